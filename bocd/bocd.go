@@ -3,6 +3,7 @@ package bocd
 import (
 	"context"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/uyouii/timeseries-algorithms/model"
@@ -11,70 +12,222 @@ import (
 )
 
 // dynamic calculate update VarX
+// BocdOnlineChecker is safe for concurrent use, the read accessors return copies
 type BocdOnlineChecker struct {
+	mu sync.RWMutex
+
 	varX  float64 // known variance
 	mean0 float64 // mean of the pre data
 
-	datas           []model.TimeValue
-	means           []float64
-	invVariances    []float64 // 1 / Variance
-	lastLogRunProbs []float64
-	runLenLogProb   [][]float64
-	runLenProb      [][]float64
+	datas             []model.TimeValue
+	means             []float64
+	invVariances      []float64 // 1 / Variance
+	lastLogRunProbs   []float64
+	lastRunLenLogProb []float64 // normalized run length log probs of the last point
+	runLenProb        [][]float64
+	mapPath           *mapPath // only tracked by the batch detection
 
 	pMeans []float64 // prediction mean
 	pVars  []float64 // prediction var
 
 	changePoints         []*model.ChangePoint
+	lastChangePointLoc   int // index of the last change point in datas
 	lastCheckTriggerTime time.Time
+
+	hazard           float64       // change point hazard of one expected interval
+	stepHazard       float64       // hazard of the current step, scaled by the elapsed time
+	expectedInterval time.Duration // expected interval between two points
+	maxGap           time.Duration // the gap longer than maxGap is treated as missing data
+	imputeGap        bool          // impute the missing data by linear interpolation
+	observeDuration  time.Duration // only check the change points in the observe duration
+}
+
+type BocdCheckerOption func(*BocdOnlineChecker)
+
+// WithExpectedInterval set the expected interval between two points, default is 1 minute,
+// the hazard is scaled by the real elapsed time compare to the expected interval
+func WithExpectedInterval(interval time.Duration) BocdCheckerOption {
+	return func(b *BocdOnlineChecker) {
+		if interval > 0 {
+			b.expectedInterval = interval
+		}
+	}
+}
+
+// WithMaxGap set the max gap between two points, the longer gap is treated as missing data
+func WithMaxGap(maxGap time.Duration) BocdCheckerOption {
+	return func(b *BocdOnlineChecker) {
+		if maxGap > 0 {
+			b.maxGap = maxGap
+		}
+	}
 }
 
-func NewBocdOnlineChecker(varx, mean0 float64) *BocdOnlineChecker {
+// WithGapImputation impute the missing data in the gap by linear interpolation,
+// otherwise only the hazard of the missing steps is applied
+func WithGapImputation(imputeGap bool) BocdCheckerOption {
+	return func(b *BocdOnlineChecker) {
+		b.imputeGap = imputeGap
+	}
+}
+
+// WithObserveDuration set the time window to look back for change points
+func WithObserveDuration(observeDuration time.Duration) BocdCheckerOption {
+	return func(b *BocdOnlineChecker) {
+		if observeDuration > 0 {
+			b.observeDuration = observeDuration
+		}
+	}
+}
+
+func NewBocdOnlineChecker(varx, mean0 float64, opts ...BocdCheckerOption) *BocdOnlineChecker {
 	bocdChecker := &BocdOnlineChecker{
 		varX:  varx,
 		mean0: mean0,
 
-		datas:           []model.TimeValue{},
-		means:           []float64{mean0},
-		invVariances:    []float64{1 / varx},
-		runLenLogProb:   [][]float64{{0}},
-		runLenProb:      [][]float64{{math.Inf(-1)}},
-		lastLogRunProbs: []float64{0},
+		datas:             []model.TimeValue{},
+		means:             []float64{mean0},
+		invVariances:      []float64{1 / varx},
+		lastRunLenLogProb: []float64{0},
+		runLenProb:        [][]float64{{math.Inf(-1)}},
+		lastLogRunProbs:   []float64{0},
 
 		pMeans: []float64{},
 		pVars:  []float64{},
 
 		changePoints:         []*model.ChangePoint{},
 		lastCheckTriggerTime: time.Time{},
+
+		hazard:           hazard(),
+		stepHazard:       hazard(),
+		expectedInterval: getExpectedInterval(),
+		maxGap:           getMaxGap(),
+		imputeGap:        false,
+		observeDuration:  getObserveDuration(),
 	}
+	for _, opt := range opts {
+		opt(bocdChecker)
+	}
+	bocdChecker.stepHazard = bocdChecker.hazard
 
 	return bocdChecker
 }
 
 func (b *BocdOnlineChecker) LastTimeValue() (model.TimeValue, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastTimeValue()
+}
+
+func (b *BocdOnlineChecker) lastTimeValue() (model.TimeValue, bool) {
 	if len(b.datas) == 0 {
 		return model.TimeValue{}, false
 	}
 	return b.datas[len(b.datas)-1], true
 }
 
+func (b *BocdOnlineChecker) FirstTimeValue() (model.TimeValue, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.datas) == 0 {
+		return model.TimeValue{}, false
+	}
+	return b.datas[0], true
+}
+
+// AppendPoint is safe for concurrent use, the readers wait at most one point calculation
 func (b *BocdOnlineChecker) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	changePoint, findChangePoint := b.appendPoint(timeValue)
+	if findChangePoint {
+		copied := *changePoint
+		changePoint = &copied
+	}
 	return changePoint, findChangePoint
 }
 
+// appendPoint fill the gap before the point if need, then append the point
 func (b *BocdOnlineChecker) appendPoint(timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	changePoint, findChangePoint := b.imputeGapPoints(timeValue)
+
+	b.stepHazard = b.scaleHazard(timeValue)
+	newChangePoint, found := b.appendObservation(timeValue)
+	if found {
+		changePoint, findChangePoint = newChangePoint, found
+	}
+	return changePoint, findChangePoint
+}
+
+// imputeGapPoints impute the points in the gap longer than maxGap by linear interpolation
+func (b *BocdOnlineChecker) imputeGapPoints(timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	// prevent too many imputed points after a very long gap
+	const MaxImputePointCnt = 1440
+
+	var changePoint *model.ChangePoint
+	findChangePoint := false
+
+	lastTimeValue, ok := b.lastTimeValue()
+	if !b.imputeGap || !ok || timeValue.Time.Sub(lastTimeValue.Time) <= b.maxGap {
+		return changePoint, findChangePoint
+	}
+
+	gap := timeValue.Time.Sub(lastTimeValue.Time)
+	imputeCnt := IntMin(int(gap/b.expectedInterval)-1, MaxImputePointCnt)
+	startTime := timeValue.Time.Add(-1 * time.Duration(imputeCnt+1) * b.expectedInterval)
+
+	for i := 1; i <= imputeCnt; i++ {
+		imputeTime := startTime.Add(time.Duration(i) * b.expectedInterval)
+		ratio := float64(imputeTime.Sub(lastTimeValue.Time)) / float64(gap)
+		imputeTimeValue := model.TimeValue{
+			Time:  imputeTime,
+			Value: lastTimeValue.Value + (timeValue.Value-lastTimeValue.Value)*ratio,
+		}
+
+		b.stepHazard = b.scaleHazard(imputeTimeValue)
+		newChangePoint, found := b.appendObservation(imputeTimeValue)
+		if found {
+			changePoint, findChangePoint = newChangePoint, found
+		}
+	}
+	return changePoint, findChangePoint
+}
+
+// scaleHazard is the hazard of the step from the last point to the point
+func (b *BocdOnlineChecker) scaleHazard(timeValue model.TimeValue) float64 {
+	lastTimeValue, ok := b.lastTimeValue()
+	if !ok {
+		return b.hazard
+	}
+	return scaleHazard(b.hazard, timeValue.Time.Sub(lastTimeValue.Time), b.expectedInterval)
+}
+
+// scaleHazard the hazard is defined for one expected interval,
+// so the probability there is no change point in elapsed time is (1-h)^(elapsed/interval)
+func scaleHazard(hazard float64, elapsed, expectedInterval time.Duration) float64 {
+	if elapsed <= 0 {
+		return hazard
+	}
+	steps := float64(elapsed) / float64(expectedInterval)
+	return 1 - math.Pow(1-hazard, steps)
+}
+
+func (b *BocdOnlineChecker) appendObservation(timeValue model.TimeValue) (*model.ChangePoint, bool) {
 	b.datas = append(b.datas, timeValue)
 
 	t := len(b.datas) // current time step
 
 	// Make model predictions.
-	b.pMeans = append(b.pMeans, b.predictionMean(t))
-	b.pVars = append(b.pVars, b.predictionVar(t))
+	b.pMeans = append(b.pMeans, b.predictionMean())
+	b.pVars = append(b.pVars, b.predictionVar())
 
 	// 3. Evaluate predictive probabilities.
 	// logPreProbs is an array that calculates the probability density of the current point x under various run lengths
 	logPreProbs := b.logOfPreProb(t, timeValue.Value)
+	if b.mapPath != nil {
+		b.mapPath.update(logPreProbs, b.logh(), b.log1mh())
+	}
 
 	// 4. Calculate growth probabilities.
 	// Growth probability, calculates the growth probability of the current point x under various run lengths,
@@ -93,8 +246,8 @@ func (b *BocdOnlineChecker) appendPoint(timeValue model.TimeValue) (*model.Chang
 	// 7. Determine run length distribution.
 	// Normalize to make the sum of these probabilities equal to 1
 	normalizeLogRunProbs := NormalizeData(logRunProbs)
-	// use normalizeLogRunProbs update runLenLogProb
-	b.runLenLogProb = append(b.runLenLogProb, normalizeLogRunProbs)
+	// only the last log probs are needed by the prediction
+	b.lastRunLenLogProb = normalizeLogRunProbs
 	b.runLenProb = append(b.runLenProb, ListExp(normalizeLogRunProbs))
 
 	// 8. update params
@@ -110,43 +263,75 @@ func (b *BocdOnlineChecker) checkChangePoints(t int) (bool, *model.ChangePoint)
 		return false, nil
 	}
 
-	observeMinutes := int(getObserveDuration().Minutes())
 	threshold := getChangePointThreshold()
+	lastTime := b.datas[t-1].Time
 
-	for j := 0; j < len(b.runLenProb[t]) && j <= observeMinutes; j++ {
+	for j := 0; j < len(b.runLenProb[t]); j++ {
+		changePointLoc := int64(t - j)
+		// run length 0 means the new run begins after the current point
+		if changePointLoc < int64(len(b.datas)) && lastTime.Sub(b.datas[changePointLoc].Time) > b.observeDuration {
+			break
+		}
 		if b.runLenProb[t][j] >= threshold {
-			changePointLoc := int64(t - j)
 			if changePointLoc == 0 {
 				break
 			}
+			if changePointLoc >= int64(len(b.datas)) {
+				continue
+			}
 			changePointTimeValue := b.datas[changePointLoc]
 
-			changePoint := &model.ChangePoint{
-				TimeValue: changePointTimeValue,
+			// if time point equal last change point, it's already found
+			lastChangePoint, ok := b.lastChangePoint()
+			if ok && lastChangePoint.TimeValue.Time.Equal(changePointTimeValue.Time) {
+				break
 			}
 
-			lastPoint := b.datas[changePointLoc-1]
-			if changePointTimeValue.Value > lastPoint.Value {
-				changePoint.ChangePointType = model.IncreaseChangePoint
-			} else {
-				changePoint.ChangePointType = model.DecreaseChangePoint
-			}
+			changePoint := b.newChangePoint(int(changePointLoc), t, j)
+			b.changePoints = append(b.changePoints, changePoint)
+			b.lastChangePointLoc = int(changePointLoc)
+			return true, changePoint
+		}
+	}
+	return false, nil
+}
 
-			if len(b.changePoints) == 0 {
-				b.changePoints = append(b.changePoints, changePoint)
-				return true, changePoint
-			}
+// newChangePoint the pre segment is from the last change point to the change point,
+// the post segment is from the change point to the current point
+func (b *BocdOnlineChecker) newChangePoint(changePointLoc, t, runLength int) *model.ChangePoint {
+	preStart := 0
+	if len(b.changePoints) > 0 && b.lastChangePointLoc < changePointLoc {
+		preStart = b.lastChangePointLoc
+	}
 
-			// if time point not equal last change point
-			lastChangePoint := b.changePoints[len(b.changePoints)-1]
-			if !lastChangePoint.TimeValue.Time.Equal(changePointTimeValue.Time) {
-				return true, changePoint
-			}
+	preMean, preVariance := segmentMeanVariance(b.datas[preStart:changePointLoc])
+	postMean, postVariance := segmentMeanVariance(b.datas[changePointLoc:t])
+	currentTime := b.datas[t-1].Time
+
+	changePoint := &model.ChangePoint{
+		TimeValue:      b.datas[changePointLoc],
+		Probability:    b.runLenProb[t][runLength],
+		RunLength:      runLength,
+		DetectTime:     currentTime,
+		DetectionDelay: currentTime.Sub(b.datas[changePointLoc].Time),
+		PreMean:        preMean,
+		PreVariance:    preVariance,
+		PostMean:       postMean,
+		PostVariance:   postVariance,
+		Magnitude:      postMean - preMean,
+	}
+	if preMean != 0 {
+		changePoint.RelativeMagnitude = changePoint.Magnitude / math.Abs(preMean)
+	}
 
-			break
-		}
+	// the type is decided by the point before the change point, the same as the first version,
+	// the suppression policies and the idempotency key depend on it
+	if changePoint.TimeValue.Value > b.datas[changePointLoc-1].Value {
+		changePoint.ChangePointType = model.IncreaseChangePoint
+	} else {
+		changePoint.ChangePointType = model.DecreaseChangePoint
 	}
-	return false, nil
+	return changePoint
 }
 
 func (b *BocdOnlineChecker) updateGuassianParams(x float64) {
@@ -163,11 +348,11 @@ func (b *BocdOnlineChecker) updateGuassianParams(x float64) {
 }
 
 func (b *BocdOnlineChecker) logh() float64 {
-	return math.Log(hazard())
+	return math.Log(b.stepHazard)
 }
 
 func (b *BocdOnlineChecker) log1mh() float64 {
-	return math.Log(1 - hazard())
+	return math.Log(1 - b.stepHazard)
 }
 
 func (b *BocdOnlineChecker) calLogChangePointProb(logPreProbs []float64) float64 {
@@ -216,37 +401,85 @@ func (b *BocdOnlineChecker) calVariances() []float64 {
 	return res
 }
 
-func (b *BocdOnlineChecker) predictionMean(t int) float64 {
-	meanProbsValue := ListMul(ListExp(b.runLenLogProb[t-1]), b.means)
+func (b *BocdOnlineChecker) predictionMean() float64 {
+	meanProbsValue := ListMul(ListExp(b.lastRunLenLogProb), b.means)
 	return floats.Sum(meanProbsValue)
 }
 
-func (b *BocdOnlineChecker) predictionVar(t int) float64 {
-	varProbsValue := ListMul(ListExp(b.runLenLogProb[t-1]), b.calVariances())
+func (b *BocdOnlineChecker) predictionVar() float64 {
+	varProbsValue := ListMul(ListExp(b.lastRunLenLogProb), b.calVariances())
 	return floats.Sum(varProbsValue)
 }
 
 func (b *BocdOnlineChecker) GetPredictionMeans() []float64 {
-	return b.pMeans
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]float64{}, b.pMeans...)
 }
 
 func (b *BocdOnlineChecker) GetPredictionVariances() []float64 {
-	return b.pVars
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]float64{}, b.pVars...)
+}
+
+// MaxRunLength is the run length with the max posterior probability after the last point
+func (b *BocdOnlineChecker) MaxRunLength() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return argMax(b.runLenProb[len(b.runLenProb)-1])
 }
 
 func (b *BocdOnlineChecker) Datas() []model.TimeValue {
-	return b.datas
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]model.TimeValue{}, b.datas...)
 }
 
 func (b *BocdOnlineChecker) DataSize() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return len(b.datas)
 }
 
+// DataDuration is the time span of the cached data
+func (b *BocdOnlineChecker) DataDuration() time.Duration {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.datas) == 0 {
+		return 0
+	}
+	return b.datas[len(b.datas)-1].Time.Sub(b.datas[0].Time)
+}
+
+func (b *BocdOnlineChecker) ObserveDuration() time.Duration {
+	return b.observeDuration
+}
+
+// GetChangePoints return the copies of the change points
 func (b *BocdOnlineChecker) GetChangePoints() []*model.ChangePoint {
-	return b.changePoints
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	res := make([]*model.ChangePoint, 0, len(b.changePoints))
+	for _, changePoint := range b.changePoints {
+		copied := *changePoint
+		res = append(res, &copied)
+	}
+	return res
 }
 
 func (b *BocdOnlineChecker) LastChangePoint() (*model.ChangePoint, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	changePoint, ok := b.lastChangePoint()
+	if !ok {
+		return nil, false
+	}
+	copied := *changePoint
+	return &copied, true
+}
+
+func (b *BocdOnlineChecker) lastChangePoint() (*model.ChangePoint, bool) {
 	if len(b.changePoints) > 0 {
 		return b.changePoints[len(b.changePoints)-1], true
 	}
@@ -0,0 +1,209 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat"
+)
+
+// BocdSegment is one segment of the MAP segmentation,
+// StartIndex and EndIndex are the indexes of the first and last point in the sorted series,
+// the imputed points are included if gap imputation is enabled
+type BocdSegment struct {
+	StartIndex int       `json:"start_index"`
+	EndIndex   int       `json:"end_index"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Count      int       `json:"count"`
+	Mean       float64   `json:"mean"`
+	Variance   float64   `json:"variance"`
+}
+
+type BocdBatchResult struct {
+	ChangePoints []*model.ChangePoint `json:"change_points"`
+	Segments     []*BocdSegment       `json:"segments"`
+	// Posterior is the sparse run length posterior, the probabilities smaller than
+	// getBatchPosteriorMinProbability are dropped, use Posterior.Dense() for the full matrix
+	Posterior *RunLengthPosterior `json:"posterior"`
+}
+
+// DetectChangePointsBatch run the bocd algorithm over a full history time series,
+// if varx <= 0, varx will be estimated from the series
+func DetectChangePointsBatch(ctx context.Context, timeSeries *model.TimeSeries,
+	varx, mean0 float64, opts ...BocdCheckerOption) (*BocdBatchResult, error) {
+	logger := utils.GetLogger(ctx)
+
+	if timeSeries.IsEmpty() {
+		logger.Error("time series is empty")
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := utils.SortedTimeValues(timeSeries.Values)
+
+	if varx <= 0 {
+		varx = estimateVarX(datas)
+	}
+	if varx <= 0 {
+		logger.Error("can not estimate varx from time series", zap.String("timeSeries", timeSeries.DebugString()))
+		return nil, common.ErrorInvalidValue
+	}
+
+	checker := NewBocdOnlineChecker(varx, mean0, opts...)
+	checker.mapPath = newMapPath()
+	posterior := &RunLengthPosterior{
+		MaxRunLengths:  []int{},
+		Entries:        []RunLengthEntry{},
+		MinProbability: getBatchPosteriorMinProbability(),
+	}
+	// runLenProb[0] is the prior, the checker only need the last row, so the others are
+	// moved to the sparse posterior once the next point is appended
+	released := 1
+	for _, timeValue := range datas {
+		checker.appendPoint(timeValue)
+		released = posterior.appendRows(checker.runLenProb, released, len(checker.runLenProb)-1)
+	}
+	posterior.appendRows(checker.runLenProb, released, len(checker.runLenProb))
+
+	posterior.Datas = checker.Datas()
+	posterior.PredictionMeans = checker.GetPredictionMeans()
+	posterior.PredictionVariances = checker.GetPredictionVariances()
+	posterior.ChangePoints = checker.GetChangePoints()
+
+	res := &BocdBatchResult{
+		ChangePoints: checker.GetChangePoints(),
+		Segments:     mapSegments(checker.mapPath.runLengths(), posterior.Datas),
+		Posterior:    posterior,
+	}
+
+	logger.Info("detect change points batch success", zap.Int("pointCnt", len(datas)),
+		zap.Int("changePointCnt", len(res.ChangePoints)), zap.Int("segmentCnt", len(res.Segments)),
+		zap.Int("posteriorEntryCnt", len(posterior.Entries)))
+
+	return res, nil
+}
+
+// appendRows move the rows [from, to) of the run length probs to the sparse posterior and release them,
+// return the next row to move
+func (p *RunLengthPosterior) appendRows(runLenProb [][]float64, from, to int) int {
+	for t := from; t < to; t++ {
+		p.MaxRunLengths = append(p.MaxRunLengths, argMax(runLenProb[t]))
+		p.Entries = appendRunLengthEntries(p.Entries, runLenProb[t], t-1, p.MinProbability)
+		runLenProb[t] = nil
+	}
+	return IntMax(from, to)
+}
+
+// mapPath is the viterbi recursion of the run length, the same as the bocd recursion with the sum
+// replaced by max, so the backtrack is the MAP segmentation of the whole series
+type mapPath struct {
+	logProbs []float64 // the max log joint probability of each run length after the last point
+	// backPointers[t] is the run length before the point t when the run ends at it
+	backPointers []int
+}
+
+func newMapPath() *mapPath {
+	return &mapPath{
+		logProbs:     []float64{0},
+		backPointers: []int{},
+	}
+}
+
+// update use the same predictive probs and hazard of the checker step
+func (p *mapPath) update(logPreProbs []float64, logh, log1mh float64) {
+	logProbs := make([]float64, len(p.logProbs)+1)
+	logProbs[0] = math.Inf(-1)
+	backPointer := 0
+	for i := range p.logProbs {
+		logProb := p.logProbs[i] + logPreProbs[i]
+		logProbs[i+1] = logProb + log1mh
+		if logProb+logh > logProbs[0] {
+			logProbs[0], backPointer = logProb+logh, i
+		}
+	}
+
+	// rescale so the long series won't underflow, the path is not changed
+	maxLogProb := logProbs[argMax(logProbs)]
+	if !math.IsInf(maxLogProb, 0) {
+		for i := range logProbs {
+			logProbs[i] -= maxLogProb
+		}
+	}
+	p.logProbs = logProbs
+	p.backPointers = append(p.backPointers, backPointer)
+}
+
+// runLengths backtrack the MAP run length after each point, run length 0 means the run ends at the point
+func (p *mapPath) runLengths() []int {
+	res := make([]int, len(p.backPointers))
+	runLength := argMax(p.logProbs)
+	for t := len(res) - 1; t >= 0; t-- {
+		res[t] = runLength
+		if runLength > 0 {
+			runLength--
+		} else {
+			runLength = p.backPointers[t]
+		}
+	}
+	return res
+}
+
+// mapSegments split the datas where the MAP run ends
+func mapSegments(runLengths []int, datas []model.TimeValue) []*BocdSegment {
+	res := []*BocdSegment{}
+
+	start := 0
+	for end := range datas {
+		if end < len(datas)-1 && runLengths[end] != 0 {
+			continue
+		}
+		values := make([]float64, 0, end-start+1)
+		for _, timeValue := range datas[start : end+1] {
+			values = append(values, timeValue.Value)
+		}
+		mean, variance := stat.MeanVariance(values, nil)
+		if len(values) == 1 {
+			variance = 0
+		}
+
+		res = append(res, &BocdSegment{
+			StartIndex: start,
+			EndIndex:   end,
+			StartTime:  datas[start].Time,
+			EndTime:    datas[end].Time,
+			Count:      len(values),
+			Mean:       mean,
+			Variance:   variance,
+		})
+		start = end + 1
+	}
+	return res
+}
+
+// estimateVarX use the first order difference to estimate the noise variance,
+// so that the level shift won't make the variance too big
+func estimateVarX(datas []model.TimeValue) float64 {
+	if len(datas) < 2 {
+		return 0
+	}
+	diffs := make([]float64, 0, len(datas)-1)
+	for i := 1; i < len(datas); i++ {
+		diffs = append(diffs, datas[i].Value-datas[i-1].Value)
+	}
+	return stat.Variance(diffs, nil) / 2
+}
+
+func argMax(data []float64) int {
+	res := 0
+	for i := range data {
+		if data[i] > data[res] {
+			res = i
+		}
+	}
+	return res
+}
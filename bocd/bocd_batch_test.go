@@ -0,0 +1,92 @@
+package bocd
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// stepTimeSeries is the minutely series with the unit noise, the level is levels[i] from the index steps[i]
+func stepTimeSeries(start time.Time, cnt int, steps []int, levels []float64) *model.TimeSeries {
+	random := rand.New(rand.NewSource(1))
+	res := &model.TimeSeries{}
+	for i := 0; i < cnt; i++ {
+		level := 0.0
+		for j, step := range steps {
+			if i >= step {
+				level = levels[j]
+			}
+		}
+		res.Values = append(res.Values, model.TimeValue{
+			Time:  start.Add(time.Duration(i) * time.Minute),
+			Value: level + random.NormFloat64(),
+		})
+	}
+	return res
+}
+
+func TestDetectChangePointsBatch(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeSeries := stepTimeSeries(start, 200, []int{100}, []float64{10})
+
+	res, err := DetectChangePointsBatch(context.Background(), timeSeries, 1, 0)
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+
+	if len(res.ChangePoints) != 1 {
+		t.Fatalf("want 1 change point, got %d", len(res.ChangePoints))
+	}
+	changePoint := res.ChangePoints[0]
+	if !changePoint.TimeValue.Time.Equal(start.Add(100*time.Minute)) ||
+		changePoint.ChangePointType != model.IncreaseChangePoint {
+		t.Errorf("unexpected change point %+v", changePoint)
+	}
+
+	if len(res.Segments) != 2 {
+		t.Fatalf("want 2 segments, got %d", len(res.Segments))
+	}
+	if res.Segments[0].EndIndex != 99 || res.Segments[1].StartIndex != 100 || res.Segments[1].EndIndex != 199 {
+		t.Errorf("unexpected segments %+v %+v", res.Segments[0], res.Segments[1])
+	}
+	if res.Segments[1].Mean < 9 || res.Segments[1].Mean > 11 {
+		t.Errorf("unexpected post segment mean %v", res.Segments[1].Mean)
+	}
+
+	posterior := res.Posterior
+	if len(posterior.Datas) != 200 || len(posterior.MaxRunLengths) != 200 {
+		t.Fatalf("unexpected posterior size %d %d", len(posterior.Datas), len(posterior.MaxRunLengths))
+	}
+	// the posterior is concentrated, far fewer entries than the dense matrix
+	if len(posterior.Entries) == 0 || len(posterior.Entries) > 200*201/2/2 {
+		t.Errorf("unexpected posterior entry count %d", len(posterior.Entries))
+	}
+	for _, entry := range posterior.Entries {
+		if entry.Probability < posterior.MinProbability {
+			t.Fatalf("entry under the floor %+v", entry)
+		}
+	}
+	if posterior.MaxRunLengths[199] != 100 {
+		t.Errorf("want run length 100 at the last point, got %d", posterior.MaxRunLengths[199])
+	}
+}
+
+func TestMapSegmentsTwoChanges(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeSeries := stepTimeSeries(start, 150, []int{50, 100}, []float64{8, 0})
+
+	res, err := DetectChangePointsBatch(context.Background(), timeSeries, 1, 0)
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	starts := []int{}
+	for _, segment := range res.Segments {
+		starts = append(starts, segment.StartIndex)
+	}
+	if len(starts) != 3 || starts[1] != 50 || starts[2] != 100 {
+		t.Errorf("want segments start at [0 50 100], got %v", starts)
+	}
+}
@@ -2,9 +2,14 @@ package bocd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/metrics"
 	"github.com/uyouii/timeseries-algorithms/model"
 	"github.com/uyouii/timeseries-algorithms/utils"
 	"go.uber.org/zap"
@@ -12,14 +17,16 @@ import (
 
 // LastTriggerPointTime: all the container last check trigger point time
 type BocdTriggerData struct {
-	TriggeredChangePoints []*model.ChangePoint `json:"triggered_change_points"`
-	LastTriggerPointTime  time.Time            `json:"last_trigger_point_time"`
+	TriggeredChangePoints  []*model.ChangePoint `json:"triggered_change_points"`
+	SuppressedChangePoints []*model.ChangePoint `json:"suppressed_change_points,omitempty"`
+	LastTriggerPointTime   time.Time            `json:"last_trigger_point_time"`
 }
 
 func NewBocdTriggerData() *BocdTriggerData {
 	return &BocdTriggerData{
-		TriggeredChangePoints: []*model.ChangePoint{},
-		LastTriggerPointTime:  time.Time{},
+		TriggeredChangePoints:  []*model.ChangePoint{},
+		SuppressedChangePoints: []*model.ChangePoint{},
+		LastTriggerPointTime:   time.Time{},
 	}
 }
 
@@ -29,37 +36,174 @@ func NewBocdTriggerData() *BocdTriggerData {
 // and each container handler will handle all the time series data
 // and the bocd handler will confirm that each change point will only trigger once
 
+// BocdHandler is safe for concurrent use, appending data and popping change points
+// can run in different goroutines, and the slow append won't block the pop
 type BocdHandler struct {
-	onlineChecker      *BocdOnlineChecker
-	newChangePoints    []*model.ChangePoint // local new generate change point
-	bocdTriggerData    *BocdTriggerData
-	lastAppendDataTime time.Time // last append time series to handler
-	timeSeriesKey      string
-	varx               float64
-	mean0              float64
+	appendMu sync.Mutex   // serialize the append, the checker need append point one by one
+	popMu    sync.Mutex   // serialize the pop, so the trigger data won't be updated twice
+	mu       sync.RWMutex // protect the fields below, only held for short time
+
+	onlineChecker       *BocdOnlineChecker
+	newChangePoints     []*model.ChangePoint // local new generate change point
+	triggerStateStore   TriggerStateStore
+	statisticsProvider  StatisticsProvider
+	suppressionPolicies []SuppressionPolicy
+	sinks               []ChangePointSink
+	sinkTimeout         time.Duration        // max time of all the sinks in one pop
+	metrics             *metrics.BocdMetrics // nil means the metrics is disabled
+	clock               utils.Clock
+	checkerOptions      []BocdCheckerOption
+	config              *BocdConfig // fixed hyperparameters, the statistics provider is not used if set
+	lastAppendDataTime  time.Time   // last append time series to handler
+	timeSeriesKey       string
+	varx                float64
+	mean0               float64
 }
 
-func NewBocdHandler(ctx context.Context, timeSeriesKey string) (*BocdHandler, bool) {
+type BocdHandlerOption func(*BocdHandler)
+
+// WithTriggerStateStore set the store shared by all the containers,
+// default is an in memory store which only dedup in the handler
+func WithTriggerStateStore(store TriggerStateStore) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		if store != nil {
+			m.triggerStateStore = store
+		}
+	}
+}
+
+// WithStatisticsProvider set the provider to get varx and mean0, default is GetNormalStatisticData,
+// it has no data source so the default varx 1 and mean0 0 are used, which only fit the series of that scale
+func WithStatisticsProvider(provider StatisticsProvider) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		if provider != nil {
+			m.statisticsProvider = provider
+		}
+	}
+}
+
+// WithClock set the clock used to decide which change points need trigger,
+// use a utils.ManualClock to replay the history data
+func WithClock(clock utils.Clock) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		if clock != nil {
+			m.clock = clock
+		}
+	}
+}
+
+// WithCheckerOptions set the options used to create the online checker,
+// like WithExpectedInterval when the scrape interval is not 1 minute
+func WithCheckerOptions(opts ...BocdCheckerOption) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		m.checkerOptions = append(m.checkerOptions, opts...)
+	}
+}
+
+// WithBocdConfig use the fixed hyperparameters, like the config fitted by FitBocdConfig
+func WithBocdConfig(config *BocdConfig) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		if config != nil {
+			m.config = config
+			m.checkerOptions = append(m.checkerOptions, config.CheckerOptions()...)
+		}
+	}
+}
+
+// WithSuppressionPolicies replace the default policies, the change point is suppressed
+// by the first policy which suppress it. default only limit 10 change points in 30 minutes
+func WithSuppressionPolicies(policies ...SuppressionPolicy) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		m.suppressionPolicies = policies
+	}
+}
+
+// WithSinks push the triggered change points to the sinks when pop,
+// the failed sink is only logged, the change points are still returned by pop.
+// the sinks are called one by one in the pop and hold the pop lock, so the slow sink delays the pop,
+// all the sinks share the deadline of WithSinkTimeout, the sink need async delivery should queue it itself
+func WithSinks(sinks ...ChangePointSink) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		m.sinks = append(m.sinks, sinks...)
+	}
+}
+
+// WithSinkTimeout the max time of sending to all the sinks in one pop, default is 10 seconds,
+// the sink not finished is canceled by the ctx and logged
+func WithSinkTimeout(timeout time.Duration) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		if timeout > 0 {
+			m.sinkTimeout = timeout
+		}
+	}
+}
+
+// WithMetrics record the handler metrics labelled by the time series key
+func WithMetrics(bocdMetrics *metrics.BocdMetrics) BocdHandlerOption {
+	return func(m *BocdHandler) {
+		m.metrics = bocdMetrics
+	}
+}
+
+// newBocdHandler create the handler with default fields and options, the online checker is not created
+func newBocdHandler(timeSeriesKey string, opts ...BocdHandlerOption) *BocdHandler {
+	handler := &BocdHandler{
+		newChangePoints:     []*model.ChangePoint{},
+		triggerStateStore:   NewMemoryTriggerStateStore(),
+		statisticsProvider:  StatisticsProviderFunc(GetNormalStatisticData),
+		suppressionPolicies: getDefaultSuppressionPolicies(),
+		clock:               utils.NewRealClock(),
+		sinkTimeout:         getSinkTimeout(),
+		timeSeriesKey:       timeSeriesKey,
+		lastAppendDataTime:  time.Time{},
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
+}
+
+// NewBocdHandler get varx and mean0 by WithBocdConfig or WithStatisticsProvider, the default varx and mean0 are
+// used if neither is set. return false if the configured provider can't get the params
+func NewBocdHandler(ctx context.Context, timeSeriesKey string, opts ...BocdHandlerOption) (*BocdHandler, bool) {
+	handler := newBocdHandler(timeSeriesKey, opts...)
+
 	// get varx, mean0
+	varx, mean0, ok := handler.getCheckerParams(ctx)
+	if !ok {
+		return nil, false
+	}
+	handler.varx, handler.mean0 = varx, mean0
+	handler.onlineChecker = NewBocdOnlineChecker(varx, mean0, handler.checkerOptions...)
+
+	return handler, true
+}
+
+// getCheckerParams get varx and mean0 from the config or the statistics provider
+func (m *BocdHandler) getCheckerParams(ctx context.Context) (float64, float64, bool) {
 	logger := utils.GetLogger(ctx)
 
-	dailyStatisticsData, err := GetNormalStatisticData(ctx, timeSeriesKey)
+	if m.config != nil {
+		return m.config.Varx, m.config.Mean0, m.config.Varx > 0
+	}
+
+	dailyStatisticsData, err := m.statisticsProvider.GetDailyStatisticsData(ctx, m.timeSeriesKey)
+	if errors.Is(err, common.ErrorStatisticsNotConfigured) {
+		varx, mean0 := getDefaultVarxMean0()
+		return varx, mean0, true
+	}
 	if err != nil {
-		logger.Error("GetNormalStatisticData failed", zap.Error(err))
-		return nil, false
+		logger.Error("GetDailyStatisticsData failed", zap.Error(err), zap.String("timeSeriesKey", m.timeSeriesKey))
+		return 0, 0, false
 	}
 
-	varx, mean0 := dailyStatisticsData.RecentNormalVariance, dailyStatisticsData.RecentNormalMean
+	if !validStatisticsData(dailyStatisticsData, m.clock.Now()) {
+		logger.Error("daily statistics data invalid", zap.String("timeSeriesKey", m.timeSeriesKey),
+			zap.Any("dailyStatisticsData", dailyStatisticsData))
+		return 0, 0, false
+	}
 
-	return &BocdHandler{
-		onlineChecker:      NewBocdOnlineChecker(varx, mean0),
-		newChangePoints:    []*model.ChangePoint{},
-		bocdTriggerData:    NewBocdTriggerData(),
-		timeSeriesKey:      timeSeriesKey,
-		varx:               varx,
-		mean0:              mean0,
-		lastAppendDataTime: time.Time{},
-	}, true
+	return dailyStatisticsData.RecentNormalVariance, dailyStatisticsData.RecentNormalMean, true
 }
 
 // bocd algorithm need cache the history data in memory
@@ -67,55 +211,92 @@ func NewBocdHandler(ctx context.Context, timeSeriesKey string) (*BocdHandler, bo
 func (m *BocdHandler) rebalance(ctx context.Context, timeValue model.TimeValue) {
 	logger := utils.GetLogger(ctx)
 
-	// this means if PreSmoothMinute don't have chagne point
-	// will reuse reserveMinute to regenerate the bocd checker
-	const PreSmoothMinute, ReserveMinute = 360, 180 // 6h, 3h
-	// if data count > 1440 minut(1 day), reset the cache
-	const MaxDataSize = 1440
-
-	lastChangePoint, ok := m.onlineChecker.LastChangePoint()
-	needRebalance := (ok && timeValue.Time.Sub(lastChangePoint.TimeValue.Time) > PreSmoothMinute*time.Minute) ||
-		(!ok && m.onlineChecker.DataSize() > PreSmoothMinute)
-	if m.onlineChecker.DataSize() > MaxDataSize {
-		needRebalance = true
+	onlineChecker := m.checker()
+	if !needRebalance(onlineChecker, timeValue) {
+		return
 	}
 
-	if !needRebalance {
-		return
+	m.mu.RLock()
+	varx, mean0 := m.varx, m.mean0
+	m.mu.RUnlock()
+
+	// if get new params failed, keep using the old params
+	if newVarx, newMean0, ok := m.getCheckerParams(ctx); ok {
+		logger.Info("get new varx and mean0", zap.Float64("varx", newVarx), zap.Float64("mean0", newMean0))
+		varx, mean0 = newVarx, newMean0
 	}
 
-	datas := m.onlineChecker.Datas()
-	reserveDatas := datas[len(datas)-ReserveMinute:]
+	// build the new checker without lock, only lock when swap
+	newOnlineChecker := reserveChecker(ctx, onlineChecker, timeValue, varx, mean0, m.checkerOptions)
 
-	dailyStatisticsData, err := GetNormalStatisticData(ctx, m.timeSeriesKey)
-	if err != nil {
-		logger.Error("GetNormalStatisticData failed", zap.Error(err))
-	} else {
-		varx, mean0 := dailyStatisticsData.RecentNormalVariance, dailyStatisticsData.RecentNormalMean
-		logger.Info("get new varx and mean0", zap.Float64("varx", varx), zap.Float64("mean0", mean0))
-		m.varx, m.mean0 = varx, mean0
+	m.mu.Lock()
+	m.onlineChecker = newOnlineChecker
+	m.varx, m.mean0 = varx, mean0
+	m.mu.Unlock()
+	m.metrics.IncRebalance(m.timeSeriesKey)
+	logger.Info("generage new online checker")
+}
+
+const (
+	// this means if preSmoothDuration don't have chagne point
+	// will reuse reserveDuration data to regenerate the bocd checker
+	preSmoothDuration, reserveDuration = 6 * time.Hour, 3 * time.Hour
+	// if data duration > 1 day, reset the cache
+	maxDataDuration = 24 * time.Hour
+)
+
+// needRebalance check whether the checker need be rebuilt before append the point
+func needRebalance(onlineChecker *BocdOnlineChecker, timeValue model.TimeValue) bool {
+	lastChangePoint, ok := onlineChecker.LastChangePoint()
+	if ok && timeValue.Time.Sub(lastChangePoint.TimeValue.Time) > preSmoothDuration {
+		return true
 	}
+	if !ok && onlineChecker.DataDuration() > preSmoothDuration {
+		return true
+	}
+	return onlineChecker.DataDuration() > maxDataDuration
+}
 
-	newOnlineChecker := NewBocdOnlineChecker(m.varx, m.mean0)
-	for _, reserveTimeValue := range reserveDatas {
+// reserveChecker build the new checker with the datas in reserveDuration before the point
+func reserveChecker(ctx context.Context, onlineChecker *BocdOnlineChecker, timeValue model.TimeValue,
+	varx, mean0 float64, opts []BocdCheckerOption) *BocdOnlineChecker {
+	datas := onlineChecker.Datas()
+	reserveStartTime := timeValue.Time.Add(-1 * reserveDuration)
+	reserveIndex := sort.Search(len(datas), func(i int) bool {
+		return datas[i].Time.After(reserveStartTime)
+	})
+
+	newOnlineChecker := NewBocdOnlineChecker(varx, mean0, opts...)
+	for _, reserveTimeValue := range datas[reserveIndex:] {
 		newOnlineChecker.AppendPoint(ctx, reserveTimeValue)
 	}
-	m.onlineChecker = newOnlineChecker
-	logger.Info("generage new online checker")
+	return newOnlineChecker
+}
+
+func (m *BocdHandler) checker() *BocdOnlineChecker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.onlineChecker
 }
 
 func (m *BocdHandler) appendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
 	logger := utils.GetLogger(ctx)
 	logger.Info("Begin Append Point", zap.Any("timeValue", timeValue))
 
+	beginTime := time.Now()
+
 	// 1. check whether need reblance
 	m.rebalance(ctx, timeValue)
 
 	// 2. append new point
-	changePoint, found := m.onlineChecker.AppendPoint(ctx, timeValue)
+	onlineChecker := m.checker()
+	changePoint, found := onlineChecker.AppendPoint(ctx, timeValue)
+	m.metrics.ObserveAppend(m.timeSeriesKey, time.Since(beginTime), onlineChecker.MaxRunLength(), found)
 	if found {
 		logger.Info("find new change point", zap.Any("changePoint", changePoint))
+		m.mu.Lock()
 		m.newChangePoints = append(m.newChangePoints, changePoint)
+		m.mu.Unlock()
 	}
 
 	return changePoint, found
@@ -123,11 +304,16 @@ func (m *BocdHandler) appendPoint(ctx context.Context, timeValue model.TimeValue
 
 func (m *BocdHandler) AppendTimeSeriesData(ctx context.Context, timeSeries *model.TimeSeries) {
 	logger := utils.GetLogger(ctx)
-	// init can first append 2 hours data
 
+	m.appendMu.Lock()
+	defer m.appendMu.Unlock()
+
+	// init can first append 2 hours data
+	m.mu.RLock()
 	lastAppendDataTime := m.lastAppendDataTime
+	m.mu.RUnlock()
 	if lastAppendDataTime.IsZero() {
-		lastAppendDataTime = time.Now().Add(-2 * time.Hour)
+		lastAppendDataTime = m.clock.Now().Add(-2 * time.Hour)
 	}
 
 	foundCount := 0
@@ -141,93 +327,218 @@ func (m *BocdHandler) AppendTimeSeriesData(ctx context.Context, timeSeries *mode
 		}
 	}
 
-	if len(timeSeries.Values) > 0 {
-		m.lastAppendDataTime = timeSeries.Values[len(timeSeries.Values)-1].Time
+	// the values may be out of order, use the max time
+	maxTime := time.Time{}
+	for _, timeValue := range timeSeries.Values {
+		if timeValue.Time.After(maxTime) {
+			maxTime = timeValue.Time
+		}
 	}
+	m.mu.Lock()
+	if maxTime.After(m.lastAppendDataTime) {
+		m.lastAppendDataTime = maxTime
+	}
+	m.mu.Unlock()
 
 	logger.Info(fmt.Sprintf("found %v change points", foundCount))
 }
 
+// PopNeedTriggerChangePoints pop the change points need trigger, the suppressed ones are only logged
 func (m *BocdHandler) PopNeedTriggerChangePoints(ctx context.Context) []*model.ChangePoint {
 	logger := utils.GetLogger(ctx)
 
+	changePoints, suppressedChangePoints := m.PopNeedTriggerChangePointsWithReport(ctx)
+	for _, suppressed := range suppressedChangePoints {
+		logger.Info("change point suppressed", zap.String("timeSeriesKey", m.timeSeriesKey),
+			zap.Any("changePoint", suppressed.ChangePoint), zap.String("policy", suppressed.Policy),
+			zap.String("reason", suppressed.Reason))
+	}
+	return changePoints
+}
+
+// PopNeedTriggerChangePointsWithReport pop the change points need trigger, and report the change points
+// dropped by the suppression policies. the suppressed change points won't be checked again
+func (m *BocdHandler) PopNeedTriggerChangePointsWithReport(ctx context.Context) (
+	[]*model.ChangePoint, []*SuppressedChangePoint) {
+	logger := utils.GetLogger(ctx)
+
+	m.popMu.Lock()
+	defer m.popMu.Unlock()
+
+	// the new change points appended during pop are kept to the next pop
+	newChangePoints := m.NewChangePoints()
+
 	// 1. if no change point need trigger, just return
-	if len(m.newChangePoints) == 0 {
+	if len(newChangePoints) == 0 {
 		logger.Info("no new change point")
-		return nil
+		return nil, nil
+	}
+
+	logger.Info(fmt.Sprintf("%v local chagne point need be checked", len(newChangePoints)))
+
+	// other containers may update the trigger data at the same time,
+	// so retry if the compare and swap failed
+	const MaxCasRetryCount = 3
+
+	for retry := 0; retry < MaxCasRetryCount; retry++ {
+		// 2. get trigger data from store
+		bocdTriggerData, version, err := m.triggerStateStore.Get(ctx, m.timeSeriesKey)
+		if err != nil {
+			logger.Error("get trigger data failed", zap.Error(err), zap.String("timeSeriesKey", m.timeSeriesKey))
+			return nil, nil
+		}
+		if bocdTriggerData == nil {
+			bocdTriggerData = NewBocdTriggerData()
+		}
+
+		candidateChangePoints, remainChangePoints, nowCheckTriggerTime :=
+			m.splitNeedTriggerChangePoints(bocdTriggerData, newChangePoints)
+
+		if len(candidateChangePoints) == 0 && len(remainChangePoints) == 0 {
+			m.removeNewChangePoints(len(newChangePoints))
+			logger.Info("no new local change point need trigger")
+			return nil, nil
+		}
+
+		// 5. apply the suppression policies
+		suppressionCtx := &SuppressionContext{
+			Now:                    m.clock.Now(),
+			TriggeredChangePoints:  append([]*model.ChangePoint{}, bocdTriggerData.TriggeredChangePoints...),
+			SuppressedChangePoints: append([]*model.ChangePoint{}, bocdTriggerData.SuppressedChangePoints...),
+			PendingChangePoints:    remainChangePoints,
+		}
+		needTriggerChangePoints, suppressedChangePoints := applySuppressionPolicies(ctx, m.suppressionPolicies,
+			candidateChangePoints, suppressionCtx)
+
+		// 6. reset trigger point data, remove some old chagne points, to prevent redis cache too big
+		bocdTriggerData.TriggeredChangePoints = RemoveOldChangePointsAt(m.clock.Now(), suppressionCtx.TriggeredChangePoints)
+		bocdTriggerData.SuppressedChangePoints = RemoveOldChangePointsAt(m.clock.Now(), suppressionCtx.SuppressedChangePoints)
+		if nowCheckTriggerTime.After(bocdTriggerData.LastTriggerPointTime) {
+			bocdTriggerData.LastTriggerPointTime = nowCheckTriggerTime
+		}
+
+		swapped, err := m.triggerStateStore.CompareAndSwap(ctx, m.timeSeriesKey, version, bocdTriggerData)
+		if err != nil {
+			logger.Error("set trigger data failed", zap.Error(err), zap.String("timeSeriesKey", m.timeSeriesKey))
+			return nil, nil
+		}
+		if !swapped {
+			logger.Info("trigger data changed by others, retry", zap.Int("retry", retry))
+			continue
+		}
+
+		m.removeNewChangePoints(len(newChangePoints) - len(remainChangePoints))
+
+		logger.Info(fmt.Sprintf("%v new local change point need trigger, %v suppressed",
+			len(needTriggerChangePoints), len(suppressedChangePoints)))
+
+		m.metrics.AddTriggered(m.timeSeriesKey, len(needTriggerChangePoints))
+		for _, suppressed := range suppressedChangePoints {
+			m.metrics.IncSuppressed(m.timeSeriesKey, suppressed.Policy)
+		}
+
+		m.sendToSinks(ctx, needTriggerChangePoints)
+
+		return needTriggerChangePoints, suppressedChangePoints
 	}
 
-	logger.Info(fmt.Sprintf("%v local chagne point need be checked", len(m.newChangePoints)))
+	logger.Error("set trigger data conflict too many times", zap.String("timeSeriesKey", m.timeSeriesKey))
+	return nil, nil
+}
 
-	// 2. get trigger data from redis
+func (m *BocdHandler) sendToSinks(ctx context.Context, changePoints []*model.ChangePoint) {
+	logger := utils.GetLogger(ctx)
 
-	beginCheckTime := m.bocdTriggerData.LastTriggerPointTime
+	if len(changePoints) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.sinkTimeout)
+	defer cancel()
+
+	notifications := newChangePointNotifications(m.timeSeriesKey, changePoints)
+	for _, sink := range m.sinks {
+		if err := sink.Send(ctx, notifications); err != nil {
+			logger.Error("send change points to sink failed", zap.Error(err),
+				zap.String("timeSeriesKey", m.timeSeriesKey), zap.String("sink", fmt.Sprintf("%T", sink)))
+		}
+	}
+}
+
+// splitNeedTriggerChangePoints split the local change points into need trigger and remain,
+// the change points already triggered by any container will be removed
+func (m *BocdHandler) splitNeedTriggerChangePoints(bocdTriggerData *BocdTriggerData,
+	newChangePoints []*model.ChangePoint) ([]*model.ChangePoint, []*model.ChangePoint, time.Time) {
+	beginCheckTime := bocdTriggerData.LastTriggerPointTime
 	// if a change point appear too long ago, don't check it
-	minTracebackTime := time.Now().Add(-1 * getMaxTracebackDuration())
+	minTracebackTime := m.clock.Now().Add(-1 * getMaxTracebackDuration())
 	if beginCheckTime.IsZero() || minTracebackTime.After(beginCheckTime) {
 		beginCheckTime = minTracebackTime
 	}
 
 	// 3. check chagne points, remove the change point already triggered
 	index := 0
-	for ; index < len(m.newChangePoints); index++ {
-		changePoint := m.newChangePoints[index]
+	for ; index < len(newChangePoints); index++ {
+		changePoint := newChangePoints[index]
 		if changePoint.TimeValue.Time.After(beginCheckTime) {
 			break
 		}
 	}
-	m.newChangePoints = m.newChangePoints[index:]
-
-	if len(m.newChangePoints) == 0 {
-		logger.Info("no new local change point need trigger")
-		return nil
-	}
+	changePoints := newChangePoints[index:]
 
 	// 4. get need trigger chagne point
-	nowCheckTriggerTime := time.Now().Add(-1 * getObserveDuration())
+	nowCheckTriggerTime := m.clock.Now().Add(-1 * m.checker().ObserveDuration())
 
 	index = 0
-	for ; index < len(m.newChangePoints); index++ {
-		changePoint := m.newChangePoints[index]
+	for ; index < len(changePoints); index++ {
+		changePoint := changePoints[index]
 		if changePoint.TimeValue.Time.After(nowCheckTriggerTime) {
 			break
 		}
 	}
 
-	needTriggerChangePoints := m.newChangePoints[:index]
-	m.newChangePoints = m.newChangePoints[index:]
-
-	// 5. reset redis trigger point data
-	m.bocdTriggerData.TriggeredChangePoints = append(
-		m.bocdTriggerData.TriggeredChangePoints, needTriggerChangePoints...)
-	// remove some old chagne points, to prevent redis cache too big
-	m.bocdTriggerData.TriggeredChangePoints = RemoveOldChangePoints(m.bocdTriggerData.TriggeredChangePoints)
-	m.bocdTriggerData.LastTriggerPointTime = nowCheckTriggerTime
-
-	if m.skipCheckChangePoints(ctx, m.newChangePoints, m.bocdTriggerData.TriggeredChangePoints) {
-		logger.Info("too many change points in recent times, skip cpd check")
-		return nil
+	needTriggerChangePoints := []*model.ChangePoint{}
+	for _, changePoint := range changePoints[:index] {
+		if !containsChangePoint(bocdTriggerData.TriggeredChangePoints, changePoint) &&
+			!containsChangePoint(bocdTriggerData.SuppressedChangePoints, changePoint) {
+			needTriggerChangePoints = append(needTriggerChangePoints, changePoint)
+		}
 	}
 
-	logger.Info(fmt.Sprintf("%v new local change point need trigger", len(needTriggerChangePoints)))
+	return needTriggerChangePoints, changePoints[index:], nowCheckTriggerTime
+}
 
-	return needTriggerChangePoints
+// removeNewChangePoints remove the first cnt local change points which have been handled
+func (m *BocdHandler) removeNewChangePoints(cnt int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.newChangePoints = m.newChangePoints[cnt:]
 }
 
-func (m *BocdHandler) skipCheckChangePoints(ctx context.Context,
-	triggeredChangePonits, newChangePoints []*model.ChangePoint) bool {
-	logger := utils.GetLogger(ctx)
+// NewChangePoints return the local change points not triggered yet
+func (m *BocdHandler) NewChangePoints() []*model.ChangePoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*model.ChangePoint{}, m.newChangePoints...)
+}
 
-	recentDuration := time.Minute * 30
+func (m *BocdHandler) Datas() []model.TimeValue {
+	return m.checker().Datas()
+}
 
-	skipCheckChangePointCount := 10
+func (m *BocdHandler) GetChangePoints() []*model.ChangePoint {
+	return m.checker().GetChangePoints()
+}
 
-	count := GetChangePointCountInRecentTime(recentDuration, triggeredChangePonits) +
-		GetChangePointCountInRecentTime(recentDuration, newChangePoints)
-	if count > skipCheckChangePointCount {
-		logger.Info("too many chagne point in recent times", zap.Any("rcentTime", recentDuration),
-			zap.Int("limitCount", skipCheckChangePointCount), zap.Int("changePointCnt", count))
-		return true
+// ExportRunLengthPosterior export the posterior of the current checker, the datas before rebalance are not included
+func (m *BocdHandler) ExportRunLengthPosterior(minProbability float64) *RunLengthPosterior {
+	return m.checker().ExportRunLengthPosterior(minProbability)
+}
+
+func containsChangePoint(changePoints []*model.ChangePoint, changePoint *model.ChangePoint) bool {
+	for _, v := range changePoints {
+		if v.TimeValue.Time.Equal(changePoint.TimeValue.Time) {
+			return true
+		}
 	}
 	return false
 }
@@ -0,0 +1,160 @@
+package bocd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uyouii/timeseries-algorithms/metrics"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+)
+
+// TestBocdHandlerConcurrent run with -race, the ingest append the points while the others pop the change points
+// and read the checker. the series is longer than the pre smooth duration so the checker is rebalanced
+func TestBocdHandlerConcurrent(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+	handler, ok := NewBocdHandler(ctx, "key", WithClock(clock),
+		WithBocdConfig(&BocdConfig{Varx: 1, Mean0: 0, Hazard: hazard()}))
+	if !ok {
+		t.Fatalf("new handler failed")
+	}
+	const pointCnt = 480
+	timeSeries := stepTimeSeries(start, pointCnt, []int{60}, []float64{10})
+
+	// the pop run with each append, the clock only advance after both finish,
+	// otherwise the change point may be older than the max traceback duration when popped
+	done := make(chan struct{})
+	appending, poppedOnce := make(chan struct{}), make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		defer close(appending)
+		for i := 0; i < pointCnt; i += 10 {
+			clock.Set(timeSeries.Values[i].Time)
+			appending <- struct{}{}
+			handler.AppendTimeSeriesData(ctx, &model.TimeSeries{Values: timeSeries.Values[i : i+10]})
+			<-poppedOnce
+		}
+	}()
+	popped := []*model.ChangePoint{}
+	go func() {
+		defer wg.Done()
+		for range appending {
+			popped = append(popped, handler.PopNeedTriggerChangePoints(ctx)...)
+			poppedOnce <- struct{}{}
+		}
+	}()
+
+	readers := []func(){
+		func() {
+			for _, changePoint := range handler.checker().GetChangePoints() {
+				changePoint.Magnitude = 0
+			}
+		},
+		func() {
+			datas := handler.checker().Datas()
+			for i := range datas {
+				datas[i].Value = 0
+			}
+		},
+		func() {
+			handler.Snapshot()
+			handler.NewChangePoints()
+		},
+	}
+	for _, read := range readers {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					read()
+				}
+			}
+		}(read)
+	}
+	wg.Wait()
+
+	clock.Advance(time.Hour)
+	popped = append(popped, handler.PopNeedTriggerChangePoints(ctx)...)
+	if len(popped) != 1 || !popped[0].TimeValue.Time.Equal(start.Add(60*time.Minute)) {
+		t.Fatalf("want the step change point triggered once, got %+v", popped)
+	}
+
+	// the copies modified by the readers don't change the checker
+	datas := handler.checker().Datas()
+	if datas[len(datas)-1] != timeSeries.Values[pointCnt-1] {
+		t.Errorf("the checker datas are modified")
+	}
+	if duration := handler.checker().DataDuration(); duration > preSmoothDuration {
+		t.Errorf("want the checker rebalanced, data duration %v", duration)
+	}
+}
+
+// gatherValue return the counter or gauge value of the series, 0 if not found
+func gatherValue(t *testing.T, registry *prometheus.Registry, name, series string) float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "series" && label.GetValue() == series {
+					return metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestBocdHandlerMetrics(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	registry := prometheus.NewRegistry()
+	bocdMetrics, err := metrics.NewBocdMetrics(registry)
+	if err != nil {
+		t.Fatalf("new metrics failed: %v", err)
+	}
+	clock := utils.NewManualClock(start)
+	handler, ok := NewBocdHandler(ctx, "key", WithClock(clock), WithMetrics(bocdMetrics),
+		WithBocdConfig(&BocdConfig{Varx: 1, Mean0: 0, Hazard: hazard()}))
+	if !ok {
+		t.Fatalf("new handler failed")
+	}
+
+	// the change point is within the max traceback duration when popped
+	timeSeries := stepTimeSeries(start, 120, []int{110}, []float64{10})
+	clock.Set(timeSeries.Values[len(timeSeries.Values)-1].Time)
+	handler.AppendTimeSeriesData(ctx, timeSeries)
+	triggered := handler.PopNeedTriggerChangePoints(ctx)
+
+	if got := gatherValue(t, registry, "bocd_points_processed_total", "key"); got != 120 {
+		t.Errorf("got %v points processed, expected 120", got)
+	}
+	found := len(handler.checker().GetChangePoints())
+	if got := gatherValue(t, registry, "bocd_change_points_found_total", "key"); found == 0 || got != float64(found) {
+		t.Errorf("got %v change points found, expected %v", got, found)
+	}
+	if got := gatherValue(t, registry, "bocd_change_points_triggered_total", "key"); len(triggered) == 0 ||
+		got != float64(len(triggered)) {
+		t.Errorf("got %v change points triggered, expected %v", got, len(triggered))
+	}
+	if got := gatherValue(t, registry, "bocd_max_run_length", "key"); got != float64(handler.checker().MaxRunLength()) {
+		t.Errorf("got max run length %v, expected %v", got, handler.checker().MaxRunLength())
+	}
+}
@@ -0,0 +1,373 @@
+package bocd
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+type managedHandler struct {
+	key            string
+	handler        *BocdHandler
+	lastActiveTime time.Time // guarded by the manager lock
+
+	// mu is held when using the handler, so the handler won't be evicted in the middle,
+	// the evicted handler is not used any more, get the restored one from the manager
+	mu      sync.Mutex
+	evicted bool
+}
+
+// BocdManager manage the handlers of many time series, the handler is created lazily
+// when the time series first appear, and evicted when it's idle too long or too many series
+type BocdManager struct {
+	mu       sync.Mutex
+	handlers map[string]*list.Element // key -> element of lru, the value is *managedHandler
+	lru      *list.List               // the front is the most recently used
+
+	workerCnt      int
+	idleTTL        time.Duration
+	maxSeriesCnt   int
+	snapshotStore  HandlerSnapshotStore
+	handlerOptions []BocdHandlerOption
+	clock          utils.Clock
+}
+
+type BocdManagerOption func(*BocdManager)
+
+// WithWorkerCount set the max goroutines to process the time series in parallel
+func WithWorkerCount(workerCnt int) BocdManagerOption {
+	return func(m *BocdManager) {
+		if workerCnt > 0 {
+			m.workerCnt = workerCnt
+		}
+	}
+}
+
+// WithIdleTTL evict the handler if no data appended in ttl, 0 means never
+func WithIdleTTL(ttl time.Duration) BocdManagerOption {
+	return func(m *BocdManager) {
+		m.idleTTL = ttl
+	}
+}
+
+// WithMaxSeriesCount evict the least recently used handlers if too many series, 0 means no limit
+func WithMaxSeriesCount(maxSeriesCnt int) BocdManagerOption {
+	return func(m *BocdManager) {
+		m.maxSeriesCnt = maxSeriesCnt
+	}
+}
+
+// WithSnapshotStore save the snapshot when evict a handler, and restore it when the series come back
+func WithSnapshotStore(store HandlerSnapshotStore) BocdManagerOption {
+	return func(m *BocdManager) {
+		m.snapshotStore = store
+	}
+}
+
+// WithHandlerOptions set the options used to create every handler
+func WithHandlerOptions(opts ...BocdHandlerOption) BocdManagerOption {
+	return func(m *BocdManager) {
+		m.handlerOptions = append(m.handlerOptions, opts...)
+	}
+}
+
+// WithManagerClock set the clock used by the manager and all the handlers
+func WithManagerClock(clock utils.Clock) BocdManagerOption {
+	return func(m *BocdManager) {
+		if clock != nil {
+			m.clock = clock
+			m.handlerOptions = append(m.handlerOptions, WithClock(clock))
+		}
+	}
+}
+
+func NewBocdManager(opts ...BocdManagerOption) *BocdManager {
+	manager := &BocdManager{
+		handlers:  map[string]*list.Element{},
+		lru:       list.New(),
+		workerCnt: 8,
+		idleTTL:   24 * time.Hour,
+		clock:     utils.NewRealClock(),
+	}
+	for _, opt := range opts {
+		opt(manager)
+	}
+	return manager
+}
+
+// Process route each time series to its handler by labels, the series are processed in parallel,
+// the series with the same labels are merged
+func (m *BocdManager) Process(ctx context.Context, timeSeriesList []*model.TimeSeries) {
+	logger := utils.GetLogger(ctx)
+
+	keys := []string{}
+	seriesByKey := map[string]*model.TimeSeries{}
+	for _, timeSeries := range timeSeriesList {
+		if timeSeries.IsEmpty() {
+			continue
+		}
+		key := timeSeries.Key()
+		if merged, ok := seriesByKey[key]; ok {
+			merged.Values = append(merged.Values, timeSeries.Values...)
+			continue
+		}
+		keys = append(keys, key)
+		seriesByKey[key] = &model.TimeSeries{
+			Labels: timeSeries.Labels,
+			Values: append([]model.TimeValue{}, timeSeries.Values...),
+		}
+	}
+	// the merged batches may be out of order
+	for _, timeSeries := range seriesByKey {
+		sort.SliceStable(timeSeries.Values, func(i, j int) bool {
+			return timeSeries.Values[i].Before(timeSeries.Values[j])
+		})
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < IntMin(m.workerCnt, len(keys)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				m.processTimeSeries(ctx, key, seriesByKey[key])
+			}
+		}()
+	}
+	for _, key := range keys {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	logger.Info("bocd manager process finish", zap.Int("seriesCnt", len(keys)), zap.Int("handlerCnt", m.Len()))
+}
+
+func (m *BocdManager) processTimeSeries(ctx context.Context, key string, timeSeries *model.TimeSeries) {
+	logger := utils.GetLogger(ctx)
+
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("process time series recover panic error!", zap.Any("err", err),
+				zap.String("panic info", utils.GetPanicInfo()), zap.String("key", key))
+		}
+	}()
+
+	// the handler may be evicted after got, then get the restored one
+	for {
+		entry, ok := m.getOrCreate(ctx, key)
+		if !ok {
+			return
+		}
+
+		entry.mu.Lock()
+		if !entry.evicted {
+			entry.handler.AppendTimeSeriesData(ctx, timeSeries)
+		}
+		evicted := entry.evicted
+		entry.mu.Unlock()
+		if !evicted {
+			return
+		}
+	}
+}
+
+// getOrCreate the handler is created without lock, because getting statistics data may be slow
+func (m *BocdManager) getOrCreate(ctx context.Context, key string) (*managedHandler, bool) {
+	logger := utils.GetLogger(ctx)
+
+	if entry, ok := m.touch(key); ok {
+		return entry, true
+	}
+
+	handler, ok := m.restoreHandler(ctx, key)
+	if !ok {
+		handler, ok = NewBocdHandler(ctx, key, m.handlerOptions...)
+	}
+	if !ok {
+		logger.Error("create bocd handler failed", zap.String("key", key))
+		return nil, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// other goroutine may create the handler at the same time
+	if element, ok := m.handlers[key]; ok {
+		m.lru.MoveToFront(element)
+		entry := element.Value.(*managedHandler)
+		entry.lastActiveTime = m.clock.Now()
+		return entry, true
+	}
+	entry := &managedHandler{
+		key:            key,
+		handler:        handler,
+		lastActiveTime: m.clock.Now(),
+	}
+	m.handlers[key] = m.lru.PushFront(entry)
+	return entry, true
+}
+
+func (m *BocdManager) touch(key string) (*managedHandler, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.handlers[key]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(element)
+	entry := element.Value.(*managedHandler)
+	entry.lastActiveTime = m.clock.Now()
+	return entry, true
+}
+
+func (m *BocdManager) restoreHandler(ctx context.Context, key string) (*BocdHandler, bool) {
+	logger := utils.GetLogger(ctx)
+
+	if m.snapshotStore == nil {
+		return nil, false
+	}
+	snapshot, err := m.snapshotStore.Load(ctx, key)
+	if err != nil {
+		logger.Error("load handler snapshot failed", zap.Error(err), zap.String("key", key))
+		return nil, false
+	}
+	if snapshot == nil {
+		return nil, false
+	}
+	return RestoreBocdHandler(ctx, snapshot, m.handlerOptions...)
+}
+
+// Evict remove the handlers idle longer than ttl, then remove the least recently used handlers
+// if there are too many series. the snapshot is saved if snapshot store is set.
+// call it after PopNeedTriggerChangePoints, the untriggered change points of the evicted handler
+// will only be triggered after the series come back and restored from the snapshot
+func (m *BocdManager) Evict(ctx context.Context) int {
+	logger := utils.GetLogger(ctx)
+
+	evictCnt := 0
+	for _, candidate := range m.evictCandidates() {
+		if m.evict(ctx, candidate) {
+			evictCnt++
+		}
+	}
+
+	if evictCnt > 0 {
+		logger.Info("evict bocd handlers", zap.Int("evictCnt", evictCnt))
+	}
+	return evictCnt
+}
+
+type evictCandidate struct {
+	entry          *managedHandler
+	lastActiveTime time.Time
+}
+
+// evictCandidates find the handlers need evict from the least recently used
+func (m *BocdManager) evictCandidates() []evictCandidate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := []evictCandidate{}
+	now := m.clock.Now()
+	for element := m.lru.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*managedHandler)
+
+		idle := m.idleTTL > 0 && now.Sub(entry.lastActiveTime) > m.idleTTL
+		overflow := m.maxSeriesCnt > 0 && m.lru.Len()-len(res) > m.maxSeriesCnt
+		// the lru list is ordered by active time, so no more handler need evict
+		if !idle && !overflow {
+			break
+		}
+		res = append(res, evictCandidate{entry: entry, lastActiveTime: entry.lastActiveTime})
+	}
+	return res
+}
+
+// evict hold the entry lock across the snapshot and delete, so no data is appended to the handler
+// after the snapshot, and the series coming back during the evict wait and restore from the snapshot
+func (m *BocdManager) evict(ctx context.Context, candidate evictCandidate) bool {
+	logger := utils.GetLogger(ctx)
+
+	entry := candidate.entry
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	// the handler used after it's chosen is kept
+	m.mu.Lock()
+	element, ok := m.handlers[entry.key]
+	active := !ok || element.Value != entry || entry.lastActiveTime.After(candidate.lastActiveTime)
+	m.mu.Unlock()
+	if active {
+		return false
+	}
+
+	if m.snapshotStore != nil {
+		if err := m.snapshotStore.Save(ctx, entry.handler.Snapshot()); err != nil {
+			logger.Error("save handler snapshot failed", zap.Error(err), zap.String("key", entry.key))
+		}
+	}
+
+	m.mu.Lock()
+	m.lru.Remove(element)
+	delete(m.handlers, entry.key)
+	m.mu.Unlock()
+
+	entry.evicted = true
+	entry.handler.metrics.DeleteSeries(entry.key)
+	return true
+}
+
+// PopNeedTriggerChangePoints pop the need trigger change points of all the series, the key is the series key
+func (m *BocdManager) PopNeedTriggerChangePoints(ctx context.Context) map[string][]*model.ChangePoint {
+	res := map[string][]*model.ChangePoint{}
+	for _, entry := range m.entries() {
+		entry.mu.Lock()
+		var changePoints []*model.ChangePoint
+		if !entry.evicted {
+			changePoints = entry.handler.PopNeedTriggerChangePoints(ctx)
+		}
+		entry.mu.Unlock()
+
+		if len(changePoints) > 0 {
+			res[entry.key] = changePoints
+		}
+	}
+	return res
+}
+
+func (m *BocdManager) entries() []*managedHandler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]*managedHandler, 0, m.lru.Len())
+	for element := m.lru.Front(); element != nil; element = element.Next() {
+		res = append(res, element.Value.(*managedHandler))
+	}
+	return res
+}
+
+// GetHandler return the handler of the key
+func (m *BocdManager) GetHandler(key string) (*BocdHandler, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.handlers[key]
+	if !ok {
+		return nil, false
+	}
+	return element.Value.(*managedHandler).handler, true
+}
+
+func (m *BocdManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
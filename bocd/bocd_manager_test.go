@@ -0,0 +1,181 @@
+package bocd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+)
+
+func newTestManager(clock *utils.ManualClock, opts ...BocdManagerOption) *BocdManager {
+	opts = append(opts, WithManagerClock(clock),
+		WithHandlerOptions(WithBocdConfig(&BocdConfig{Varx: 1, Mean0: 0, Hazard: hazard()})))
+	return NewBocdManager(opts...)
+}
+
+func minutePoints(labels map[string]string, start time.Time, minutes ...int) *model.TimeSeries {
+	res := &model.TimeSeries{Labels: labels}
+	for _, minute := range minutes {
+		res.Values = append(res.Values, model.TimeValue{Time: start.Add(time.Duration(minute) * time.Minute), Value: 1})
+	}
+	return res
+}
+
+func TestBocdManagerProcessMergedBatches(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start.Add(10 * time.Minute))
+	manager := newTestManager(clock)
+
+	labels := map[string]string{"job": "api"}
+	manager.Process(ctx, []*model.TimeSeries{
+		minutePoints(labels, start, 3, 4),
+		minutePoints(map[string]string{"job": "db"}, start, 0),
+		minutePoints(labels, start, 1, 2),
+	})
+
+	if manager.Len() != 2 {
+		t.Fatalf("want 2 handlers, got %d", manager.Len())
+	}
+	handler, ok := manager.GetHandler((&model.TimeSeries{Labels: labels}).Key())
+	if !ok {
+		t.Fatalf("handler not found")
+	}
+	datas := handler.checker().Datas()
+	if len(datas) != 4 {
+		t.Fatalf("want the merged 4 points, got %d", len(datas))
+	}
+	for i, timeValue := range datas {
+		if !timeValue.Time.Equal(start.Add(time.Duration(i+1) * time.Minute)) {
+			t.Errorf("want the points in time order, got %v at %d", timeValue.Time, i)
+		}
+	}
+	if !handler.lastAppendDataTime.Equal(start.Add(4 * time.Minute)) {
+		t.Errorf("want the last append time the max time, got %v", handler.lastAppendDataTime)
+	}
+}
+
+func TestBocdManagerEvictAndRestore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+	manager := newTestManager(clock, WithIdleTTL(time.Hour), WithSnapshotStore(NewMemoryHandlerSnapshotStore()))
+
+	labels := map[string]string{"job": "api"}
+	manager.Process(ctx, []*model.TimeSeries{minutePoints(labels, start, 0, 1, 2)})
+
+	clock.Advance(30 * time.Minute)
+	if cnt := manager.Evict(ctx); cnt != 0 {
+		t.Fatalf("the active handler should be kept, evicted %d", cnt)
+	}
+	clock.Advance(time.Hour)
+	if cnt := manager.Evict(ctx); cnt != 1 || manager.Len() != 0 {
+		t.Fatalf("want the idle handler evicted, evicted %d left %d", cnt, manager.Len())
+	}
+
+	manager.Process(ctx, []*model.TimeSeries{minutePoints(labels, start, 3)})
+	handler, ok := manager.GetHandler((&model.TimeSeries{Labels: labels}).Key())
+	if !ok || handler.checker().DataSize() != 4 {
+		t.Fatalf("want the handler restored with 4 points")
+	}
+}
+
+// TestBocdManagerEvictRace evict the handler again and again while appending,
+// no point is lost because the appends wait the snapshot or go to the restored handler
+func TestBocdManagerEvictRace(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+	// every handler is idle once the clock advance
+	manager := newTestManager(clock, WithIdleTTL(time.Nanosecond), WithSnapshotStore(NewMemoryHandlerSnapshotStore()))
+
+	labels := map[string]string{"job": "api"}
+	const pointCnt = 100
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < pointCnt; i++ {
+			manager.Process(ctx, []*model.TimeSeries{minutePoints(labels, start, i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			clock.Advance(time.Millisecond)
+			manager.Evict(ctx)
+			manager.PopNeedTriggerChangePoints(ctx)
+		}
+	}()
+	wg.Wait()
+
+	manager.Process(ctx, []*model.TimeSeries{minutePoints(labels, start, pointCnt)})
+	handler, ok := manager.GetHandler((&model.TimeSeries{Labels: labels}).Key())
+	if !ok || handler.checker().DataSize() != pointCnt+1 {
+		t.Fatalf("want all the %d points kept, got %d", pointCnt+1, handler.checker().DataSize())
+	}
+}
+
+// blockingSnapshotStore block the save until released
+type blockingSnapshotStore struct {
+	*MemoryHandlerSnapshotStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSnapshotStore) Save(ctx context.Context, snapshot *BocdHandlerSnapshot) error {
+	close(s.saving)
+	<-s.release
+	return s.MemoryHandlerSnapshotStore.Save(ctx, snapshot)
+}
+
+func TestBocdManagerAppendDuringEvict(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+	store := &blockingSnapshotStore{
+		MemoryHandlerSnapshotStore: NewMemoryHandlerSnapshotStore(),
+		saving:                     make(chan struct{}),
+		release:                    make(chan struct{}),
+	}
+	manager := newTestManager(clock, WithIdleTTL(time.Hour), WithSnapshotStore(store))
+
+	labels := map[string]string{"job": "api"}
+	manager.Process(ctx, []*model.TimeSeries{minutePoints(labels, start, 0, 1, 2)})
+	clock.Advance(2 * time.Hour)
+
+	evicted := make(chan int)
+	go func() {
+		evicted <- manager.Evict(ctx)
+	}()
+	<-store.saving
+
+	// the point come during the snapshot wait for it, then go to the restored handler
+	processed := make(chan struct{})
+	go func() {
+		manager.Process(ctx, []*model.TimeSeries{minutePoints(labels, start, 3)})
+		close(processed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(store.release)
+
+	if cnt := <-evicted; cnt != 1 {
+		t.Fatalf("want 1 handler evicted, got %d", cnt)
+	}
+	<-processed
+
+	handler, ok := manager.GetHandler((&model.TimeSeries{Labels: labels}).Key())
+	if !ok || handler.checker().DataSize() != 4 {
+		t.Fatalf("want the restored handler has 4 points")
+	}
+}
@@ -0,0 +1,425 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/mat"
+)
+
+// normal wishart params of one run length
+type normalWishartParams struct {
+	mean  []float64
+	kappa float64
+	nu    float64
+	psi   *mat.SymDense // scale matrix
+}
+
+// MultiBocdOnlineChecker use the multivariate gaussian with unknown mean and covariance,
+// the conjugate prior is normal wishart, so the posterior predictive is multivariate student t
+type MultiBocdOnlineChecker struct {
+	dim   int
+	prior *normalWishartParams
+
+	datas           []model.MultiTimeValue
+	runLengths      []int                  // the kept run lengths in ascending order
+	params          []*normalWishartParams // params of each kept run length
+	lastLogRunProbs []float64              // normalized log probs of each kept run length
+	runLenProb      []float64              // run length probs of the last point
+
+	changePoints        []*model.MultiChangePoint
+	lastChangePointLoc  int // index of the last change point in datas
+	lastChangePointSeen bool
+
+	hazard                  float64       // change point hazard of one expected interval
+	expectedInterval        time.Duration // expected interval between two points
+	observeDuration         time.Duration // only check the change points in the observe duration
+	minRunLengthProbability float64       // the run length with smaller probability is pruned
+	maxRunLengthCnt         int           // the least probable run lengths are pruned if more than it
+}
+
+type MultiBocdCheckerOption func(*MultiBocdOnlineChecker)
+
+// WithMultiHazard set the change point hazard of one expected interval, default is 0.002
+func WithMultiHazard(hazard float64) MultiBocdCheckerOption {
+	return func(b *MultiBocdOnlineChecker) {
+		if hazard > 0 && hazard < 1 {
+			b.hazard = hazard
+		}
+	}
+}
+
+// WithMultiExpectedInterval set the expected interval between two points, default is 1 minute,
+// the hazard is scaled by the real elapsed time like WithExpectedInterval
+func WithMultiExpectedInterval(interval time.Duration) MultiBocdCheckerOption {
+	return func(b *MultiBocdOnlineChecker) {
+		if interval > 0 {
+			b.expectedInterval = interval
+		}
+	}
+}
+
+// WithMultiObserveDuration set the time window to look back for change points
+func WithMultiObserveDuration(observeDuration time.Duration) MultiBocdCheckerOption {
+	return func(b *MultiBocdOnlineChecker) {
+		if observeDuration > 0 {
+			b.observeDuration = observeDuration
+		}
+	}
+}
+
+// WithMinRunLengthProbability the run lengths whose probability is smaller are pruned, every run length cost
+// a cholesky for each point, default is 1e-6
+func WithMinRunLengthProbability(probability float64) MultiBocdCheckerOption {
+	return func(b *MultiBocdOnlineChecker) {
+		if probability > 0 && probability < 1 {
+			b.minRunLengthProbability = probability
+		}
+	}
+}
+
+// WithMaxRunLengthCount keep at most maxRunLengthCnt run lengths, the least probable ones are pruned,
+// the run lengths of a stable series are nearly the same probable so the probability pruning is not enough,
+// default is 100
+func WithMaxRunLengthCount(maxRunLengthCnt int) MultiBocdCheckerOption {
+	return func(b *MultiBocdOnlineChecker) {
+		if maxRunLengthCnt > 1 {
+			b.maxRunLengthCnt = maxRunLengthCnt
+		}
+	}
+}
+
+// NewMultiBocdOnlineChecker mean0 and varx are the prior mean and variance of each dimension
+func NewMultiBocdOnlineChecker(mean0, varx []float64, opts ...MultiBocdCheckerOption) (*MultiBocdOnlineChecker,
+	error) {
+	dim := len(mean0)
+	if dim == 0 || dim != len(varx) {
+		return nil, common.ErrorInvalidValue
+	}
+
+	psi := mat.NewSymDense(dim, nil)
+	for i, v := range varx {
+		if v <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, common.ErrorInvalidValue
+		}
+		psi.SetSym(i, i, v)
+	}
+
+	prior := &normalWishartParams{
+		mean:  append([]float64{}, mean0...),
+		kappa: 1,
+		// nu = dim + 2 make the prior expectation of covariance equal psi
+		nu:  float64(dim + 2),
+		psi: psi,
+	}
+
+	checker := &MultiBocdOnlineChecker{
+		dim:   dim,
+		prior: prior,
+
+		changePoints: []*model.MultiChangePoint{},
+
+		hazard:                  hazard(),
+		expectedInterval:        getExpectedInterval(),
+		observeDuration:         getObserveDuration(),
+		minRunLengthProbability: getMinRunLengthProbability(),
+		maxRunLengthCnt:         getMaxRunLengthCount(),
+	}
+	for _, opt := range opts {
+		opt(checker)
+	}
+	checker.reset()
+	return checker, nil
+}
+
+// reset clear the datas and the run lengths, the change points are kept
+func (b *MultiBocdOnlineChecker) reset() {
+	b.datas = []model.MultiTimeValue{}
+	b.runLengths = []int{0}
+	b.params = []*normalWishartParams{b.prior}
+	b.lastLogRunProbs = []float64{0}
+	b.runLenProb = []float64{1}
+	b.lastChangePointLoc, b.lastChangePointSeen = 0, false
+}
+
+func (b *MultiBocdOnlineChecker) AppendPoint(ctx context.Context,
+	timeValue model.MultiTimeValue) (*model.MultiChangePoint, bool) {
+	if len(timeValue.Values) != b.dim {
+		logger := utils.GetLogger(ctx)
+		logger.Error("dimension not match", zap.Int("dim", b.dim), zap.Int("valueDim", len(timeValue.Values)))
+		return nil, false
+	}
+	if b.needRebalance(timeValue) {
+		b.rebalance(timeValue)
+		logger := utils.GetLogger(ctx)
+		logger.Info("rebalance multivariate checker", zap.Int("dataSize", len(b.datas)))
+	}
+	return b.appendPoint(timeValue)
+}
+
+// needRebalance is the same as the needRebalance of BocdHandler, the small run lengths are pruned but the
+// long run and the datas grow without a change point
+func (b *MultiBocdOnlineChecker) needRebalance(timeValue model.MultiTimeValue) bool {
+	if len(b.datas) == 0 {
+		return false
+	}
+	if b.lastChangePointSeen && timeValue.Time.Sub(b.datas[b.lastChangePointLoc].Time) > preSmoothDuration {
+		return true
+	}
+	dataDuration := b.datas[len(b.datas)-1].Time.Sub(b.datas[0].Time)
+	if !b.lastChangePointSeen && dataDuration > preSmoothDuration {
+		return true
+	}
+	return dataDuration > maxDataDuration
+}
+
+// rebalance replay the datas in reserveDuration before the point,
+// the change points found again in the replay are already reported
+func (b *MultiBocdOnlineChecker) rebalance(timeValue model.MultiTimeValue) {
+	reserveStartTime := timeValue.Time.Add(-1 * reserveDuration)
+	reserveIndex := sort.Search(len(b.datas), func(i int) bool {
+		return b.datas[i].Time.After(reserveStartTime)
+	})
+	datas, changePoints := b.datas[reserveIndex:], b.changePoints
+
+	b.reset()
+	b.changePoints = []*model.MultiChangePoint{}
+	for _, reserveTimeValue := range datas {
+		b.appendPoint(reserveTimeValue)
+	}
+	b.changePoints = changePoints
+}
+
+func (b *MultiBocdOnlineChecker) appendPoint(timeValue model.MultiTimeValue) (*model.MultiChangePoint, bool) {
+	stepHazard := b.hazard
+	if len(b.datas) > 0 {
+		stepHazard = scaleHazard(b.hazard, timeValue.Time.Sub(b.datas[len(b.datas)-1].Time), b.expectedInterval)
+	}
+	b.datas = append(b.datas, timeValue)
+
+	// evaluate predictive probabilities under each run length
+	logPreProbs := make([]float64, len(b.params))
+	for i, param := range b.params {
+		logPreProbs[i] = param.logPredictiveProb(timeValue.Values)
+	}
+
+	logh, log1mh := math.Log(stepHazard), math.Log(1-stepHazard)
+
+	logGrowthProbs := make([]float64, len(logPreProbs))
+	changePointData := make([]float64, len(logPreProbs))
+	for i := range logPreProbs {
+		logGrowthProbs[i] = logPreProbs[i] + b.lastLogRunProbs[i] + log1mh
+		changePointData[i] = logPreProbs[i] + b.lastLogRunProbs[i] + logh
+	}
+	logRunProbs := NormalizeData(append([]float64{LogSumExp(changePointData)}, logGrowthProbs...))
+
+	// update params and prune the small run lengths, the new run use the prior params
+	minLogProb := math.Log(b.minRunLengthProbability)
+	if len(logRunProbs) > b.maxRunLengthCnt {
+		sorted := append([]float64{}, logRunProbs[1:]...)
+		sort.Float64s(sorted)
+		minLogProb = math.Max(minLogProb, sorted[len(sorted)-b.maxRunLengthCnt+1])
+	}
+	runLengths := []int{0}
+	params := []*normalWishartParams{b.prior}
+	lastLogRunProbs := []float64{logRunProbs[0]}
+	for i, param := range b.params {
+		if logRunProbs[i+1] < minLogProb || len(runLengths) >= b.maxRunLengthCnt {
+			continue
+		}
+		runLengths = append(runLengths, b.runLengths[i]+1)
+		params = append(params, param.update(timeValue.Values))
+		lastLogRunProbs = append(lastLogRunProbs, logRunProbs[i+1])
+	}
+	b.runLengths, b.params, b.lastLogRunProbs = runLengths, params, lastLogRunProbs
+	b.runLenProb = ListExp(lastLogRunProbs)
+
+	findChangePoint, changePoint := b.checkChangePoints()
+	return changePoint, findChangePoint
+}
+
+func (b *MultiBocdOnlineChecker) checkChangePoints() (bool, *model.MultiChangePoint) {
+	threshold := getChangePointThreshold()
+	t := len(b.datas)
+	lastTime := b.datas[t-1].Time
+
+	for i, runLength := range b.runLengths {
+		changePointLoc := t - runLength
+		// run length 0 means the new run begins after the current point
+		if changePointLoc < t && lastTime.Sub(b.datas[changePointLoc].Time) > b.observeDuration {
+			break
+		}
+		if b.runLenProb[i] < threshold {
+			continue
+		}
+		if changePointLoc <= 0 {
+			break
+		}
+		if changePointLoc >= t {
+			continue
+		}
+		// if time point equal last change point, it's already found
+		lastChangePoint, ok := b.LastChangePoint()
+		if ok && lastChangePoint.TimeValue.Time.Equal(b.datas[changePointLoc].Time) {
+			break
+		}
+
+		preStart := 0
+		if b.lastChangePointSeen && b.lastChangePointLoc < changePointLoc {
+			preStart = b.lastChangePointLoc
+		}
+		changePoint := b.newChangePoint(preStart, changePointLoc, runLength, b.runLenProb[i])
+		b.changePoints = append(b.changePoints, changePoint)
+		b.lastChangePointLoc, b.lastChangePointSeen = changePointLoc, true
+		return true, changePoint
+	}
+	return false, nil
+}
+
+// newChangePoint calculate the mean shift of each dimension,
+// the contribution of a dimension is the square of the standardized shift
+func (b *MultiBocdOnlineChecker) newChangePoint(preStart, changePointLoc, runLength int,
+	probability float64) *model.MultiChangePoint {
+	preMeans, preVars := b.meanVariances(preStart, changePointLoc)
+	postMeans, _ := b.meanVariances(changePointLoc, len(b.datas))
+
+	changePoint := &model.MultiChangePoint{
+		TimeValue:        b.datas[changePointLoc],
+		Probability:      probability,
+		RunLength:        runLength,
+		PreMeans:         preMeans,
+		PostMeans:        postMeans,
+		ChangePointTypes: make([]model.ChangePointType, b.dim),
+		Contributions:    make([]float64, b.dim),
+		TopDimensions:    make([]int, b.dim),
+	}
+
+	total := 0.0
+	for i := 0; i < b.dim; i++ {
+		variance := preVars[i]
+		// too few points to estimate, use the prior variance
+		if changePointLoc-preStart < 2 || variance <= 0 {
+			variance = b.prior.psi.At(i, i)
+		}
+		diff := postMeans[i] - preMeans[i]
+		changePoint.Contributions[i] = diff * diff / variance
+		total += changePoint.Contributions[i]
+
+		if diff > 0 {
+			changePoint.ChangePointTypes[i] = model.IncreaseChangePoint
+		} else {
+			changePoint.ChangePointTypes[i] = model.DecreaseChangePoint
+		}
+		changePoint.TopDimensions[i] = i
+	}
+
+	if total > 0 {
+		for i := range changePoint.Contributions {
+			changePoint.Contributions[i] /= total
+		}
+	}
+	sort.SliceStable(changePoint.TopDimensions, func(i, j int) bool {
+		return changePoint.Contributions[changePoint.TopDimensions[i]] >
+			changePoint.Contributions[changePoint.TopDimensions[j]]
+	})
+
+	return changePoint
+}
+
+func (b *MultiBocdOnlineChecker) meanVariances(start, end int) ([]float64, []float64) {
+	means, vars := make([]float64, b.dim), make([]float64, b.dim)
+	n := float64(end - start)
+	if n <= 0 {
+		return means, vars
+	}
+	for _, timeValue := range b.datas[start:end] {
+		for i, v := range timeValue.Values {
+			means[i] += v / n
+		}
+	}
+	if n < 2 {
+		return means, vars
+	}
+	for _, timeValue := range b.datas[start:end] {
+		for i, v := range timeValue.Values {
+			vars[i] += (v - means[i]) * (v - means[i]) / (n - 1)
+		}
+	}
+	return means, vars
+}
+
+func (p *normalWishartParams) update(x []float64) *normalWishartParams {
+	dim := len(x)
+	diff := make([]float64, dim)
+	mean := make([]float64, dim)
+	for i := range x {
+		diff[i] = x[i] - p.mean[i]
+		mean[i] = (p.kappa*p.mean[i] + x[i]) / (p.kappa + 1)
+	}
+
+	psi := mat.NewSymDense(dim, nil)
+	psi.SymRankOne(p.psi, p.kappa/(p.kappa+1), mat.NewVecDense(dim, diff))
+
+	return &normalWishartParams{
+		mean:  mean,
+		kappa: p.kappa + 1,
+		nu:    p.nu + 1,
+		psi:   psi,
+	}
+}
+
+// logPredictiveProb is the log pdf of the multivariate student t posterior predictive
+func (p *normalWishartParams) logPredictiveProb(x []float64) float64 {
+	dim := float64(len(x))
+	df := p.nu - dim + 1
+
+	shape := mat.NewSymDense(len(x), nil)
+	shape.ScaleSym((p.kappa+1)/(p.kappa*df), p.psi)
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(shape); !ok {
+		return math.Inf(-1)
+	}
+
+	diff := make([]float64, len(x))
+	for i := range x {
+		diff[i] = x[i] - p.mean[i]
+	}
+	diffVec := mat.NewVecDense(len(x), diff)
+	var solved mat.VecDense
+	if err := chol.SolveVecTo(&solved, diffVec); err != nil {
+		return math.Inf(-1)
+	}
+	maha := mat.Dot(diffVec, &solved)
+
+	lgammaA, _ := math.Lgamma((df + dim) / 2)
+	lgammaB, _ := math.Lgamma(df / 2)
+
+	return lgammaA - lgammaB - dim/2*math.Log(df*math.Pi) - chol.LogDet()/2 -
+		(df+dim)/2*math.Log1p(maha/df)
+}
+
+func (b *MultiBocdOnlineChecker) Datas() []model.MultiTimeValue {
+	return b.datas
+}
+
+func (b *MultiBocdOnlineChecker) DataSize() int {
+	return len(b.datas)
+}
+
+func (b *MultiBocdOnlineChecker) GetChangePoints() []*model.MultiChangePoint {
+	return b.changePoints
+}
+
+func (b *MultiBocdOnlineChecker) LastChangePoint() (*model.MultiChangePoint, bool) {
+	if len(b.changePoints) > 0 {
+		return b.changePoints[len(b.changePoints)-1], true
+	}
+	return nil, false
+}
@@ -0,0 +1,160 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func TestMultiBocdOnlineChecker(t *testing.T) {
+	ctx := context.Background()
+	checker, err := NewMultiBocdOnlineChecker([]float64{0, 0}, []float64{1, 1})
+	if err != nil {
+		t.Fatalf("new checker failed: %v", err)
+	}
+	if _, ok := checker.AppendPoint(ctx, model.MultiTimeValue{Values: []float64{1}}); ok {
+		t.Fatalf("the point with wrong dimension should be skipped")
+	}
+
+	random := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 150; i++ {
+		values := []float64{random.NormFloat64(), random.NormFloat64()}
+		if i >= 80 {
+			values[1] += 8
+		}
+		checker.AppendPoint(ctx, model.MultiTimeValue{Time: start.Add(time.Duration(i) * time.Minute), Values: values})
+	}
+
+	changePoints := checker.GetChangePoints()
+	if len(changePoints) != 1 {
+		t.Fatalf("want 1 change point, got %d", len(changePoints))
+	}
+	changePoint := changePoints[0]
+	if !changePoint.TimeValue.Time.Equal(start.Add(80 * time.Minute)) {
+		t.Errorf("unexpected change point time %v", changePoint.TimeValue.Time)
+	}
+	if changePoint.TopDimensions[0] != 1 || changePoint.ChangePointTypes[1] != model.IncreaseChangePoint {
+		t.Errorf("want dimension 1 increase as the top, got %+v", changePoint)
+	}
+	if math.Abs(changePoint.Contributions[0]+changePoint.Contributions[1]-1) > 1e-9 ||
+		changePoint.Contributions[1] < 0.9 {
+		t.Errorf("unexpected contributions %v", changePoint.Contributions)
+	}
+}
+
+// TestMultiBocdOnlineCheckerJointShift the dimensions are correlated by a common factor, the shift is against the
+// correlation and weak in every dimension, the univariate checkers miss it
+func TestMultiBocdOnlineCheckerJointShift(t *testing.T) {
+	ctx := context.Background()
+	checker, err := NewMultiBocdOnlineChecker([]float64{0, 0, 0}, []float64{1, 1, 1})
+	if err != nil {
+		t.Fatalf("new checker failed: %v", err)
+	}
+	singleCheckers := []*BocdOnlineChecker{NewBocdOnlineChecker(1, 0), NewBocdOnlineChecker(1, 0),
+		NewBocdOnlineChecker(1, 0)}
+
+	random := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	shift := []float64{1.5, -0.8, 0.2}
+	for i := 0; i < 200; i++ {
+		factor := random.NormFloat64()
+		values := make([]float64, len(shift))
+		for j := range values {
+			// the variance of each dimension is about 1
+			values[j] = 0.9*factor + 0.43*random.NormFloat64()
+			if i >= 120 {
+				values[j] += shift[j]
+			}
+		}
+		timestamp := start.Add(time.Duration(i) * time.Minute)
+		checker.AppendPoint(ctx, model.MultiTimeValue{Time: timestamp, Values: values})
+		for j, singleChecker := range singleCheckers {
+			singleChecker.AppendPoint(ctx, model.TimeValue{Time: timestamp, Value: values[j]})
+		}
+	}
+
+	for j, singleChecker := range singleCheckers {
+		if changePoints := singleChecker.GetChangePoints(); len(changePoints) != 0 {
+			t.Errorf("dimension %v got %v change points, expected the univariate checker miss it", j,
+				len(changePoints))
+		}
+	}
+	changePoints := checker.GetChangePoints()
+	if len(changePoints) != 1 || !changePoints[0].TimeValue.Time.Equal(start.Add(120*time.Minute)) {
+		t.Fatalf("got change points %+v, expected one at minute 120", changePoints)
+	}
+	changePoint := changePoints[0]
+	for i, expected := range []int{0, 1, 2} {
+		if changePoint.TopDimensions[i] != expected {
+			t.Errorf("got top dimensions %v, expected [0 1 2]", changePoint.TopDimensions)
+			break
+		}
+	}
+	if changePoint.ChangePointTypes[0] != model.IncreaseChangePoint ||
+		changePoint.ChangePointTypes[1] != model.DecreaseChangePoint {
+		t.Errorf("got change point types %v", changePoint.ChangePointTypes)
+	}
+}
+
+func TestMultiBocdOnlineCheckerIrregular(t *testing.T) {
+	ctx := context.Background()
+	interval := 5 * time.Minute
+	checker, err := NewMultiBocdOnlineChecker([]float64{0, 0}, []float64{1, 1}, WithMultiExpectedInterval(interval),
+		WithMultiObserveDuration(5*interval))
+	if err != nil {
+		t.Fatalf("new checker failed: %v", err)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 150; i++ {
+		values := []float64{random.NormFloat64(), random.NormFloat64()}
+		if i >= 80 {
+			values[1] += 8
+		}
+		checker.AppendPoint(ctx, model.MultiTimeValue{Time: start.Add(time.Duration(i) * interval), Values: values})
+	}
+	// the same as the 1 minute series, the hazard is scaled and the observe window is by time
+	changePoints := checker.GetChangePoints()
+	if len(changePoints) != 1 || !changePoints[0].TimeValue.Time.Equal(start.Add(80*interval)) {
+		t.Fatalf("got change points %+v, expected one at the point 80", changePoints)
+	}
+
+	if hazard := scaleHazard(0.002, 2*time.Minute, time.Minute); math.Abs(hazard-(1-0.998*0.998)) > 1e-12 {
+		t.Errorf("got hazard %v of 2 intervals", hazard)
+	}
+}
+
+func TestMultiBocdOnlineCheckerBounded(t *testing.T) {
+	ctx := context.Background()
+	checker, err := NewMultiBocdOnlineChecker([]float64{0, 0}, []float64{1, 1})
+	if err != nil {
+		t.Fatalf("new checker failed: %v", err)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxRunLengthCnt := 0
+	for i := 0; i < 8*60; i++ {
+		values := []float64{random.NormFloat64(), random.NormFloat64()}
+		checker.AppendPoint(ctx, model.MultiTimeValue{Time: start.Add(time.Duration(i) * time.Minute), Values: values})
+		maxRunLengthCnt = max(maxRunLengthCnt, len(checker.runLengths))
+	}
+
+	// the small run lengths are pruned, the long run is kept
+	if maxRunLengthCnt > 100 || checker.runLengths[len(checker.runLengths)-1] != checker.DataSize() {
+		t.Errorf("got %v run lengths at most, the longest %v of %v datas", maxRunLengthCnt,
+			checker.runLengths[len(checker.runLengths)-1], checker.DataSize())
+	}
+	// rebalanced after 6 hours without change point, the datas in the last 3 hours are replayed
+	datas := checker.Datas()
+	if duration := datas[len(datas)-1].Time.Sub(datas[0].Time); duration > preSmoothDuration ||
+		checker.DataSize() > 5*60 {
+		t.Errorf("got %v datas of %v after rebalance", checker.DataSize(), duration)
+	}
+}
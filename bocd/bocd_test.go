@@ -0,0 +1,64 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func TestBocdOnlineCheckerChangePoint(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	checker := NewBocdOnlineChecker(1, 0)
+
+	var found *model.ChangePoint
+	for _, timeValue := range stepTimeSeries(start, 120, []int{60}, []float64{10}).Values {
+		if changePoint, ok := checker.AppendPoint(ctx, timeValue); ok {
+			if found != nil {
+				t.Fatalf("want only 1 change point, got another %+v", changePoint)
+			}
+			found = changePoint
+		}
+	}
+	if found == nil {
+		t.Fatalf("change point not found")
+	}
+
+	if !found.TimeValue.Time.Equal(start.Add(60*time.Minute)) || found.ChangePointType != model.IncreaseChangePoint {
+		t.Errorf("unexpected change point %+v", found)
+	}
+	if found.Probability < getChangePointThreshold() || found.DetectionDelay != found.DetectTime.Sub(found.TimeValue.Time) ||
+		found.DetectionDelay > getObserveDuration() {
+		t.Errorf("unexpected detection %+v", found)
+	}
+	if math.Abs(found.PreMean) > 0.5 || math.Abs(found.PostMean-10) > 1.5 ||
+		found.Magnitude != found.PostMean-found.PreMean {
+		t.Errorf("unexpected segment statistics %+v", found)
+	}
+	if checker.MaxRunLength() != 60 || len(checker.GetPredictionMeans()) != 120 {
+		t.Errorf("unexpected run length %d", checker.MaxRunLength())
+	}
+}
+
+func TestBocdOnlineCheckerChangePointType(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	checker := NewBocdOnlineChecker(1, 0)
+	// the segment mean increase, but the change point is lower than the point before it
+	for i, v := range []float64{0, 0, 9, 5, 5, 5} {
+		checker.datas = append(checker.datas, model.TimeValue{Time: start.Add(time.Duration(i) * time.Minute), Value: v})
+		checker.runLenProb = append(checker.runLenProb, []float64{1})
+	}
+
+	changePoint := checker.newChangePoint(3, 6, 0)
+	if changePoint.Magnitude <= 0 || changePoint.ChangePointType != model.DecreaseChangePoint {
+		t.Errorf("got change point type %v with magnitude %v, expected decrease by the point before it",
+			changePoint.ChangePointType, changePoint.Magnitude)
+	}
+	changePoint = checker.newChangePoint(2, 6, 0)
+	if changePoint.ChangePointType != model.IncreaseChangePoint {
+		t.Errorf("got change point type %v, expected increase", changePoint.ChangePointType)
+	}
+}
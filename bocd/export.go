@@ -0,0 +1,285 @@
+package bocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// RunLengthEntry is a non zero element of the run length posterior matrix
+type RunLengthEntry struct {
+	Step        int     `json:"step"`
+	RunLength   int     `json:"run_length"`
+	Probability float64 `json:"probability"`
+}
+
+// RunLengthPosterior is the run length posterior of the checker as a sparse matrix,
+// the row is the step (the index of Datas) and the column is the run length,
+// the probabilities smaller than MinProbability are dropped
+type RunLengthPosterior struct {
+	Datas               []model.TimeValue    `json:"datas"`
+	PredictionMeans     []float64            `json:"prediction_means"`
+	PredictionVariances []float64            `json:"prediction_variances"`
+	MaxRunLengths       []int                `json:"max_run_lengths"`
+	ChangePoints        []*model.ChangePoint `json:"change_points"`
+	Entries             []RunLengthEntry     `json:"entries"`
+	MinProbability      float64              `json:"min_probability"`
+}
+
+// ExportRunLengthPosterior export the posterior of all the cached datas,
+// minProbability <= 0 means keep all the elements
+func (b *BocdOnlineChecker) ExportRunLengthPosterior(minProbability float64) *RunLengthPosterior {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	res := &RunLengthPosterior{
+		Datas:               append([]model.TimeValue{}, b.datas...),
+		PredictionMeans:     append([]float64{}, b.pMeans...),
+		PredictionVariances: append([]float64{}, b.pVars...),
+		MaxRunLengths:       make([]int, 0, len(b.datas)),
+		ChangePoints:        make([]*model.ChangePoint, 0, len(b.changePoints)),
+		Entries:             []RunLengthEntry{},
+		MinProbability:      minProbability,
+	}
+	for _, changePoint := range b.changePoints {
+		copied := *changePoint
+		res.ChangePoints = append(res.ChangePoints, &copied)
+	}
+
+	// runLenProb[0] is the prior before the first point
+	for t := 1; t < len(b.runLenProb); t++ {
+		res.MaxRunLengths = append(res.MaxRunLengths, argMax(b.runLenProb[t]))
+		res.Entries = appendRunLengthEntries(res.Entries, b.runLenProb[t], t-1, minProbability)
+	}
+	return res
+}
+
+func appendRunLengthEntries(entries []RunLengthEntry, runLenProb []float64, step int,
+	minProbability float64) []RunLengthEntry {
+	for runLength, probability := range runLenProb {
+		if probability <= 0 || probability < minProbability {
+			continue
+		}
+		entries = append(entries, RunLengthEntry{
+			Step:        step,
+			RunLength:   runLength,
+			Probability: probability,
+		})
+	}
+	return entries
+}
+
+// Dense convert the sparse matrix back, res[step][runLength]
+func (p *RunLengthPosterior) Dense() [][]float64 {
+	res := make([][]float64, len(p.Datas))
+	for step := range res {
+		res[step] = make([]float64, step+2)
+	}
+	for _, entry := range p.Entries {
+		res[entry.Step][entry.RunLength] = entry.Probability
+	}
+	return res
+}
+
+func (p *RunLengthPosterior) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(p)
+}
+
+// HeatmapOptions is the layout of the rendered heatmap
+type HeatmapOptions struct {
+	CellWidth    int // width of one step, default 4
+	CellHeight   int // height of one run length, default 2
+	MaxRunLength int // run lengths larger than it are not drawn, default the max of the data
+	DataHeight   int // height of the data panel above the heatmap, default 120
+}
+
+func (o *HeatmapOptions) withDefault(posterior *RunLengthPosterior) HeatmapOptions {
+	res := HeatmapOptions{CellWidth: 4, CellHeight: 2, DataHeight: 120}
+	if o != nil {
+		res = *o
+	}
+	if res.CellWidth <= 0 {
+		res.CellWidth = 4
+	}
+	if res.CellHeight <= 0 {
+		res.CellHeight = 2
+	}
+	if res.DataHeight <= 0 {
+		res.DataHeight = 120
+	}
+	if res.MaxRunLength <= 0 {
+		for _, entry := range posterior.Entries {
+			res.MaxRunLength = IntMax(res.MaxRunLength, entry.RunLength)
+		}
+		res.MaxRunLength = IntMax(res.MaxRunLength, 1)
+	}
+	return res
+}
+
+// heatmapLayout map the data and the posterior to pixels, the data panel is on the top,
+// the heatmap is below it and the run length 0 is at the bottom
+type heatmapLayout struct {
+	HeatmapOptions
+	width, height  int
+	minValue, span float64
+}
+
+func newHeatmapLayout(posterior *RunLengthPosterior, opts *HeatmapOptions) *heatmapLayout {
+	layout := &heatmapLayout{HeatmapOptions: opts.withDefault(posterior)}
+	layout.width = IntMax(len(posterior.Datas), 1) * layout.CellWidth
+	layout.height = layout.DataHeight + (layout.MaxRunLength+1)*layout.CellHeight
+
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, timeValue := range posterior.Datas {
+		minValue, maxValue = math.Min(minValue, timeValue.Value), math.Max(maxValue, timeValue.Value)
+	}
+	layout.minValue, layout.span = minValue, maxValue-minValue
+	if len(posterior.Datas) == 0 {
+		layout.minValue, layout.span = 0, 1
+	}
+	if layout.span == 0 {
+		layout.span = 1
+	}
+	return layout
+}
+
+func (l *heatmapLayout) x(step int) int {
+	return step*l.CellWidth + l.CellWidth/2
+}
+
+// y keep 5% margin in the data panel
+func (l *heatmapLayout) y(value float64) int {
+	ratio := (value - l.minValue) / l.span
+	return int(float64(l.DataHeight) * (0.95 - 0.9*ratio))
+}
+
+func (l *heatmapLayout) cell(entry RunLengthEntry) (int, int) {
+	return entry.Step * l.CellWidth, l.height - (entry.RunLength+1)*l.CellHeight
+}
+
+// changePointSteps find the step of each change point by time
+func (p *RunLengthPosterior) changePointSteps() []int {
+	res := []int{}
+	for _, changePoint := range p.ChangePoints {
+		for step, timeValue := range p.Datas {
+			if timeValue.Time.Equal(changePoint.TimeValue.Time) {
+				res = append(res, step)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// heatColor map the probability to white -> blue
+func heatColor(probability float64) color.RGBA {
+	probability = math.Max(0, math.Min(1, probability))
+	level := uint8(255 * (1 - probability))
+	return color.RGBA{R: level, G: level, B: 255, A: 255}
+}
+
+var (
+	dataColor        = color.RGBA{R: 40, G: 40, B: 40, A: 255}
+	changePointColor = color.RGBA{R: 220, G: 30, B: 30, A: 255}
+	separatorColor   = color.RGBA{R: 180, G: 180, B: 180, A: 255}
+)
+
+// RenderHeatmapPNG draw the data, the posterior heatmap and the change points as vertical lines
+func (p *RunLengthPosterior) RenderHeatmapPNG(w io.Writer, opts *HeatmapOptions) error {
+	layout := newHeatmapLayout(p, opts)
+	img := image.NewRGBA(image.Rect(0, 0, layout.width, layout.height))
+	fillRect(img, 0, 0, layout.width, layout.height, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	for _, entry := range p.Entries {
+		if entry.RunLength > layout.MaxRunLength {
+			continue
+		}
+		x, y := layout.cell(entry)
+		fillRect(img, x, y, layout.CellWidth, layout.CellHeight, heatColor(entry.Probability))
+	}
+	fillRect(img, 0, layout.DataHeight, layout.width, 1, separatorColor)
+
+	for step := 1; step < len(p.Datas); step++ {
+		drawLine(img, layout.x(step-1), layout.y(p.Datas[step-1].Value),
+			layout.x(step), layout.y(p.Datas[step].Value), dataColor)
+	}
+	for _, step := range p.changePointSteps() {
+		fillRect(img, layout.x(step), 0, 1, layout.height, changePointColor)
+	}
+
+	return png.Encode(w, img)
+}
+
+// RenderHeatmapSVG is the same as RenderHeatmapPNG, the cell has a title with the probability
+func (p *RunLengthPosterior) RenderHeatmapSVG(w io.Writer, opts *HeatmapOptions) error {
+	layout := newHeatmapLayout(p, opts)
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		layout.width, layout.height, layout.width, layout.height)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="white"/>`+"\n", layout.width, layout.height)
+
+	for _, entry := range p.Entries {
+		if entry.RunLength > layout.MaxRunLength {
+			continue
+		}
+		x, y := layout.cell(entry)
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>%s run_length=%d p=%.4f</title></rect>`+"\n",
+			x, y, layout.CellWidth, layout.CellHeight, svgColor(heatColor(entry.Probability)),
+			p.Datas[entry.Step].Time.Format(time.RFC3339), entry.RunLength, entry.Probability)
+	}
+	fmt.Fprintf(&sb, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+		layout.DataHeight, layout.width, layout.DataHeight, svgColor(separatorColor))
+
+	if len(p.Datas) > 0 {
+		points := make([]string, 0, len(p.Datas))
+		for step, timeValue := range p.Datas {
+			points = append(points, fmt.Sprintf("%d,%d", layout.x(step), layout.y(timeValue.Value)))
+		}
+		fmt.Fprintf(&sb, `<polyline points="%s" fill="none" stroke="%s"/>`+"\n",
+			strings.Join(points, " "), svgColor(dataColor))
+	}
+	for _, step := range p.changePointSteps() {
+		fmt.Fprintf(&sb, `<line x1="%d" y1="0" x2="%d" y2="%d" stroke="%s"><title>change point %s</title></line>`+"\n",
+			layout.x(step), layout.x(step), layout.height, svgColor(changePointColor),
+			p.Datas[step].Time.Format(time.RFC3339))
+	}
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func fillRect(img *image.RGBA, x, y, width, height int, c color.RGBA) {
+	for i := x; i < x+width; i++ {
+		for j := y; j < y+height; j++ {
+			img.SetRGBA(i, j, c)
+		}
+	}
+}
+
+// drawLine draw the line by dda, enough for the small plot
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	steps := IntMax(IntAbs(x1-x0), IntAbs(y1-y0))
+	if steps == 0 {
+		img.SetRGBA(x0, y0, c)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		x := x0 + int(math.Round(float64(i*(x1-x0))/float64(steps)))
+		y := y0 + int(math.Round(float64(i*(y1-y0))/float64(steps)))
+		img.SetRGBA(x, y, c)
+	}
+}
@@ -0,0 +1,158 @@
+package bocd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExportTestChecker(t *testing.T) *BocdOnlineChecker {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	checker := NewBocdOnlineChecker(1, 0)
+	for _, timeValue := range stepTimeSeries(start, 40, []int{20}, []float64{10}).Values {
+		checker.AppendPoint(ctx, timeValue)
+	}
+	if len(checker.GetChangePoints()) != 1 {
+		t.Fatalf("got %v change points, expected 1", len(checker.GetChangePoints()))
+	}
+	return checker
+}
+
+func TestExportRunLengthPosterior(t *testing.T) {
+	checker := newExportTestChecker(t)
+
+	posterior := checker.ExportRunLengthPosterior(0)
+	if len(posterior.Datas) != 40 || len(posterior.PredictionMeans) != 40 || len(posterior.MaxRunLengths) != 40 {
+		t.Fatalf("got %v datas, %v means, %v max run lengths, expected 40", len(posterior.Datas),
+			len(posterior.PredictionMeans), len(posterior.MaxRunLengths))
+	}
+	// the run length after the last point count the points since the change point
+	if posterior.MaxRunLengths[39] != checker.MaxRunLength() || checker.MaxRunLength() != 20 {
+		t.Errorf("got max run length %v, checker %v, expected 20", posterior.MaxRunLengths[39],
+			checker.MaxRunLength())
+	}
+	if steps := posterior.changePointSteps(); len(steps) != 1 || steps[0] != 20 {
+		t.Errorf("got change point steps %v, expected [20]", steps)
+	}
+
+	dense := posterior.Dense()
+	for step, row := range dense {
+		if len(row) != step+2 {
+			t.Fatalf("step %v got %v run lengths, expected %v", step, len(row), step+2)
+		}
+		sum := 0.0
+		for _, probability := range row {
+			sum += probability
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("step %v probabilities sum to %v", step, sum)
+		}
+	}
+
+	// the exported change points are copies
+	posterior.ChangePoints[0].Magnitude = 0
+	if checker.GetChangePoints()[0].Magnitude == 0 {
+		t.Errorf("the export share the change point with the checker")
+	}
+
+	sparse := checker.ExportRunLengthPosterior(1e-3)
+	if len(sparse.Entries) >= len(posterior.Entries) {
+		t.Errorf("got %v entries with min probability, %v without", len(sparse.Entries), len(posterior.Entries))
+	}
+	for _, entry := range sparse.Entries {
+		if entry.Probability < 1e-3 {
+			t.Fatalf("got entry %+v smaller than the min probability", entry)
+		}
+	}
+}
+
+func TestRunLengthPosteriorWriteJSON(t *testing.T) {
+	posterior := newExportTestChecker(t).ExportRunLengthPosterior(1e-3)
+
+	buf := bytes.Buffer{}
+	if err := posterior.WriteJSON(&buf); err != nil {
+		t.Fatalf("write json failed: %v", err)
+	}
+	for _, key := range []string{`"run_length"`, `"prediction_means"`, `"min_probability"`, `"change_points"`} {
+		if !strings.Contains(buf.String(), key) {
+			t.Errorf("json missing key %v", key)
+		}
+	}
+
+	decoded := &RunLengthPosterior{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(decoded.Entries) != len(posterior.Entries) || len(decoded.ChangePoints) != 1 ||
+		!decoded.ChangePoints[0].TimeValue.Time.Equal(posterior.ChangePoints[0].TimeValue.Time) {
+		t.Fatalf("decoded posterior not match")
+	}
+	expected, got := posterior.Dense(), decoded.Dense()
+	for step := range expected {
+		for runLength := range expected[step] {
+			if expected[step][runLength] != got[step][runLength] {
+				t.Fatalf("step %v run length %v got %v, expected %v", step, runLength,
+					got[step][runLength], expected[step][runLength])
+			}
+		}
+	}
+}
+
+func TestRenderHeatmap(t *testing.T) {
+	posterior := newExportTestChecker(t).ExportRunLengthPosterior(1e-3)
+	opts := &HeatmapOptions{CellWidth: 3, CellHeight: 2, MaxRunLength: 30, DataHeight: 50}
+	width, height := 40*3, 50+31*2
+
+	buf := bytes.Buffer{}
+	if err := posterior.RenderHeatmapPNG(&buf, opts); err != nil {
+		t.Fatalf("render png failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode png failed: %v", err)
+	}
+	if img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+		t.Fatalf("got png size %v, expected %vx%v", img.Bounds(), width, height)
+	}
+	// the change point at step 20 is a vertical line, x is the middle of the cell
+	r, g, b, _ := img.At(20*3+1, 0).RGBA()
+	if uint8(r>>8) != changePointColor.R || uint8(g>>8) != changePointColor.G || uint8(b>>8) != changePointColor.B {
+		t.Errorf("got color %v,%v,%v at the change point", r>>8, g>>8, b>>8)
+	}
+
+	buf.Reset()
+	if err := posterior.RenderHeatmapSVG(&buf, opts); err != nil {
+		t.Fatalf("render svg failed: %v", err)
+	}
+	svg := buf.String()
+	drawn := 0
+	for _, entry := range posterior.Entries {
+		if entry.RunLength <= opts.MaxRunLength {
+			drawn++
+		}
+	}
+	// one rect is the background
+	if cnt := strings.Count(svg, "<rect"); cnt != drawn+1 {
+		t.Errorf("got %v rects, expected %v", cnt, drawn+1)
+	}
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="120" height="112"`) ||
+		!strings.Contains(svg, "change point 2024-01-01T00:20:00Z") {
+		t.Errorf("unexpected svg %v", svg[:min(len(svg), 200)])
+	}
+}
+
+func TestRenderHeatmapEmpty(t *testing.T) {
+	posterior := NewBocdOnlineChecker(1, 0).ExportRunLengthPosterior(0)
+	if err := posterior.RenderHeatmapPNG(&bytes.Buffer{}, nil); err != nil {
+		t.Errorf("render png failed: %v", err)
+	}
+	if err := posterior.RenderHeatmapSVG(&bytes.Buffer{}, nil); err != nil {
+		t.Errorf("render svg failed: %v", err)
+	}
+}
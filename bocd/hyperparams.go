@@ -0,0 +1,177 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/optimize"
+)
+
+// BocdConfig is the hyperparameters of the bocd model,
+// use NewBocdOnlineChecker(c.Varx, c.Mean0, c.CheckerOptions()...) or WithBocdConfig(c)
+type BocdConfig struct {
+	Varx   float64 `json:"varx"`
+	Mean0  float64 `json:"mean0"`
+	Hazard float64 `json:"hazard"`
+	// ExpectedInterval is the median interval of the fitted data, the hazard is fitted per point of it
+	ExpectedInterval time.Duration `json:"expected_interval"`
+	// LogLikelihood is the log marginal likelihood of the fitted data
+	LogLikelihood float64 `json:"log_likelihood"`
+}
+
+// CheckerOptions is the hazard and its expected interval, so the hazard is scaled right for the fitted data
+func (c *BocdConfig) CheckerOptions() []BocdCheckerOption {
+	res := []BocdCheckerOption{WithHazard(c.Hazard)}
+	if c.ExpectedInterval > 0 {
+		res = append(res, WithExpectedInterval(c.ExpectedInterval))
+	}
+	return res
+}
+
+// WithHazard set the change point hazard of one expected interval, default is 0.002
+func WithHazard(hazard float64) BocdCheckerOption {
+	return func(b *BocdOnlineChecker) {
+		if hazard > 0 && hazard < 1 {
+			b.hazard = hazard
+		}
+	}
+}
+
+// LogMarginalLikelihood is log p(x_1:n) of the bocd model, the run lengths whose
+// probability is too small are pruned, so the cost is nearly linear
+func LogMarginalLikelihood(values []float64, config *BocdConfig) float64 {
+	// log prob smaller than max - PruneLogProb will be pruned
+	const PruneLogProb = 30.0
+
+	if len(values) == 0 || config.Varx <= 0 || config.Hazard <= 0 || config.Hazard >= 1 {
+		return math.Inf(-1)
+	}
+
+	logh, log1mh := math.Log(config.Hazard), math.Log(1-config.Hazard)
+	varx := config.Varx
+
+	logRunProbs := []float64{0}
+	means := []float64{config.Mean0}
+	invVariances := []float64{1 / varx}
+
+	res := 0.0
+	for _, x := range values {
+		n := len(logRunProbs)
+		newLogRunProbs := make([]float64, n+1)
+		changePointData := make([]float64, n)
+
+		for i := 0; i < n; i++ {
+			variance := 1/invVariances[i] + varx
+			logPreProb := -0.5*math.Log(2*math.Pi*variance) - (x-means[i])*(x-means[i])/(2*variance)
+			changePointData[i] = logPreProb + logRunProbs[i] + logh
+			newLogRunProbs[i+1] = logPreProb + logRunProbs[i] + log1mh
+		}
+		newLogRunProbs[0] = LogSumExp(changePointData)
+
+		// logRunProbs is normalized, so the sum of new probs is p(x_t | x_1:t-1)
+		logEvidence := LogSumExp(newLogRunProbs)
+		res += logEvidence
+
+		// update params and prune the small run lengths
+		maxLogProb := math.Inf(-1)
+		for _, v := range newLogRunProbs {
+			maxLogProb = math.Max(maxLogProb, v)
+		}
+		logRunProbs = []float64{newLogRunProbs[0] - logEvidence}
+		newMeans := []float64{config.Mean0}
+		newInvVariances := []float64{1 / varx}
+		for i := 0; i < n; i++ {
+			if newLogRunProbs[i+1] < maxLogProb-PruneLogProb {
+				continue
+			}
+			invVariance := invVariances[i] + 1/varx
+			logRunProbs = append(logRunProbs, newLogRunProbs[i+1]-logEvidence)
+			newMeans = append(newMeans, (means[i]*invVariances[i]+x/varx)/invVariance)
+			newInvVariances = append(newInvVariances, invVariance)
+		}
+		means, invVariances = newMeans, newInvVariances
+	}
+	return res
+}
+
+// FitBocdConfig maximise the marginal likelihood of the history time series,
+// first grid search the hazard, varx and mean0, then refine by nelder mead
+func FitBocdConfig(ctx context.Context, timeSeries *model.TimeSeries) (*BocdConfig, error) {
+	logger := utils.GetLogger(ctx)
+
+	if timeSeries.IsEmpty() || len(timeSeries.Values) < 2 {
+		logger.Error("time series too short to fit bocd config")
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := utils.SortedTimeValues(timeSeries.Values)
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		values = append(values, timeValue.Value)
+	}
+
+	baseVarx := estimateVarX(datas)
+	if baseVarx <= 0 || math.IsNaN(baseVarx) {
+		logger.Error("can not estimate varx from time series", zap.String("timeSeries", timeSeries.DebugString()))
+		return nil, common.ErrorInvalidValue
+	}
+	sortedValues := append([]float64{}, values...)
+	sort.Float64s(sortedValues)
+	baseMean0 := utils.Median(sortedValues)
+
+	// 1. grid search
+	best := &BocdConfig{LogLikelihood: math.Inf(-1)}
+	for _, hazard := range []float64{1e-4, 3e-4, 1e-3, 3e-3, 1e-2, 3e-2} {
+		for _, varxScale := range []float64{0.25, 0.5, 1, 2, 4} {
+			config := &BocdConfig{
+				Varx:   baseVarx * varxScale,
+				Mean0:  baseMean0,
+				Hazard: hazard,
+			}
+			config.LogLikelihood = LogMarginalLikelihood(values, config)
+			if config.LogLikelihood > best.LogLikelihood {
+				best = config
+			}
+		}
+	}
+
+	// 2. refine by nelder mead, optimize the logit of hazard and log of varx so there is no constraint
+	toConfig := func(x []float64) *BocdConfig {
+		return &BocdConfig{
+			Hazard: 1 / (1 + math.Exp(-x[0])),
+			Varx:   math.Exp(x[1]),
+			Mean0:  x[2],
+		}
+	}
+	problem := optimize.Problem{
+		Func: func(x []float64) float64 {
+			logLikelihood := LogMarginalLikelihood(values, toConfig(x))
+			if math.IsNaN(logLikelihood) || math.IsInf(logLikelihood, 0) {
+				return math.MaxFloat64
+			}
+			return -logLikelihood
+		},
+	}
+	initX := []float64{math.Log(best.Hazard / (1 - best.Hazard)), math.Log(best.Varx), best.Mean0}
+	result, err := optimize.Minimize(problem, initX, &optimize.Settings{FuncEvaluations: 200},
+		&optimize.NelderMead{})
+	if err != nil {
+		logger.Warn("nelder mead refine failed, use the grid search result", zap.Error(err))
+	}
+	if result != nil && -result.F > best.LogLikelihood {
+		config := toConfig(result.X)
+		config.LogLikelihood = -result.F
+		best = config
+	}
+	best.ExpectedInterval = utils.MedianInterval(datas)
+
+	logger.Info("fit bocd config success", zap.String("timeSeries", timeSeries.DebugString()),
+		zap.Any("config", best))
+	return best, nil
+}
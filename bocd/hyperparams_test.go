@@ -0,0 +1,88 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func logNormal(x, mean, variance float64) float64 {
+	return -0.5*math.Log(2*math.Pi*variance) - (x-mean)*(x-mean)/(2*variance)
+}
+
+func TestLogMarginalLikelihood(t *testing.T) {
+	config := &BocdConfig{Varx: 2, Mean0: 1, Hazard: 0.1}
+
+	// the prior variance of the mean is varx, so the predictive variance is 2 * varx
+	got := LogMarginalLikelihood([]float64{3}, config)
+	if want := logNormal(3, 1, 4); math.Abs(got-want) > 1e-12 {
+		t.Errorf("one point want %v, got %v", want, got)
+	}
+
+	// the second point is predicted by the prior with hazard, or the posterior of the first point
+	got = LogMarginalLikelihood([]float64{3, 2}, config)
+	want := logNormal(3, 1, 4) + math.Log(0.1*math.Exp(logNormal(2, 1, 4))+0.9*math.Exp(logNormal(2, 2, 3)))
+	if math.Abs(got-want) > 1e-12 {
+		t.Errorf("two points want %v, got %v", want, got)
+	}
+
+	if !math.IsInf(LogMarginalLikelihood([]float64{1}, &BocdConfig{Varx: 0, Hazard: 0.1}), -1) {
+		t.Errorf("invalid config should be -Inf")
+	}
+}
+
+func TestFitBocdConfig(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	random := rand.New(rand.NewSource(1))
+	timeSeries := &model.TimeSeries{}
+	for i := 0; i < 400; i++ {
+		value := 50 + 2*random.NormFloat64()
+		if i >= 200 {
+			value += 20
+		}
+		timeSeries.Values = append(timeSeries.Values, model.TimeValue{Time: start.Add(time.Duration(i) * time.Minute), Value: value})
+	}
+
+	config, err := FitBocdConfig(context.Background(), timeSeries)
+	if err != nil {
+		t.Fatalf("fit failed: %v", err)
+	}
+	if config.Varx < 2 || config.Varx > 8 || config.Hazard <= 0 || config.Hazard > 0.05 {
+		t.Errorf("want varx near 4 and small hazard, got %+v", config)
+	}
+	// the fitted config is at least as good as the default hazard with the true varx
+	baseline := LogMarginalLikelihood(valuesOf(timeSeries), &BocdConfig{Varx: 4, Mean0: 50, Hazard: hazard()})
+	if config.LogLikelihood < baseline {
+		t.Errorf("fitted log likelihood %v is worse than %v", config.LogLikelihood, baseline)
+	}
+	if config.ExpectedInterval != time.Minute {
+		t.Errorf("got expected interval %v, expected %v", config.ExpectedInterval, time.Minute)
+	}
+}
+
+func TestBocdConfigCheckerOptions(t *testing.T) {
+	config := &BocdConfig{Varx: 4, Mean0: 50, Hazard: 0.01, ExpectedInterval: 5 * time.Minute}
+	checker := NewBocdOnlineChecker(config.Varx, config.Mean0, config.CheckerOptions()...)
+	if checker.hazard != 0.01 || checker.expectedInterval != 5*time.Minute {
+		t.Errorf("got hazard %v interval %v, expected 0.01 and 5m", checker.hazard, checker.expectedInterval)
+	}
+
+	// the config without interval keep the default one
+	config.ExpectedInterval = 0
+	checker = NewBocdOnlineChecker(config.Varx, config.Mean0, config.CheckerOptions()...)
+	if checker.expectedInterval != getExpectedInterval() {
+		t.Errorf("got interval %v, expected %v", checker.expectedInterval, getExpectedInterval())
+	}
+}
+
+func valuesOf(timeSeries *model.TimeSeries) []float64 {
+	res := make([]float64, 0, len(timeSeries.Values))
+	for _, timeValue := range timeSeries.Values {
+		res = append(res, timeValue.Value)
+	}
+	return res
+}
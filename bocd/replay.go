@@ -0,0 +1,49 @@
+package bocd
+
+import (
+	"context"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// ReplayTimeSeries replay the history time series to the handler like it's online,
+// every step the clock is advanced, the new data is appended and the triggered change points are popped.
+// the handler must be created with WithClock(clock)
+func ReplayTimeSeries(ctx context.Context, handler *BocdHandler, clock *utils.ManualClock,
+	timeSeries *model.TimeSeries, step time.Duration) []*model.ChangePoint {
+	logger := utils.GetLogger(ctx)
+
+	res := []*model.ChangePoint{}
+	if timeSeries.IsEmpty() || step <= 0 {
+		return res
+	}
+
+	datas := utils.SortedTimeValues(timeSeries.Values)
+
+	// keep running after the last point, so that the last change points can pass the observe duration
+	endTime := datas[len(datas)-1].Time.Add(handler.checker().ObserveDuration() + step)
+
+	index := 0
+	for now := datas[0].Time; !now.After(endTime); now = now.Add(step) {
+		clock.Set(now)
+
+		begin := index
+		for index < len(datas) && !datas[index].Time.After(now) {
+			index++
+		}
+		if index > begin {
+			handler.AppendTimeSeriesData(ctx, &model.TimeSeries{
+				Labels: timeSeries.Labels,
+				Values: datas[begin:index],
+			})
+		}
+
+		res = append(res, handler.PopNeedTriggerChangePoints(ctx)...)
+	}
+
+	logger.Info("replay time series finish", zap.Int("pointCnt", len(datas)), zap.Int("changePointCnt", len(res)))
+	return res
+}
@@ -0,0 +1,36 @@
+package bocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/utils"
+)
+
+func TestReplayTimeSeries(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(start)
+
+	// the change point is 3 minutes before the end, it's only triggered if the replay
+	// keep running for the 10 minutes observe duration of the checker
+	handler, ok := NewBocdHandler(ctx, "key", WithClock(clock),
+		WithBocdConfig(&BocdConfig{Varx: 1, Mean0: 0, Hazard: 0.002}),
+		WithCheckerOptions(WithObserveDuration(10*time.Minute)))
+	if !ok {
+		t.Fatalf("new handler failed")
+	}
+	timeSeries := stepTimeSeries(start, 60, []int{57}, []float64{10})
+
+	changePoints := ReplayTimeSeries(ctx, handler, clock, timeSeries, time.Minute)
+	if len(changePoints) != 1 {
+		t.Fatalf("want 1 change point, got %d", len(changePoints))
+	}
+	if !changePoints[0].TimeValue.Time.Equal(start.Add(57 * time.Minute)) {
+		t.Errorf("unexpected change point time %v", changePoints[0].TimeValue.Time)
+	}
+	if len(handler.NewChangePoints()) != 0 {
+		t.Errorf("the change point should be popped")
+	}
+}
@@ -0,0 +1,319 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// SeasonalBaseline provide the expected seasonal value at a time,
+// Observe is called with every new point so that the baseline can update itself
+type SeasonalBaseline interface {
+	Baseline(t time.Time) (float64, bool)
+	Observe(timeValue model.TimeValue)
+}
+
+// LagBaseline use the value one lag ago as the baseline,
+// like the same minute yesterday (lag 24h) or a week ago (lag 7*24h)
+type LagBaseline struct {
+	lag        time.Duration
+	resolution time.Duration
+	values     map[int64]float64 // truncated unix time -> value
+	keys       []int64           // the keys in observe order, so the expired values are removed from the front
+	lastTime   time.Time
+}
+
+func NewLagBaseline(lag, resolution time.Duration) *LagBaseline {
+	if resolution <= 0 {
+		resolution = time.Minute
+	}
+	return &LagBaseline{
+		lag:        lag,
+		resolution: resolution,
+		values:     map[int64]float64{},
+	}
+}
+
+func (b *LagBaseline) key(t time.Time) int64 {
+	return t.Truncate(b.resolution).Unix()
+}
+
+func (b *LagBaseline) Baseline(t time.Time) (float64, bool) {
+	value, ok := b.values[b.key(t.Add(-1*b.lag))]
+	return value, ok
+}
+
+// Observe only keep one lag data, the late point is kept a little longer until the keys before it expire
+func (b *LagBaseline) Observe(timeValue model.TimeValue) {
+	if timeValue.Time.After(b.lastTime) {
+		b.lastTime = timeValue.Time
+	}
+	expiredKey := b.key(b.lastTime.Add(-1 * (b.lag + b.resolution)))
+
+	key := b.key(timeValue.Time)
+	if key < expiredKey {
+		return
+	}
+	if _, ok := b.values[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.values[key] = timeValue.Value
+
+	index := 0
+	for index < len(b.keys) && b.keys[index] < expiredKey {
+		delete(b.values, b.keys[index])
+		index++
+	}
+	b.keys = b.keys[index:]
+}
+
+// ObserveTimeSeries fill the baseline with the history data
+func (b *LagBaseline) ObserveTimeSeries(timeSeries *model.TimeSeries) {
+	if timeSeries.IsEmpty() {
+		return
+	}
+	for _, timeValue := range timeSeries.Values {
+		b.Observe(timeValue)
+	}
+}
+
+// ProfileBaseline is a fitted seasonal profile, the period is split into buckets,
+// the baseline of a bucket is the median of the history values in the bucket
+type ProfileBaseline struct {
+	period  time.Duration
+	bucket  time.Duration
+	profile []float64
+	valid   []bool
+}
+
+func FitSeasonalProfile(timeSeries *model.TimeSeries, period, bucket time.Duration) (*ProfileBaseline, error) {
+	if timeSeries.IsEmpty() || period <= 0 || bucket <= 0 || period%bucket != 0 {
+		return nil, common.ErrorInvalidValue
+	}
+
+	bucketCnt := int(period / bucket)
+	bucketValues := make([][]float64, bucketCnt)
+	res := &ProfileBaseline{
+		period:  period,
+		bucket:  bucket,
+		profile: make([]float64, bucketCnt),
+		valid:   make([]bool, bucketCnt),
+	}
+
+	for _, timeValue := range timeSeries.Values {
+		if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) {
+			continue
+		}
+		index := res.bucketIndex(timeValue.Time)
+		bucketValues[index] = append(bucketValues[index], timeValue.Value)
+	}
+
+	for i, values := range bucketValues {
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+		res.profile[i] = utils.Median(values)
+		res.valid[i] = true
+	}
+	return res, nil
+}
+
+func (b *ProfileBaseline) bucketIndex(t time.Time) int {
+	offset := time.Duration(t.UnixNano()) % b.period
+	if offset < 0 {
+		offset += b.period
+	}
+	return int(offset / b.bucket)
+}
+
+func (b *ProfileBaseline) Baseline(t time.Time) (float64, bool) {
+	index := b.bucketIndex(t)
+	return b.profile[index], b.valid[index]
+}
+
+// Observe the profile is fitted offline, don't update online
+func (b *ProfileBaseline) Observe(timeValue model.TimeValue) {}
+
+type seasonalPoint struct {
+	timeValue model.TimeValue
+	baseline  float64
+}
+
+// SeasonalBocdChecker remove the seasonal baseline from the point,
+// and run bocd on the residual, so the daily ramp won't be reported as change point
+type SeasonalBocdChecker struct {
+	checker  *BocdOnlineChecker
+	baseline SeasonalBaseline
+	varx     float64
+	opts     []BocdCheckerOption
+
+	points       []seasonalPoint // points in the inner checker, used to report in original units
+	changePoints []*model.ChangePoint
+}
+
+// NewSeasonalBocdChecker varx is the variance of the residual, the residual mean is 0
+func NewSeasonalBocdChecker(baseline SeasonalBaseline, varx float64,
+	opts ...BocdCheckerOption) *SeasonalBocdChecker {
+	return &SeasonalBocdChecker{
+		checker:      NewBocdOnlineChecker(varx, 0, opts...),
+		baseline:     baseline,
+		varx:         varx,
+		opts:         opts,
+		points:       []seasonalPoint{},
+		changePoints: []*model.ChangePoint{},
+	}
+}
+
+// AppendPoint the point is skipped if there is no baseline at the time,
+// the inner checker is rebalanced the same as the handler so the cached residuals are limited
+func (s *SeasonalBocdChecker) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	logger := utils.GetLogger(ctx)
+
+	baseline, ok := s.baseline.Baseline(timeValue.Time)
+	s.baseline.Observe(timeValue)
+	if !ok {
+		logger.Debug("no seasonal baseline, skip point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+
+	residual := model.TimeValue{
+		Time:  timeValue.Time,
+		Value: timeValue.Value - baseline,
+	}
+	if needRebalance(s.checker, residual) {
+		s.checker = reserveChecker(ctx, s.checker, residual, s.varx, 0, s.opts)
+		s.removeOldPoints(residual.Time)
+		logger.Debug("rebalance seasonal checker", zap.Int("dataSize", s.checker.DataSize()))
+	}
+	s.points = append(s.points, seasonalPoint{timeValue: timeValue, baseline: baseline})
+
+	residualChangePoint, found := s.checker.AppendPoint(ctx, residual)
+	if !found {
+		return nil, false
+	}
+
+	changePoint, ok := s.toOriginalUnits(residualChangePoint)
+	if !ok {
+		logger.Warn("no seasonal baseline at the change point, skip it", zap.Any("changePoint", residualChangePoint))
+		return nil, false
+	}
+	s.changePoints = append(s.changePoints, changePoint)
+	return changePoint, true
+}
+
+// removeOldPoints remove the points before the datas of the inner checker after rebalance
+func (s *SeasonalBocdChecker) removeOldPoints(currentTime time.Time) {
+	firstTime := currentTime
+	if timeValue, ok := s.checker.FirstTimeValue(); ok {
+		firstTime = timeValue.Time
+	}
+	index := sort.Search(len(s.points), func(i int) bool {
+		return !s.points[i].timeValue.Time.Before(firstTime)
+	})
+	s.points = append([]seasonalPoint{}, s.points[index:]...)
+}
+
+// toOriginalUnits add the baseline back, the pre and post means add the mean baseline of their segments,
+// so the magnitude is the change in original units. return false if the baseline at the change point is unknown
+func (s *SeasonalBocdChecker) toOriginalUnits(residualChangePoint *model.ChangePoint) (*model.ChangePoint, bool) {
+	changePoint := *residualChangePoint
+
+	points := make(map[int64]seasonalPoint, len(s.points))
+	for _, point := range s.points {
+		points[point.timeValue.Time.UnixNano()] = point
+	}
+	baselineAt := func(t time.Time) (float64, bool) {
+		if point, ok := points[t.UnixNano()]; ok {
+			return point.baseline, true
+		}
+		// the imputed point
+		return s.baseline.Baseline(t)
+	}
+
+	changePointTime := residualChangePoint.TimeValue.Time
+	if point, ok := points[changePointTime.UnixNano()]; ok {
+		changePoint.TimeValue = point.timeValue
+	} else if baseline, ok := s.baseline.Baseline(changePointTime); ok {
+		changePoint.TimeValue.Value += baseline
+	} else {
+		return nil, false
+	}
+
+	preBaseline, postBaseline := s.segmentBaselines(changePointTime, baselineAt)
+	changePoint.PreMean += preBaseline
+	changePoint.PostMean += postBaseline
+	changePoint.Magnitude = changePoint.PostMean - changePoint.PreMean
+	changePoint.RelativeMagnitude = 0
+	if changePoint.PreMean != 0 {
+		changePoint.RelativeMagnitude = changePoint.Magnitude / math.Abs(changePoint.PreMean)
+	}
+	return &changePoint, true
+}
+
+// segmentBaselines is the mean baseline of the pre and post segments of the change point,
+// the segments are the same as BocdOnlineChecker.newChangePoint
+func (s *SeasonalBocdChecker) segmentBaselines(changePointTime time.Time,
+	baselineAt func(t time.Time) (float64, bool)) (float64, float64) {
+	datas := s.checker.Datas()
+	search := func(t time.Time) int {
+		return sort.Search(len(datas), func(i int) bool {
+			return !datas[i].Time.Before(t)
+		})
+	}
+	changePointLoc := search(changePointTime)
+
+	// the last change point of the inner checker is this one
+	preStart := 0
+	innerChangePoints := s.checker.GetChangePoints()
+	if len(innerChangePoints) > 1 {
+		lastChangePointTime := innerChangePoints[len(innerChangePoints)-2].TimeValue.Time
+		if lastChangePointTime.Before(changePointTime) {
+			preStart = search(lastChangePointTime)
+		}
+	}
+
+	meanBaseline := func(timeValues []model.TimeValue) float64 {
+		sum, cnt := 0.0, 0
+		for _, timeValue := range timeValues {
+			if baseline, ok := baselineAt(timeValue.Time); ok {
+				sum += baseline
+				cnt++
+			}
+		}
+		if cnt == 0 {
+			return 0
+		}
+		return sum / float64(cnt)
+	}
+	return meanBaseline(datas[preStart:changePointLoc]), meanBaseline(datas[changePointLoc:])
+}
+
+// Checker return the inner checker, its datas are the residuals
+func (s *SeasonalBocdChecker) Checker() *BocdOnlineChecker {
+	return s.checker
+}
+
+// GetChangePoints return the copies of the change points
+func (s *SeasonalBocdChecker) GetChangePoints() []*model.ChangePoint {
+	res := make([]*model.ChangePoint, 0, len(s.changePoints))
+	for _, changePoint := range s.changePoints {
+		copied := *changePoint
+		res = append(res, &copied)
+	}
+	return res
+}
+
+func (s *SeasonalBocdChecker) LastChangePoint() (*model.ChangePoint, bool) {
+	if len(s.changePoints) > 0 {
+		copied := *s.changePoints[len(s.changePoints)-1]
+		return &copied, true
+	}
+	return nil, false
+}
@@ -0,0 +1,136 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func TestLagBaseline(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	baseline := NewLagBaseline(time.Hour, time.Minute)
+	for i := 0; i < 180; i++ {
+		baseline.Observe(model.TimeValue{Time: start.Add(time.Duration(i) * time.Minute), Value: float64(i)})
+	}
+
+	if len(baseline.values) > 62 || len(baseline.keys) != len(baseline.values) {
+		t.Errorf("want only one lag kept, got %d values %d keys", len(baseline.values), len(baseline.keys))
+	}
+	if value, ok := baseline.Baseline(start.Add(179 * time.Minute)); !ok || value != 119 {
+		t.Errorf("want the value one hour ago 119, got %v %v", value, ok)
+	}
+	if _, ok := baseline.Baseline(start.Add(100 * time.Minute)); ok {
+		t.Errorf("the expired value should be removed")
+	}
+
+	// the late point older than one lag is dropped
+	baseline.Observe(model.TimeValue{Time: start.Add(10 * time.Minute), Value: -1})
+	if _, ok := baseline.values[baseline.key(start.Add(10*time.Minute))]; ok {
+		t.Errorf("the expired late point should be dropped")
+	}
+}
+
+func TestSeasonalBocdChecker(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	random := rand.New(rand.NewSource(1))
+
+	// the residual of the lag baseline is the difference of two noises, so varx is 2
+	checker := NewSeasonalBocdChecker(NewLagBaseline(time.Hour, time.Minute), 2)
+	changePoints := []*model.ChangePoint{}
+	for i := 0; i < 600; i++ {
+		value := 20*math.Sin(2*math.Pi*float64(i)/60) + random.NormFloat64()
+		if i >= 150 {
+			value += 15
+		}
+		timeValue := model.TimeValue{Time: start.Add(time.Duration(i) * time.Minute), Value: value}
+		if changePoint, ok := checker.AppendPoint(ctx, timeValue); ok {
+			changePoints = append(changePoints, changePoint)
+			if changePoint.TimeValue != timeValue {
+				t.Errorf("want the change point in original units %+v, got %+v", timeValue, changePoint.TimeValue)
+			}
+		}
+	}
+
+	// the baseline catch up the step one lag later
+	if len(changePoints) != 2 {
+		t.Fatalf("want 2 change points, got %d", len(changePoints))
+	}
+	if !changePoints[0].TimeValue.Time.Equal(start.Add(150*time.Minute)) ||
+		changePoints[0].ChangePointType != model.IncreaseChangePoint {
+		t.Errorf("unexpected first change point %+v", changePoints[0])
+	}
+	if !changePoints[1].TimeValue.Time.Equal(start.Add(210*time.Minute)) ||
+		changePoints[1].ChangePointType != model.DecreaseChangePoint {
+		t.Errorf("unexpected second change point %+v", changePoints[1])
+	}
+
+	// no change point in the last 6 hours, the residuals before the reserve duration are dropped
+	if duration := checker.Checker().DataDuration(); duration > preSmoothDuration {
+		t.Errorf("want the inner checker rebalanced, data duration %v", duration)
+	}
+
+	// the change point without the baseline is skipped instead of reported as the residual
+	residual := &model.ChangePoint{TimeValue: model.TimeValue{Time: start.Add(-time.Hour), Value: 1}}
+	if _, ok := checker.toOriginalUnits(residual); ok {
+		t.Errorf("the change point without baseline should be skipped")
+	}
+}
+
+func TestSeasonalBocdCheckerSegmentMeans(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	random := rand.New(rand.NewSource(1))
+
+	// the daily profile is a ramp of 0.5 each minute
+	history := &model.TimeSeries{}
+	for i := 0; i < 24*60; i++ {
+		history.Values = append(history.Values, model.TimeValue{Time: start.Add(time.Duration(i) * time.Minute),
+			Value: 0.5 * float64(i)})
+	}
+	profile, err := FitSeasonalProfile(history, 24*time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("fit profile failed: %v", err)
+	}
+
+	checker := NewSeasonalBocdChecker(profile, 1)
+	values := []float64{}
+	var found *model.ChangePoint
+	for i := 0; i < 200 && found == nil; i++ {
+		value := 0.5*float64(i) + random.NormFloat64()
+		if i >= 150 {
+			value += 10
+		}
+		values = append(values, value)
+		found, _ = checker.AppendPoint(ctx, model.TimeValue{Time: start.Add(time.Duration(i) * time.Minute),
+			Value: value})
+	}
+	if found == nil || !found.TimeValue.Time.Equal(start.Add(150*time.Minute)) {
+		t.Fatalf("got change point %+v, expected at minute 150", found)
+	}
+
+	// the means are the means of the original values in the segments, not shifted by the baseline at 150
+	mean := func(values []float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+	preMean, postMean := mean(values[:150]), mean(values[150:])
+	if math.Abs(found.PreMean-preMean) > 1e-9 || math.Abs(found.PostMean-postMean) > 1e-9 ||
+		math.Abs(found.Magnitude-(postMean-preMean)) > 1e-9 {
+		t.Errorf("got pre mean %v post mean %v magnitude %v, expected %v %v", found.PreMean, found.PostMean,
+			found.Magnitude, preMean, postMean)
+	}
+
+	// the accessors return copies
+	checker.GetChangePoints()[0].PreMean = 0
+	if lastChangePoint, _ := checker.LastChangePoint(); lastChangePoint.PreMean != found.PreMean {
+		t.Errorf("the change point is changed by the returned copy")
+	}
+}
@@ -0,0 +1,242 @@
+package bocd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// ChangePointNotification is what the sinks deliver, one notification for one change point
+type ChangePointNotification struct {
+	TimeSeriesKey string             `json:"time_series_key"`
+	ChangePoint   *model.ChangePoint `json:"change_point"`
+}
+
+// IdempotencyKey is the same for the same change point of the same series,
+// so the receiver can dedup the notifications delivered more than once
+func (n *ChangePointNotification) IdempotencyKey() string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%v|%v|%v", n.TimeSeriesKey,
+		n.ChangePoint.TimeValue.Time.UnixNano(), n.ChangePoint.ChangePointType)))
+	return hex.EncodeToString(hash[:])
+}
+
+// ChangePointSink deliver the triggered change points
+type ChangePointSink interface {
+	Send(ctx context.Context, notifications []*ChangePointNotification) error
+}
+
+func newChangePointNotifications(timeSeriesKey string, changePoints []*model.ChangePoint) []*ChangePointNotification {
+	res := make([]*ChangePointNotification, 0, len(changePoints))
+	for _, changePoint := range changePoints {
+		res = append(res, &ChangePointNotification{
+			TimeSeriesKey: timeSeriesKey,
+			ChangePoint:   changePoint,
+		})
+	}
+	return res
+}
+
+// ChannelSink send the notifications to a go channel, block until the receiver take it or ctx done
+type ChannelSink struct {
+	ch chan<- *ChangePointNotification
+}
+
+func NewChannelSink(ch chan<- *ChangePointNotification) *ChannelSink {
+	return &ChannelSink{
+		ch: ch,
+	}
+}
+
+func (s *ChannelSink) Send(ctx context.Context, notifications []*ChangePointNotification) error {
+	for _, notification := range notifications {
+		select {
+		case s.ch <- notification:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// JSONLFileSink append one json line for each notification to the file
+type JSONLFileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewJSONLFileSink(path string) (*JSONLFileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLFileSink{
+		file: file,
+	}, nil
+}
+
+func (s *JSONLFileSink) Send(ctx context.Context, notifications []*ChangePointNotification) error {
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	for _, notification := range notifications {
+		if err := encoder.Encode(notification); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// write all the lines once, so the lines of different sends won't interleave
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+func (s *JSONLFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// WebhookSink post each notification as json to the url, the Idempotency-Key header is set,
+// the failed request is retried with exponential backoff if it's a network error, 429 or 5xx.
+// the retries stop when the ctx done, the handler bound it by WithSinkTimeout
+type WebhookSink struct {
+	url            string
+	client         *http.Client
+	headers        map[string]string
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+type WebhookSinkOption func(*WebhookSink)
+
+// WithHTTPClient default is a client with 10 seconds timeout
+func WithHTTPClient(client *http.Client) WebhookSinkOption {
+	return func(s *WebhookSink) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithWebhookHeader add a header to every request, like the authorization
+func WithWebhookHeader(key, value string) WebhookSinkOption {
+	return func(s *WebhookSink) {
+		s.headers[key] = value
+	}
+}
+
+// WithMaxRetries default is 3, 0 means no retry
+func WithMaxRetries(maxRetries int) WebhookSinkOption {
+	return func(s *WebhookSink) {
+		if maxRetries >= 0 {
+			s.maxRetries = maxRetries
+		}
+	}
+}
+
+// WithRetryBackoff the backoff begin at initial and double after each retry, at most max
+func WithRetryBackoff(initial, max time.Duration) WebhookSinkOption {
+	return func(s *WebhookSink) {
+		if initial > 0 && max >= initial {
+			s.initialBackoff, s.maxBackoff = initial, max
+		}
+	}
+}
+
+func NewWebhookSink(url string, opts ...WebhookSinkOption) *WebhookSink {
+	sink := &WebhookSink{
+		url:            url,
+		client:         &http.Client{Timeout: 10 * time.Second},
+		headers:        map[string]string{},
+		maxRetries:     3,
+		initialBackoff: 500 * time.Millisecond,
+		maxBackoff:     10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(sink)
+	}
+	return sink
+}
+
+// Send stop at the first notification failed after all the retries
+func (s *WebhookSink) Send(ctx context.Context, notifications []*ChangePointNotification) error {
+	for _, notification := range notifications {
+		if err := s.sendWithRetry(ctx, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *WebhookSink) sendWithRetry(ctx context.Context, notification *ChangePointNotification) error {
+	logger := utils.GetLogger(ctx)
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.initialBackoff
+	for retry := 0; ; retry++ {
+		retryable, err := s.post(ctx, body, notification.IdempotencyKey())
+		if err == nil {
+			return nil
+		}
+		if !retryable || retry >= s.maxRetries {
+			return err
+		}
+
+		logger.Warn("send webhook failed, retry", zap.Error(err), zap.Int("retry", retry),
+			zap.Duration("backoff", backoff))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// post return whether the request can be retried if failed
+func (s *WebhookSink) post(ctx context.Context, body []byte, idempotencyKey string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	// read the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("%w: %v", common.ErrorUnexpectedStatus, resp.StatusCode)
+}
@@ -0,0 +1,236 @@
+package bocd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func newTestNotifications(timeSeriesKey string, minutes ...int) []*ChangePointNotification {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	changePoints := []*model.ChangePoint{}
+	for _, minute := range minutes {
+		changePoints = append(changePoints, newTestChangePoint(start, minute, model.IncreaseChangePoint, 1))
+	}
+	return newChangePointNotifications(timeSeriesKey, changePoints)
+}
+
+// webhookRecorder answer the requests by the statuses in order, the last status is used after them
+type webhookRecorder struct {
+	mu              sync.Mutex
+	statuses        []int
+	requestTimes    []time.Time
+	idempotencyKeys []string
+	notifications   []*ChangePointNotification
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	notification := &ChangePointNotification{}
+	err := json.NewDecoder(req.Body).Decode(notification)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	status := r.statuses[min(len(r.requestTimes), len(r.statuses)-1)]
+	r.requestTimes = append(r.requestTimes, time.Now())
+	r.idempotencyKeys = append(r.idempotencyKeys, req.Header.Get("Idempotency-Key"))
+	r.notifications = append(r.notifications, notification)
+	w.WriteHeader(status)
+}
+
+func TestChangePointNotificationIdempotencyKey(t *testing.T) {
+	notifications := newTestNotifications("key1", 10, 10, 20)
+	other := newTestNotifications("key2", 10)
+
+	if notifications[0].IdempotencyKey() != notifications[1].IdempotencyKey() {
+		t.Fatalf("same change point got different keys")
+	}
+	if notifications[0].IdempotencyKey() == notifications[2].IdempotencyKey() {
+		t.Fatalf("different change points got the same key")
+	}
+	if notifications[0].IdempotencyKey() == other[0].IdempotencyKey() {
+		t.Fatalf("different series got the same key")
+	}
+}
+
+func TestWebhookSinkRetryServerError(t *testing.T) {
+	recorder := &webhookRecorder{statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError,
+		http.StatusOK}}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	backoff := 20 * time.Millisecond
+	sink := NewWebhookSink(server.URL, WithRetryBackoff(backoff, 4*backoff), WithWebhookHeader("X-Token", "t"))
+	notifications := newTestNotifications("key", 10)
+	if err := sink.Send(context.Background(), notifications); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	if len(recorder.requestTimes) != 3 {
+		t.Fatalf("got %v requests, expected 3", len(recorder.requestTimes))
+	}
+	// the backoff is doubled after each retry
+	for i, expected := range []time.Duration{backoff, 2 * backoff} {
+		if gap := recorder.requestTimes[i+1].Sub(recorder.requestTimes[i]); gap < expected {
+			t.Errorf("retry %v after %v, expected backoff %v", i, gap, expected)
+		}
+	}
+	// the retries carry the same key so the receiver can dedup them
+	for _, key := range recorder.idempotencyKeys {
+		if key != notifications[0].IdempotencyKey() {
+			t.Errorf("got idempotency key %v, expected %v", key, notifications[0].IdempotencyKey())
+		}
+	}
+	if recorder.notifications[2].TimeSeriesKey != "key" ||
+		!recorder.notifications[2].ChangePoint.TimeValue.Time.Equal(notifications[0].ChangePoint.TimeValue.Time) {
+		t.Errorf("got notification %+v", recorder.notifications[2])
+	}
+}
+
+func TestWebhookSinkMaxRetries(t *testing.T) {
+	recorder := &webhookRecorder{statuses: []int{http.StatusTooManyRequests}}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, WithMaxRetries(2), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	err := sink.Send(context.Background(), newTestNotifications("key", 10, 20))
+	if !errors.Is(err, common.ErrorUnexpectedStatus) {
+		t.Fatalf("got error %v, expected %v", err, common.ErrorUnexpectedStatus)
+	}
+	// send stop at the first failed notification
+	if len(recorder.requestTimes) != 3 {
+		t.Fatalf("got %v requests, expected 3", len(recorder.requestTimes))
+	}
+}
+
+func TestWebhookSinkNoRetryClientError(t *testing.T) {
+	recorder := &webhookRecorder{statuses: []int{http.StatusBadRequest, http.StatusOK}}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, WithRetryBackoff(time.Millisecond, time.Millisecond))
+	err := sink.Send(context.Background(), newTestNotifications("key", 10))
+	if !errors.Is(err, common.ErrorUnexpectedStatus) {
+		t.Fatalf("got error %v, expected %v", err, common.ErrorUnexpectedStatus)
+	}
+	if len(recorder.requestTimes) != 1 {
+		t.Fatalf("got %v requests, expected 1", len(recorder.requestTimes))
+	}
+}
+
+func TestWebhookSinkStopRetryWhenCtxDone(t *testing.T) {
+	recorder := &webhookRecorder{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, WithMaxRetries(100), WithRetryBackoff(time.Second, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	err := sink.Send(ctx, newTestNotifications("key", 10))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Fatalf("send took %v after the ctx done", elapsed)
+	}
+}
+
+func TestJSONLFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "change_points.jsonl")
+	sink, err := NewJSONLFileSink(path)
+	if err != nil {
+		t.Fatalf("new sink failed: %v", err)
+	}
+	notifications := newTestNotifications("key", 10, 20, 30)
+	if err := sink.Send(context.Background(), notifications[:2]); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if err := sink.Send(context.Background(), notifications[2:]); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer file.Close()
+
+	lineCnt := 0
+	scanner := bufio.NewScanner(file)
+	for ; scanner.Scan(); lineCnt++ {
+		notification := &ChangePointNotification{}
+		if err := json.Unmarshal(scanner.Bytes(), notification); err != nil {
+			t.Fatalf("line %v is not json: %v", lineCnt, err)
+		}
+		if lineCnt < len(notifications) && notification.IdempotencyKey() != notifications[lineCnt].IdempotencyKey() {
+			t.Errorf("line %v got %+v", lineCnt, notification.ChangePoint)
+		}
+	}
+	if lineCnt != len(notifications) {
+		t.Fatalf("got %v lines, expected %v", lineCnt, len(notifications))
+	}
+}
+
+func TestChannelSink(t *testing.T) {
+	notifications := newTestNotifications("key", 10, 20)
+
+	ch := make(chan *ChangePointNotification, len(notifications))
+	if err := NewChannelSink(ch).Send(context.Background(), notifications); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	for _, expected := range notifications {
+		if got := <-ch; got != expected {
+			t.Errorf("got %+v, expected %+v", got, expected)
+		}
+	}
+
+	// nobody receive the unbuffered channel, the send return when the ctx done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewChannelSink(make(chan *ChangePointNotification)).Send(ctx, notifications); !errors.Is(err,
+		context.Canceled) {
+		t.Fatalf("got error %v, expected %v", err, context.Canceled)
+	}
+}
+
+// blockingSink block until the ctx done
+type blockingSink struct{}
+
+func (s blockingSink) Send(ctx context.Context, notifications []*ChangePointNotification) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHandlerSinkTimeout(t *testing.T) {
+	timeout := 100 * time.Millisecond
+	handler := newBocdHandler("key", WithSinks(blockingSink{}, blockingSink{}, blockingSink{}),
+		WithSinkTimeout(timeout))
+
+	begin := time.Now()
+	handler.sendToSinks(context.Background(), []*model.ChangePoint{
+		newTestChangePoint(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 10, model.IncreaseChangePoint, 1),
+	})
+	// all the sinks share one deadline, it's 3 timeouts if each sink has its own
+	if elapsed := time.Since(begin); elapsed < timeout || elapsed > 5*timeout/2 {
+		t.Fatalf("send to sinks took %v, expected about %v", elapsed, timeout)
+	}
+}
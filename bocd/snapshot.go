@@ -0,0 +1,94 @@
+package bocd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// BocdHandlerSnapshot is the state needed to restore a handler after it's evicted
+type BocdHandlerSnapshot struct {
+	TimeSeriesKey      string               `json:"time_series_key"`
+	Varx               float64              `json:"varx"`
+	Mean0              float64              `json:"mean0"`
+	Datas              []model.TimeValue    `json:"datas"`
+	NewChangePoints    []*model.ChangePoint `json:"new_change_points"`
+	LastAppendDataTime time.Time            `json:"last_append_data_time"`
+}
+
+// HandlerSnapshotStore save the handler snapshot, Load return nil if the snapshot not exist
+type HandlerSnapshotStore interface {
+	Save(ctx context.Context, snapshot *BocdHandlerSnapshot) error
+	Load(ctx context.Context, timeSeriesKey string) (*BocdHandlerSnapshot, error)
+}
+
+// Snapshot wait the running append finish, so the datas and params are consistent
+func (m *BocdHandler) Snapshot() *BocdHandlerSnapshot {
+	m.appendMu.Lock()
+	defer m.appendMu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &BocdHandlerSnapshot{
+		TimeSeriesKey:      m.timeSeriesKey,
+		Varx:               m.varx,
+		Mean0:              m.mean0,
+		Datas:              m.onlineChecker.Datas(),
+		NewChangePoints:    append([]*model.ChangePoint{}, m.newChangePoints...),
+		LastAppendDataTime: m.lastAppendDataTime,
+	}
+}
+
+// RestoreBocdHandler use the snapshot params instead of the statistics provider,
+// the cached datas are replayed to rebuild the online checker
+func RestoreBocdHandler(ctx context.Context, snapshot *BocdHandlerSnapshot,
+	opts ...BocdHandlerOption) (*BocdHandler, bool) {
+	logger := utils.GetLogger(ctx)
+
+	if snapshot == nil || snapshot.Varx <= 0 {
+		logger.Error("invalid handler snapshot", zap.Any("snapshot", snapshot))
+		return nil, false
+	}
+
+	handler := newBocdHandler(snapshot.TimeSeriesKey, opts...)
+	handler.newChangePoints = append(handler.newChangePoints, snapshot.NewChangePoints...)
+	handler.varx, handler.mean0 = snapshot.Varx, snapshot.Mean0
+	handler.lastAppendDataTime = snapshot.LastAppendDataTime
+
+	handler.onlineChecker = NewBocdOnlineChecker(handler.varx, handler.mean0, handler.checkerOptions...)
+	for _, timeValue := range snapshot.Datas {
+		handler.onlineChecker.AppendPoint(ctx, timeValue)
+	}
+
+	logger.Info("restore bocd handler success", zap.String("timeSeriesKey", snapshot.TimeSeriesKey),
+		zap.Int("dataSize", len(snapshot.Datas)))
+	return handler, true
+}
+
+type MemoryHandlerSnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]*BocdHandlerSnapshot
+}
+
+func NewMemoryHandlerSnapshotStore() *MemoryHandlerSnapshotStore {
+	return &MemoryHandlerSnapshotStore{
+		snapshots: map[string]*BocdHandlerSnapshot{},
+	}
+}
+
+func (s *MemoryHandlerSnapshotStore) Save(ctx context.Context, snapshot *BocdHandlerSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.TimeSeriesKey] = snapshot
+	return nil
+}
+
+func (s *MemoryHandlerSnapshotStore) Load(ctx context.Context, timeSeriesKey string) (*BocdHandlerSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshots[timeSeriesKey], nil
+}
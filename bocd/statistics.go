@@ -0,0 +1,160 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat"
+)
+
+// StatisticsProvider provide the daily statistics data of a time series,
+// the bocd handler use RecentNormalVariance and RecentNormalMean as varx and mean0
+type StatisticsProvider interface {
+	GetDailyStatisticsData(ctx context.Context, timeSeriesKey string) (*model.DailyStatisticsData, error)
+}
+
+type StatisticsProviderFunc func(ctx context.Context, timeSeriesKey string) (*model.DailyStatisticsData, error)
+
+func (f StatisticsProviderFunc) GetDailyStatisticsData(ctx context.Context,
+	timeSeriesKey string) (*model.DailyStatisticsData, error) {
+	return f(ctx, timeSeriesKey)
+}
+
+// HistoryLoader load the history data of a time series, like the last day data
+type HistoryLoader func(ctx context.Context, timeSeriesKey string) (*model.TimeSeries, error)
+
+// HistoryStatisticsProvider calculate the daily statistics data from the history data
+type HistoryStatisticsProvider struct {
+	loader         HistoryLoader
+	recentDuration time.Duration
+	zScore         float64
+	clock          utils.Clock
+}
+
+func NewHistoryStatisticsProvider(loader HistoryLoader) *HistoryStatisticsProvider {
+	return &HistoryStatisticsProvider{
+		loader:         loader,
+		recentDuration: getRecentStatisticsDuration(),
+		zScore:         getNormalZScore(),
+		clock:          utils.NewRealClock(),
+	}
+}
+
+// SetClock set the clock used as the update time of the statistics data
+func (p *HistoryStatisticsProvider) SetClock(clock utils.Clock) {
+	if clock != nil {
+		p.clock = clock
+	}
+}
+
+func (p *HistoryStatisticsProvider) GetDailyStatisticsData(ctx context.Context,
+	timeSeriesKey string) (*model.DailyStatisticsData, error) {
+	logger := utils.GetLogger(ctx)
+
+	timeSeries, err := p.loader(ctx, timeSeriesKey)
+	if err != nil {
+		logger.Error("load history failed", zap.Error(err), zap.String("timeSeriesKey", timeSeriesKey))
+		return nil, err
+	}
+
+	res, err := CalculateDailyStatisticsData(timeSeries, p.clock.Now(), p.recentDuration, p.zScore)
+	if err != nil {
+		logger.Error("CalculateDailyStatisticsData failed", zap.Error(err), zap.String("timeSeriesKey", timeSeriesKey))
+		return nil, err
+	}
+
+	logger.Info("GetDailyStatisticsData success", zap.String("timeSeriesKey", timeSeriesKey),
+		zap.Any("dailyStatisticsData", res))
+	return res, nil
+}
+
+// CalculateDailyStatisticsData the recent data is the data in recentDuration before the last point,
+// the normal data is the data whose zscore is not bigger than zScore
+func CalculateDailyStatisticsData(timeSeries *model.TimeSeries, now time.Time,
+	recentDuration time.Duration, zScore float64) (*model.DailyStatisticsData, error) {
+	if timeSeries.IsEmpty() {
+		return nil, common.ErrorInvalidValue
+	}
+
+	values, recentValues := []float64{}, []float64{}
+	lastTime := timeSeries.Values[0].Time
+	for _, timeValue := range timeSeries.Values {
+		if timeValue.Time.After(lastTime) {
+			lastTime = timeValue.Time
+		}
+	}
+	recentStartTime := lastTime.Add(-1 * recentDuration)
+
+	for _, timeValue := range timeSeries.Values {
+		if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) {
+			continue
+		}
+		values = append(values, timeValue.Value)
+		if timeValue.Time.After(recentStartTime) {
+			recentValues = append(recentValues, timeValue.Value)
+		}
+	}
+	if len(values) == 0 || len(recentValues) == 0 {
+		return nil, common.ErrorInvalidValue
+	}
+
+	res := &model.DailyStatisticsData{
+		Mean:            stat.Mean(values, nil),
+		UpdateTimestamp: now.Unix(),
+	}
+	res.RecentMean, res.RecentVariance = meanVariance(recentValues)
+	res.RecentStddev = math.Sqrt(res.RecentVariance)
+	res.NormalMean, res.NormalVariance = meanVariance(filterByZScore(values, zScore))
+	res.RecentNormalMean, res.RecentNormalVariance = meanVariance(filterByZScore(recentValues, zScore))
+
+	return res, nil
+}
+
+func meanVariance(values []float64) (float64, float64) {
+	if len(values) < 2 {
+		return stat.Mean(values, nil), 0
+	}
+	return stat.MeanVariance(values, nil)
+}
+
+func filterByZScore(values []float64, zScore float64) []float64 {
+	mean, variance := meanVariance(values)
+	stddev := math.Sqrt(variance)
+	if stddev == 0 {
+		return values
+	}
+
+	res := []float64{}
+	for _, v := range values {
+		if math.Abs(v-mean)/stddev <= zScore {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func getRecentStatisticsDuration() time.Duration {
+	return 3 * time.Hour
+}
+
+func getNormalZScore() float64 {
+	return 3.0
+}
+
+// validStatisticsData is DailyStatisticsData.ValidAt without the mean checks, the mean of a series like the
+// growth rate can be 0. varx is used as denominator, must be positive
+func validStatisticsData(data *model.DailyStatisticsData, now time.Time) bool {
+	if data == nil || data.UpdateTimestamp == 0 || data.RecentNormalVariance <= 0 {
+		return false
+	}
+	return now.Sub(time.Unix(data.UpdateTimestamp, 0)) <= getStatisticsExpiredDuration()
+}
+
+func getStatisticsExpiredDuration() time.Duration {
+	return 24 * time.Hour
+}
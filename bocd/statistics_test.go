@@ -0,0 +1,81 @@
+package bocd
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// hourlyTimeSeries the i-th value is at i hours after start
+func hourlyTimeSeries(start time.Time, values ...float64) *model.TimeSeries {
+	res := &model.TimeSeries{}
+	for i, v := range values {
+		res.Values = append(res.Values, model.TimeValue{Time: start.Add(time.Duration(i) * time.Hour), Value: v})
+	}
+	return res
+}
+
+func TestCalculateDailyStatisticsData(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// the outlier 1000 is out of 3 zscore, the recent 3 hours are 1, 3, 1
+	timeSeries := hourlyTimeSeries(start, 1, 3, 1, 3, 1000, 3, 1, 3, 1, 3, 1)
+
+	res, err := CalculateDailyStatisticsData(timeSeries, start, 3*time.Hour, 3)
+	if err != nil {
+		t.Fatalf("calculate failed: %v", err)
+	}
+	checks := map[string][2]float64{
+		"Mean":                 {res.Mean, 1020.0 / 11},
+		"RecentMean":           {res.RecentMean, 5.0 / 3},
+		"RecentVariance":       {res.RecentVariance, 4.0 / 3},
+		"NormalMean":           {res.NormalMean, 2},
+		"NormalVariance":       {res.NormalVariance, 10.0 / 9},
+		"RecentNormalMean":     {res.RecentNormalMean, 5.0 / 3},
+		"RecentNormalVariance": {res.RecentNormalVariance, 4.0 / 3},
+	}
+	for name, check := range checks {
+		if math.Abs(check[0]-check[1]) > 1e-9 {
+			t.Errorf("%s want %v, got %v", name, check[1], check[0])
+		}
+	}
+	if res.UpdateTimestamp != start.Unix() {
+		t.Errorf("unexpected update timestamp %d", res.UpdateTimestamp)
+	}
+}
+
+func TestNewBocdHandlerStatisticsProvider(t *testing.T) {
+	ctx := context.Background()
+	// the handler without statistics provider use the default params
+	handler, ok := NewBocdHandler(ctx, "key")
+	if !ok {
+		t.Fatalf("new handler without statistics provider failed")
+	}
+	if handler.varx != 1 || handler.mean0 != 0 {
+		t.Errorf("got the default varx %v mean0 %v", handler.varx, handler.mean0)
+	}
+	failedProvider := StatisticsProviderFunc(func(ctx context.Context, timeSeriesKey string) (*model.DailyStatisticsData,
+		error) {
+		return nil, common.ErrorInvalidValue
+	})
+	if _, ok := NewBocdHandler(ctx, "key", WithStatisticsProvider(failedProvider)); ok {
+		t.Fatalf("the handler with failed statistics provider should fail")
+	}
+
+	// the zero mean series is valid
+	timeSeries := hourlyTimeSeries(time.Now().Add(-4*time.Hour), -1, 1, -1, 1, -1)
+	provider := NewHistoryStatisticsProvider(func(ctx context.Context, timeSeriesKey string) (*model.TimeSeries, error) {
+		return timeSeries, nil
+	})
+	handler, ok = NewBocdHandler(ctx, "key", WithStatisticsProvider(provider))
+	if !ok {
+		t.Fatalf("new handler with history statistics failed")
+	}
+	// the recent 3 hours are -1, 1, -1
+	if math.Abs(handler.varx-4.0/3) > 1e-9 || math.Abs(handler.mean0+1.0/3) > 1e-9 {
+		t.Errorf("unexpected checker params varx %v mean0 %v", handler.varx, handler.mean0)
+	}
+}
@@ -0,0 +1,240 @@
+package bocd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// SuppressionContext is the change points history used by the suppression policies
+type SuppressionContext struct {
+	Now time.Time
+	// TriggeredChangePoints include the change points accepted before in the same pop
+	TriggeredChangePoints  []*model.ChangePoint
+	SuppressedChangePoints []*model.ChangePoint
+	// PendingChangePoints are the local change points not pass the observe duration yet
+	PendingChangePoints []*model.ChangePoint
+}
+
+// SuppressionPolicy decide whether a change point should not be triggered,
+// the reason is returned if the change point is suppressed
+type SuppressionPolicy interface {
+	Name() string
+	Suppress(ctx context.Context, changePoint *model.ChangePoint, suppressionCtx *SuppressionContext) (string, bool)
+}
+
+type SuppressedChangePoint struct {
+	ChangePoint *model.ChangePoint `json:"change_point"`
+	Policy      string             `json:"policy"`
+	Reason      string             `json:"reason"`
+}
+
+// applySuppressionPolicies check the change points in time order, the first policy suppress
+// the change point decide the reason. the accepted change points are seen by the later checks
+func applySuppressionPolicies(ctx context.Context, policies []SuppressionPolicy, changePoints []*model.ChangePoint,
+	suppressionCtx *SuppressionContext) ([]*model.ChangePoint, []*SuppressedChangePoint) {
+	accepted, suppressed := []*model.ChangePoint{}, []*SuppressedChangePoint{}
+
+	for _, changePoint := range changePoints {
+		var suppressedChangePoint *SuppressedChangePoint
+		for _, policy := range policies {
+			if reason, ok := policy.Suppress(ctx, changePoint, suppressionCtx); ok {
+				suppressedChangePoint = &SuppressedChangePoint{
+					ChangePoint: changePoint,
+					Policy:      policy.Name(),
+					Reason:      reason,
+				}
+				break
+			}
+		}
+
+		if suppressedChangePoint != nil {
+			suppressed = append(suppressed, suppressedChangePoint)
+			suppressionCtx.SuppressedChangePoints = append(suppressionCtx.SuppressedChangePoints, changePoint)
+			continue
+		}
+		accepted = append(accepted, changePoint)
+		suppressionCtx.TriggeredChangePoints = append(suppressionCtx.TriggeredChangePoints, changePoint)
+	}
+	return accepted, suppressed
+}
+
+// RateLimitPolicy suppress the change points if too many change points occur in recent time,
+// all the triggered, suppressed and pending change points are counted
+type RateLimitPolicy struct {
+	window   time.Duration
+	maxCount int
+}
+
+func NewRateLimitPolicy(window time.Duration, maxCount int) *RateLimitPolicy {
+	return &RateLimitPolicy{
+		window:   window,
+		maxCount: maxCount,
+	}
+}
+
+func (p *RateLimitPolicy) Name() string {
+	return "rate_limit"
+}
+
+func (p *RateLimitPolicy) Suppress(ctx context.Context, changePoint *model.ChangePoint,
+	suppressionCtx *SuppressionContext) (string, bool) {
+	startTime := suppressionCtx.Now.Add(-1 * p.window)
+	count := 0
+	for _, changePoints := range [][]*model.ChangePoint{suppressionCtx.TriggeredChangePoints,
+		suppressionCtx.SuppressedChangePoints, suppressionCtx.PendingChangePoints, {changePoint}} {
+		for _, v := range changePoints {
+			if v.TimeValue.Time.After(startTime) {
+				count++
+			}
+		}
+	}
+	if count > p.maxCount {
+		return fmt.Sprintf("%v change points in recent %v, limit %v", count, p.window, p.maxCount), true
+	}
+	return "", false
+}
+
+// DirectionCooldownPolicy suppress the change point if a change point of the same direction
+// has been triggered in cooldown duration
+type DirectionCooldownPolicy struct {
+	cooldown time.Duration
+}
+
+func NewDirectionCooldownPolicy(cooldown time.Duration) *DirectionCooldownPolicy {
+	return &DirectionCooldownPolicy{
+		cooldown: cooldown,
+	}
+}
+
+func (p *DirectionCooldownPolicy) Name() string {
+	return "direction_cooldown"
+}
+
+func (p *DirectionCooldownPolicy) Suppress(ctx context.Context, changePoint *model.ChangePoint,
+	suppressionCtx *SuppressionContext) (string, bool) {
+	lastChangePoint, ok := lastChangePointBefore(suppressionCtx.TriggeredChangePoints, changePoint,
+		func(v *model.ChangePoint) bool { return v.ChangePointType == changePoint.ChangePointType })
+	if !ok {
+		return "", false
+	}
+	elapsed := changePoint.TimeValue.Time.Sub(lastChangePoint.TimeValue.Time)
+	if elapsed < p.cooldown {
+		return fmt.Sprintf("same direction change point triggered %v ago, cooldown %v", elapsed, p.cooldown), true
+	}
+	return "", false
+}
+
+// MinMagnitudePolicy suppress the change point whose magnitude is too small, 0 means not check
+type MinMagnitudePolicy struct {
+	minMagnitude         float64
+	minRelativeMagnitude float64
+}
+
+func NewMinMagnitudePolicy(minMagnitude, minRelativeMagnitude float64) *MinMagnitudePolicy {
+	return &MinMagnitudePolicy{
+		minMagnitude:         minMagnitude,
+		minRelativeMagnitude: minRelativeMagnitude,
+	}
+}
+
+func (p *MinMagnitudePolicy) Name() string {
+	return "min_magnitude"
+}
+
+func (p *MinMagnitudePolicy) Suppress(ctx context.Context, changePoint *model.ChangePoint,
+	suppressionCtx *SuppressionContext) (string, bool) {
+	if p.minMagnitude > 0 && math.Abs(changePoint.Magnitude) < p.minMagnitude {
+		return fmt.Sprintf("magnitude %v less than %v", changePoint.Magnitude, p.minMagnitude), true
+	}
+	if p.minRelativeMagnitude > 0 && math.Abs(changePoint.RelativeMagnitude) < p.minRelativeMagnitude {
+		return fmt.Sprintf("relative magnitude %v less than %v",
+			changePoint.RelativeMagnitude, p.minRelativeMagnitude), true
+	}
+	return "", false
+}
+
+// MinSegmentDurationPolicy suppress the change point if the segment before it is too short,
+// the segment begin at the last triggered change point
+type MinSegmentDurationPolicy struct {
+	minDuration time.Duration
+}
+
+func NewMinSegmentDurationPolicy(minDuration time.Duration) *MinSegmentDurationPolicy {
+	return &MinSegmentDurationPolicy{
+		minDuration: minDuration,
+	}
+}
+
+func (p *MinSegmentDurationPolicy) Name() string {
+	return "min_segment_duration"
+}
+
+func (p *MinSegmentDurationPolicy) Suppress(ctx context.Context, changePoint *model.ChangePoint,
+	suppressionCtx *SuppressionContext) (string, bool) {
+	lastChangePoint, ok := lastChangePointBefore(suppressionCtx.TriggeredChangePoints, changePoint,
+		func(v *model.ChangePoint) bool { return true })
+	if !ok {
+		return "", false
+	}
+	duration := changePoint.TimeValue.Time.Sub(lastChangePoint.TimeValue.Time)
+	if duration < p.minDuration {
+		return fmt.Sprintf("segment duration %v less than %v", duration, p.minDuration), true
+	}
+	return "", false
+}
+
+// FlappingPolicy suppress the change point if a change point of the opposite direction
+// has been triggered in window, like increase then decrease in 10 minutes
+type FlappingPolicy struct {
+	window time.Duration
+}
+
+func NewFlappingPolicy(window time.Duration) *FlappingPolicy {
+	return &FlappingPolicy{
+		window: window,
+	}
+}
+
+func (p *FlappingPolicy) Name() string {
+	return "flapping"
+}
+
+func (p *FlappingPolicy) Suppress(ctx context.Context, changePoint *model.ChangePoint,
+	suppressionCtx *SuppressionContext) (string, bool) {
+	lastChangePoint, ok := lastChangePointBefore(suppressionCtx.TriggeredChangePoints, changePoint,
+		func(v *model.ChangePoint) bool { return v.ChangePointType != changePoint.ChangePointType })
+	if !ok {
+		return "", false
+	}
+	elapsed := changePoint.TimeValue.Time.Sub(lastChangePoint.TimeValue.Time)
+	if elapsed < p.window {
+		return fmt.Sprintf("opposite direction change point triggered %v ago, flapping window %v",
+			elapsed, p.window), true
+	}
+	return "", false
+}
+
+// lastChangePointBefore find the latest change point before the change point which match the filter
+func lastChangePointBefore(changePoints []*model.ChangePoint, changePoint *model.ChangePoint,
+	filter func(*model.ChangePoint) bool) (*model.ChangePoint, bool) {
+	var res *model.ChangePoint
+	for _, v := range changePoints {
+		if !v.TimeValue.Time.Before(changePoint.TimeValue.Time) || !filter(v) {
+			continue
+		}
+		if res == nil || v.TimeValue.Time.After(res.TimeValue.Time) {
+			res = v
+		}
+	}
+	return res, res != nil
+}
+
+func getDefaultSuppressionPolicies() []SuppressionPolicy {
+	return []SuppressionPolicy{
+		NewRateLimitPolicy(30*time.Minute, 10),
+	}
+}
@@ -0,0 +1,78 @@
+package bocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func newTestChangePoint(start time.Time, minute int, changePointType model.ChangePointType,
+	magnitude float64) *model.ChangePoint {
+	return &model.ChangePoint{
+		ChangePointType: changePointType,
+		TimeValue:       model.TimeValue{Time: start.Add(time.Duration(minute) * time.Minute)},
+		Magnitude:       magnitude,
+	}
+}
+
+func TestApplySuppressionPolicies(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	policies := []SuppressionPolicy{
+		NewRateLimitPolicy(time.Hour, 10),
+		NewDirectionCooldownPolicy(10 * time.Minute),
+		NewMinMagnitudePolicy(1, 0),
+		NewMinSegmentDurationPolicy(10 * time.Minute),
+		NewFlappingPolicy(15 * time.Minute),
+	}
+	changePoints := []*model.ChangePoint{
+		newTestChangePoint(start, 0, model.IncreaseChangePoint, 5),
+		newTestChangePoint(start, 5, model.IncreaseChangePoint, 5),
+		newTestChangePoint(start, 8, model.DecreaseChangePoint, -0.1),
+		newTestChangePoint(start, 12, model.DecreaseChangePoint, -5),
+		newTestChangePoint(start, 20, model.DecreaseChangePoint, -5),
+		newTestChangePoint(start, 25, model.IncreaseChangePoint, 5),
+	}
+
+	accepted, suppressed := applySuppressionPolicies(context.Background(), policies, changePoints,
+		&SuppressionContext{Now: start.Add(time.Hour)})
+
+	if len(accepted) != 2 || accepted[0] != changePoints[0] || accepted[1] != changePoints[4] {
+		t.Errorf("want the change points at 0 and 20 minutes accepted, got %d", len(accepted))
+	}
+	wantPolicies := []string{"direction_cooldown", "min_magnitude", "flapping", "min_segment_duration"}
+	if len(suppressed) != len(wantPolicies) {
+		t.Fatalf("want %d suppressed, got %d", len(wantPolicies), len(suppressed))
+	}
+	for i, policy := range wantPolicies {
+		if suppressed[i].Policy != policy || suppressed[i].Reason == "" {
+			t.Errorf("want suppressed by %s, got %+v", policy, suppressed[i])
+		}
+	}
+}
+
+func TestRateLimitPolicy(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := NewRateLimitPolicy(30*time.Minute, 3)
+
+	// the change point 40 minutes ago is out of the window, the pending one is counted
+	suppressionCtx := &SuppressionContext{
+		Now: start.Add(40 * time.Minute),
+		TriggeredChangePoints: []*model.ChangePoint{
+			newTestChangePoint(start, 0, model.IncreaseChangePoint, 1),
+			newTestChangePoint(start, 20, model.IncreaseChangePoint, 1),
+		},
+		PendingChangePoints: []*model.ChangePoint{newTestChangePoint(start, 38, model.IncreaseChangePoint, 1)},
+	}
+	if _, ok := policy.Suppress(ctx, newTestChangePoint(start, 30, model.DecreaseChangePoint, 1), suppressionCtx); ok {
+		t.Errorf("3 change points in window should not be suppressed")
+	}
+
+	suppressionCtx.SuppressedChangePoints = []*model.ChangePoint{newTestChangePoint(start, 25, model.IncreaseChangePoint, 1)}
+	if reason, ok := policy.Suppress(ctx, newTestChangePoint(start, 30, model.DecreaseChangePoint, 1),
+		suppressionCtx); !ok || reason != "4 change points in recent 30m0s, limit 3" {
+		t.Errorf("4 change points in window should be suppressed, got %q", reason)
+	}
+}
@@ -0,0 +1,223 @@
+package bocd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+)
+
+// TriggerStateStore store the BocdTriggerData shared by all the containers,
+// so that each change point will only trigger once.
+// the version is used for compare and swap, version 0 means the key not exist yet
+type TriggerStateStore interface {
+	Get(ctx context.Context, key string) (*BocdTriggerData, int64, error)
+	// CompareAndSwap set the data only if the stored version still equal version,
+	// return false if the data has been changed by others
+	CompareAndSwap(ctx context.Context, key string, version int64, data *BocdTriggerData) (bool, error)
+}
+
+type triggerStateRecord struct {
+	Version int64            `json:"version"`
+	Data    *BocdTriggerData `json:"data"`
+}
+
+// MemoryTriggerStateStore only share the trigger data in one process
+type MemoryTriggerStateStore struct {
+	mu      sync.Mutex
+	records map[string]*triggerStateRecord
+}
+
+func NewMemoryTriggerStateStore() *MemoryTriggerStateStore {
+	return &MemoryTriggerStateStore{
+		records: map[string]*triggerStateRecord{},
+	}
+}
+
+func (s *MemoryTriggerStateStore) Get(ctx context.Context, key string) (*BocdTriggerData, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return nil, 0, nil
+	}
+	data, err := copyTriggerData(record.Data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, record.Version, nil
+}
+
+func (s *MemoryTriggerStateStore) CompareAndSwap(ctx context.Context, key string,
+	version int64, data *BocdTriggerData) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nowVersion int64
+	if record, ok := s.records[key]; ok {
+		nowVersion = record.Version
+	}
+	if nowVersion != version {
+		return false, nil
+	}
+
+	data, err := copyTriggerData(data)
+	if err != nil {
+		return false, err
+	}
+	s.records[key] = &triggerStateRecord{
+		Version: version + 1,
+		Data:    data,
+	}
+	return true, nil
+}
+
+// copy by json so that the caller can't modify the stored data
+func copyTriggerData(data *BocdTriggerData) (*BocdTriggerData, error) {
+	if data == nil {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	res := &BocdTriggerData{}
+	if err := json.Unmarshal(bytes, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// FileTriggerStateStore store each key in a json file under dir, the flock of a lock file
+// is used to make the compare and swap atomic between processes, only supported on unix
+type FileTriggerStateStore struct {
+	dir         string
+	lockTimeout time.Duration // max wait time of the lock
+}
+
+func NewFileTriggerStateStore(dir string) (*FileTriggerStateStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileTriggerStateStore{
+		dir:         dir,
+		lockTimeout: 5 * time.Second,
+	}, nil
+}
+
+func (s *FileTriggerStateStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".json")
+}
+
+func (s *FileTriggerStateStore) Get(ctx context.Context, key string) (*BocdTriggerData, int64, error) {
+	record, err := s.read(key)
+	if err != nil || record == nil {
+		return nil, 0, err
+	}
+	return record.Data, record.Version, nil
+}
+
+func (s *FileTriggerStateStore) CompareAndSwap(ctx context.Context, key string,
+	version int64, data *BocdTriggerData) (bool, error) {
+	unlock, err := s.lock(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	record, err := s.read(key)
+	if err != nil {
+		return false, err
+	}
+	var nowVersion int64
+	if record != nil {
+		nowVersion = record.Version
+	}
+	if nowVersion != version {
+		return false, nil
+	}
+
+	bytes, err := json.Marshal(&triggerStateRecord{
+		Version: version + 1,
+		Data:    data,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// write to a temp file then rename, so the reader never see a partial file
+	tmpFile, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(bytes); err != nil {
+		tmpFile.Close()
+		return false, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpFile.Name(), s.path(key)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FileTriggerStateStore) read(key string) (*triggerStateRecord, error) {
+	bytes, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &triggerStateRecord{}
+	if err := json.Unmarshal(bytes, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// lock hold the flock of the lock file, the kernel release it when the holder process dies,
+// so there is no stale lock to take over. the lock file is never removed, otherwise two processes
+// may lock the different files of the same path
+func (s *FileTriggerStateStore) lock(ctx context.Context, key string) (func(), error) {
+	file, err := os.OpenFile(s.path(key)+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(s.lockTimeout)
+
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				unlockFile(file)
+				file.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, common.ErrorLockTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
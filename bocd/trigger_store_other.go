@@ -0,0 +1,14 @@
+//go:build !unix
+
+package bocd
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLockFile(file *os.File) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+func unlockFile(file *os.File) {}
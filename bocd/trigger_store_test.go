@@ -0,0 +1,99 @@
+package bocd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+)
+
+// incrementTrigger retry the compare and swap until it succeeds
+func incrementTrigger(t *testing.T, store TriggerStateStore, key string) {
+	ctx := context.Background()
+	for {
+		data, version, err := store.Get(ctx, key)
+		if err != nil {
+			t.Errorf("get failed: %v", err)
+			return
+		}
+		if data == nil {
+			data = NewBocdTriggerData()
+		}
+		data.LastTriggerPointTime = data.LastTriggerPointTime.Add(time.Minute)
+		ok, err := store.CompareAndSwap(ctx, key, version, data)
+		if err != nil {
+			t.Errorf("compare and swap failed: %v", err)
+			return
+		}
+		if ok {
+			return
+		}
+	}
+}
+
+func testTriggerStateStore(t *testing.T, store TriggerStateStore) {
+	ctx := context.Background()
+	if ok, err := store.CompareAndSwap(ctx, "key", 1, NewBocdTriggerData()); ok || err != nil {
+		t.Fatalf("swap with the wrong version should fail, ok %v err %v", ok, err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				incrementTrigger(t, store, "key")
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, version, err := store.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if version != 80 || data.LastTriggerPointTime != (time.Time{}).Add(80*time.Minute) {
+		t.Errorf("want 80 swaps, got version %d time %v", version, data.LastTriggerPointTime)
+	}
+}
+
+func TestMemoryTriggerStateStore(t *testing.T) {
+	testTriggerStateStore(t, NewMemoryTriggerStateStore())
+}
+
+func TestFileTriggerStateStore(t *testing.T) {
+	store, err := NewFileTriggerStateStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	testTriggerStateStore(t, store)
+}
+
+func TestFileTriggerStateStoreLock(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileTriggerStateStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	store.lockTimeout = 50 * time.Millisecond
+
+	unlock, err := store.lock(ctx, "key")
+	if err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if _, err := store.lock(ctx, "key"); !errors.Is(err, common.ErrorLockTimeout) {
+		t.Fatalf("want lock timeout, got %v", err)
+	}
+	unlock()
+
+	// the lock file is kept, the next holder lock the same file
+	unlock, err = store.lock(ctx, "key")
+	if err != nil {
+		t.Fatalf("lock after unlock failed: %v", err)
+	}
+	unlock()
+}
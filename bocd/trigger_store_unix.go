@@ -0,0 +1,22 @@
+//go:build unix
+
+package bocd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile return false if the file is locked by others
+func tryLockFile(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -5,9 +5,11 @@ import (
 	"math"
 	"time"
 
+	"github.com/uyouii/timeseries-algorithms/common"
 	"github.com/uyouii/timeseries-algorithms/model"
 	"github.com/uyouii/timeseries-algorithms/utils"
 	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat"
 )
 
 func LogSumExp(data []float64) float64 {
@@ -43,14 +45,55 @@ func getObserveDuration() time.Duration {
 	return 5 * time.Minute
 }
 
+func getExpectedInterval() time.Duration {
+	return time.Minute
+}
+
+func getMaxGap() time.Duration {
+	return 10 * time.Minute
+}
+
 func getChangePointThreshold() float64 {
 	return 0.75
 }
 
+// getBatchPosteriorMinProbability the batch posterior is O(n^2) if all the run lengths are kept
+func getBatchPosteriorMinProbability() float64 {
+	return 1e-4
+}
+
+// getMinRunLengthProbability the multivariate checker prune the run lengths, each of them cost a cholesky
+func getMinRunLengthProbability() float64 {
+	return 1e-6
+}
+
+func getMaxRunLengthCount() int {
+	return 100
+}
+
+// getSinkTimeout the sinks run in the pop, the webhook retries can take about a minute without it
+func getSinkTimeout() time.Duration {
+	return 10 * time.Second
+}
+
 func hazard() float64 {
 	return 2 / 1000.0
 }
 
+func segmentMeanVariance(datas []model.TimeValue) (float64, float64) {
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		values = append(values, timeValue.Value)
+	}
+	if len(values) == 0 {
+		return 0, 0
+	}
+	if len(values) == 1 {
+		return values[0], 0
+	}
+	return stat.MeanVariance(values, nil)
+}
+
 func IntMin(i1, i2 int) int {
 	if i1 < i2 {
 		return i1
@@ -58,6 +101,20 @@ func IntMin(i1, i2 int) int {
 	return i2
 }
 
+func IntMax(i1, i2 int) int {
+	if i1 > i2 {
+		return i1
+	}
+	return i2
+}
+
+func IntAbs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
 func ListMul(l1, l2 []float64) []float64 {
 	listLen := IntMin(len(l1), len(l2))
 
@@ -69,10 +126,15 @@ func ListMul(l1, l2 []float64) []float64 {
 }
 
 func RemoveOldChangePoints(changePoints []*model.ChangePoint) []*model.ChangePoint {
+	return RemoveOldChangePointsAt(time.Now(), changePoints)
+}
+
+// RemoveOldChangePointsAt is RemoveOldChangePoints with the given current time, like the time of an injected clock
+func RemoveOldChangePointsAt(now time.Time, changePoints []*model.ChangePoint) []*model.ChangePoint {
 	res := []*model.ChangePoint{}
 
 	var MaxDuration = 24 * time.Hour
-	startTime := time.Now().Add(-1 * MaxDuration)
+	startTime := now.Add(-1 * MaxDuration)
 
 	for _, changePoint := range changePoints {
 		if changePoint.TimeValue.Time.Before(startTime) {
@@ -88,7 +150,13 @@ func getMaxTracebackDuration() time.Duration {
 }
 
 func GetChangePointCountInRecentTime(duration time.Duration, changePoints []*model.ChangePoint) int {
-	startTime := time.Now().Add(-1 * duration)
+	return GetChangePointCountInRecentTimeAt(time.Now(), duration, changePoints)
+}
+
+// GetChangePointCountInRecentTimeAt is GetChangePointCountInRecentTime with the given current time
+func GetChangePointCountInRecentTimeAt(now time.Time, duration time.Duration,
+	changePoints []*model.ChangePoint) int {
+	startTime := now.Add(-1 * duration)
 	res := 0
 	for _, changePoint := range changePoints {
 		if changePoint.TimeValue.Time.After(startTime) {
@@ -98,20 +166,19 @@ func GetChangePointCountInRecentTime(duration time.Duration, changePoints []*mod
 	return res
 }
 
-// TODO: need a calcualte data daily statistics data process
-// Important: this is a function to get timeSeries data daily statistics data
+// GetNormalStatisticData is the default StatisticsProvider of BocdHandler, it has no data source and
+// always return common.ErrorStatisticsNotConfigured, the handler created without WithStatisticsProvider
+// or WithBocdConfig use the default varx and mean0. use HistoryStatisticsProvider to calculate the statistics
+// data from history
 func GetNormalStatisticData(ctx context.Context, timeSeriesKey string) (*model.DailyStatisticsData, error) {
 	logger := utils.GetLogger(ctx)
 
-	// speedHelper := speed_helper.GetSpeedHelper()
-	// dailyStatisticsData, err := speedHelper.GetDailyStatisticsData(ctx, service, checkType, labels)
-	// if err != nil {
-	// 	logger.Error("GetDailyStatisticsData failed", zap.Error(err))
-	// 	return nil, err
-	// }
-
-	res := &model.DailyStatisticsData{}
+	logger.Warn("statistics provider not configured, use WithStatisticsProvider or WithBocdConfig",
+		zap.String("timeSeriesKey", timeSeriesKey))
+	return nil, common.ErrorStatisticsNotConfigured
+}
 
-	logger.Info("GetDailyStatisticsData success", zap.Any("dailyStatisticsData", res))
-	return res, nil
+// getDefaultVarxMean0 is used when the statistics provider is not configured
+func getDefaultVarxMean0() (float64, float64) {
+	return 1, 0
 }
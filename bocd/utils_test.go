@@ -0,0 +1,41 @@
+package bocd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+)
+
+func TestChangePointUtilsWithClock(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewManualClock(now)
+
+	changePoints := []*model.ChangePoint{}
+	for _, ago := range []time.Duration{25 * time.Hour, 23*time.Hour + 30*time.Minute, 20 * time.Minute, 5 * time.Minute} {
+		changePoints = append(changePoints, &model.ChangePoint{TimeValue: model.TimeValue{Time: now.Add(-ago)}})
+	}
+
+	if res := RemoveOldChangePointsAt(clock.Now(), changePoints); len(res) != 3 || res[0] != changePoints[1] {
+		t.Errorf("want the change points in 24 hours kept, got %d", len(res))
+	}
+	if cnt := GetChangePointCountInRecentTimeAt(clock.Now(), 30*time.Minute, changePoints); cnt != 2 {
+		t.Errorf("want 2 change points in 30 minutes, got %d", cnt)
+	}
+
+	clock.Advance(time.Hour)
+	if cnt := GetChangePointCountInRecentTimeAt(clock.Now(), 30*time.Minute, changePoints); cnt != 0 {
+		t.Errorf("want no change point in 30 minutes after an hour, got %d", cnt)
+	}
+	if res := RemoveOldChangePointsAt(clock.Now(), changePoints); len(res) != 2 {
+		t.Errorf("want 2 change points kept after an hour, got %d", len(res))
+	}
+
+	// the functions without the time use the current time
+	recent := []*model.ChangePoint{{TimeValue: model.TimeValue{Time: time.Now().Add(-10 * time.Minute)}}}
+	if len(RemoveOldChangePoints(append(recent, changePoints...))) != 1 ||
+		GetChangePointCountInRecentTime(30*time.Minute, recent) != 1 {
+		t.Errorf("want only the recent change point kept")
+	}
+}
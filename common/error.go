@@ -3,5 +3,9 @@ package common
 import "errors"
 
 var (
-	ErrorInvalidValue = errors.New("invalid value")
+	ErrorInvalidValue     = errors.New("invalid value")
+	ErrorLockTimeout      = errors.New("lock timeout")
+	ErrorUnexpectedStatus = errors.New("unexpected status")
+
+	ErrorStatisticsNotConfigured = errors.New("statistics provider not configured")
 )
@@ -0,0 +1,50 @@
+package controlchart
+
+import (
+	"math"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// controlBaseline is the in control level, the center and sigma come from the recent normal
+// mean and variance of the daily statistics, the same as the bocd handler
+type controlBaseline struct {
+	center float64
+	sigma  float64
+}
+
+func newControlBaseline(baseline *model.DailyStatisticsData) (*controlBaseline, error) {
+	if baseline == nil || baseline.RecentNormalVariance <= 0 || math.IsNaN(baseline.RecentNormalVariance) {
+		return nil, common.ErrorInvalidValue
+	}
+	return &controlBaseline{
+		center: baseline.RecentNormalMean,
+		sigma:  math.Sqrt(baseline.RecentNormalVariance),
+	}, nil
+}
+
+func newChangePoint(start, current model.TimeValue, center, postMean float64, rule string) *model.ChangePoint {
+	changePoint := &model.ChangePoint{
+		TimeValue:      start,
+		DetectTime:     current.Time,
+		DetectionDelay: current.Time.Sub(start.Time),
+		PreMean:        center,
+		PostMean:       postMean,
+		Magnitude:      postMean - center,
+		Rule:           rule,
+	}
+	if center != 0 {
+		changePoint.RelativeMagnitude = changePoint.Magnitude / math.Abs(center)
+	}
+	if changePoint.Magnitude > 0 {
+		changePoint.ChangePointType = model.IncreaseChangePoint
+	} else {
+		changePoint.ChangePointType = model.DecreaseChangePoint
+	}
+	return changePoint
+}
+
+func invalidValue(value float64) bool {
+	return math.IsNaN(value) || math.IsInf(value, 0)
+}
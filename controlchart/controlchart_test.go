@@ -0,0 +1,168 @@
+package controlchart
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// unitBaseline is the center 0 and sigma 1
+var unitBaseline = &model.DailyStatisticsData{RecentNormalMean: 0, RecentNormalVariance: 1}
+
+type chart interface {
+	AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool)
+}
+
+// appendValues append the minutely points, return the index of the detected points and the change points
+func appendValues(c chart, values ...float64) ([]int, []*model.ChangePoint) {
+	indexes, changePoints := []int{}, []*model.ChangePoint{}
+	for i, value := range values {
+		timeValue := model.TimeValue{Time: testStart.Add(time.Duration(i) * time.Minute), Value: value}
+		if changePoint, ok := c.AppendPoint(context.Background(), timeValue); ok {
+			indexes = append(indexes, i)
+			changePoints = append(changePoints, changePoint)
+		}
+	}
+	return indexes, changePoints
+}
+
+func repeat(value float64, cnt int) []float64 {
+	res := make([]float64, cnt)
+	for i := range res {
+		res[i] = value
+	}
+	return res
+}
+
+func TestNewChartInvalidBaseline(t *testing.T) {
+	for _, baseline := range []*model.DailyStatisticsData{nil, {RecentNormalVariance: 0},
+		{RecentNormalVariance: math.NaN()}} {
+		if _, err := NewEwmaChart(baseline); !errors.Is(err, common.ErrorInvalidValue) {
+			t.Errorf("ewma got error %v for baseline %+v", err, baseline)
+		}
+		if _, err := NewShewhartChart(baseline); !errors.Is(err, common.ErrorInvalidValue) {
+			t.Errorf("shewhart got error %v for baseline %+v", err, baseline)
+		}
+	}
+}
+
+func TestEwmaChartLimits(t *testing.T) {
+	c, err := NewEwmaChart(&model.DailyStatisticsData{RecentNormalMean: 10, RecentNormalVariance: 4})
+	if err != nil {
+		t.Fatalf("new chart failed: %v", err)
+	}
+	appendValues(c, 15)
+	// 3 * 2 * sqrt(0.2 / 1.8 * (1 - 0.8^2)) = 1.2
+	lower, upper := c.Limits()
+	if math.Abs(lower-8.8) > 1e-12 || math.Abs(upper-11.2) > 1e-12 || math.Abs(c.Ewma()-11) > 1e-12 {
+		t.Errorf("got limits %v %v ewma %v, expected 8.8 11.2 and 11", lower, upper, c.Ewma())
+	}
+
+	// the limits approach 3 * 2 * sqrt(0.2 / 1.8) = 2
+	appendValues(c, repeat(10, 100)...)
+	if lower, upper = c.Limits(); math.Abs(upper-12) > 1e-9 || math.Abs(lower-8) > 1e-9 {
+		t.Errorf("got limits %v %v, expected 8 12", lower, upper)
+	}
+}
+
+func TestEwmaChart(t *testing.T) {
+	c, err := NewEwmaChart(unitBaseline)
+	if err != nil {
+		t.Fatalf("new chart failed: %v", err)
+	}
+	// the ewma cross the center at the first 2 and leave the limit about 1 at the 5th,
+	// then cross the center at the third -2 and leave the lower limit about -1 at the 6th
+	values := append(append(repeat(-0.5, 10), repeat(2, 10)...), repeat(-2, 10)...)
+	indexes, changePoints := appendValues(c, values...)
+
+	if len(indexes) != 2 || indexes[0] != 14 || indexes[1] != 25 {
+		t.Fatalf("got alarms at %v, expected [14 25]", indexes)
+	}
+	increase, decrease := changePoints[0], changePoints[1]
+	if increase.ChangePointType != model.IncreaseChangePoint || increase.Rule != RuleEwma ||
+		!increase.TimeValue.Time.Equal(testStart.Add(10*time.Minute)) || increase.PostMean != 2 ||
+		increase.DetectionDelay != 4*time.Minute {
+		t.Errorf("unexpected increase change point %+v", increase)
+	}
+	if decrease.ChangePointType != model.DecreaseChangePoint ||
+		!decrease.TimeValue.Time.Equal(testStart.Add(22*time.Minute)) || decrease.PostMean != -2 {
+		t.Errorf("unexpected decrease change point %+v", decrease)
+	}
+	if last, ok := c.LastChangePoint(); !ok || !last.TimeValue.Time.Equal(decrease.TimeValue.Time) {
+		t.Errorf("got last change point %+v", last)
+	}
+
+	if err := c.SetBaseline(&model.DailyStatisticsData{RecentNormalMean: -2, RecentNormalVariance: 1}); err != nil {
+		t.Fatalf("set baseline failed: %v", err)
+	}
+	if indexes, _ := appendValues(c, repeat(-2, 20)...); len(indexes) != 0 || c.Ewma() != -2 {
+		t.Errorf("got alarms at %v and ewma %v on the new baseline", indexes, c.Ewma())
+	}
+}
+
+func TestShewhartChartRules(t *testing.T) {
+	testCases := []struct {
+		values      []float64
+		index       int
+		start       int
+		rule        string
+		postMean    float64
+		changeType  model.ChangePointType
+		subgroupCnt int
+	}{
+		{[]float64{0, 0, 3.5}, 2, 2, RuleBeyond3Sigma, 3.5, model.IncreaseChangePoint, 1},
+		{[]float64{0, 0, -3.5}, 2, 2, RuleBeyond3Sigma, -3.5, model.DecreaseChangePoint, 1},
+		{[]float64{0, 2.5, 0, 2.5}, 3, 1, Rule2Of3Beyond2, 2.5, model.IncreaseChangePoint, 1},
+		{[]float64{0, -1.5, -1.5, 0, -1.5, -1.5}, 5, 1, Rule4Of5Beyond1, -1.5, model.DecreaseChangePoint, 1},
+		{append([]float64{-1}, repeat(0.5, 8)...), 8, 1, Rule8OnSameSide, 0.5, model.IncreaseChangePoint, 1},
+		// the sigma of the mean of 4 points is 0.5
+		{[]float64{0, 0, 0, 0, 1.6, 1.6, 1.6, 1.6}, 7, 4, RuleBeyond3Sigma, 1.6, model.IncreaseChangePoint, 4},
+	}
+	for i, testCase := range testCases {
+		c, err := NewShewhartChart(unitBaseline, WithSubgroupSize(testCase.subgroupCnt))
+		if err != nil {
+			t.Fatalf("new chart failed: %v", err)
+		}
+		indexes, changePoints := appendValues(c, testCase.values...)
+		if len(indexes) != 1 || indexes[0] != testCase.index {
+			t.Errorf("case %v got alarms at %v, expected [%v]", i, indexes, testCase.index)
+			continue
+		}
+		changePoint := changePoints[0]
+		if changePoint.Rule != testCase.rule || changePoint.ChangePointType != testCase.changeType ||
+			!changePoint.TimeValue.Time.Equal(testStart.Add(time.Duration(testCase.start)*time.Minute)) ||
+			math.Abs(changePoint.PostMean-testCase.postMean) > 1e-12 {
+			t.Errorf("case %v got change point %+v", i, changePoint)
+		}
+	}
+}
+
+func TestShewhartChartOnlyFireOnce(t *testing.T) {
+	c, err := NewShewhartChart(unitBaseline, WithRules(Rule8OnSameSide))
+	if err != nil {
+		t.Fatalf("new chart failed: %v", err)
+	}
+	// the beyond 3 sigma rule is disabled, the history is cleared after the rule fired
+	indexes, _ := appendValues(c, append([]float64{5}, repeat(0.5, 16)...)...)
+	if len(indexes) != 2 || indexes[0] != 7 || indexes[1] != 15 {
+		t.Errorf("got alarms at %v, expected [7 15]", indexes)
+	}
+
+	if lower, upper := c.Limits(); lower != -3 || upper != 3 {
+		t.Errorf("got limits %v %v, expected -3 3", lower, upper)
+	}
+}
+
+func TestShewhartChartUnknownRule(t *testing.T) {
+	if _, err := NewShewhartChart(unitBaseline, WithRules(Rule8OnSameSide, "western_electric_5")); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the unknown rule", err)
+	}
+}
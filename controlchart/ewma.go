@@ -0,0 +1,159 @@
+package controlchart
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+const RuleEwma = "ewma"
+
+// EwmaChart is the exponentially weighted moving average chart,
+//
+//	z = lambda * x + (1 - lambda) * z, limits = center ± L * sigma * sqrt(lambda / (2 - lambda) * (1 - (1 - lambda)^2t))
+//
+// it's more sensitive to the small sustained shift than the shewhart chart.
+// the chart alarm once when the ewma leave the limits, and alarm again only after it cross the center.
+// safe for concurrent use
+type EwmaChart struct {
+	mu sync.Mutex
+
+	baseline *controlBaseline
+	lambda   float64
+	width    float64 // L, the limit width in the unit of the ewma sigma
+
+	ewma    float64
+	steps   int
+	alarmed bool
+
+	// the points since the ewma last cross the center, the change point is the first of them
+	runStart model.TimeValue
+	runSide  float64
+	runSum   float64
+	runCnt   int
+
+	lastChangePoint *model.ChangePoint
+}
+
+type EwmaOption func(*EwmaChart)
+
+// WithLambda the weight of the new point, in (0, 1], default is 0.2
+func WithLambda(lambda float64) EwmaOption {
+	return func(c *EwmaChart) {
+		if lambda > 0 && lambda <= 1 {
+			c.lambda = lambda
+		}
+	}
+}
+
+// WithLimitWidth the L of the control limits, default is 3
+func WithLimitWidth(width float64) EwmaOption {
+	return func(c *EwmaChart) {
+		if width > 0 {
+			c.width = width
+		}
+	}
+}
+
+func NewEwmaChart(baseline *model.DailyStatisticsData, opts ...EwmaOption) (*EwmaChart, error) {
+	controlBaseline, err := newControlBaseline(baseline)
+	if err != nil {
+		return nil, err
+	}
+	chart := &EwmaChart{
+		baseline: controlBaseline,
+		lambda:   0.2,
+		width:    3,
+		ewma:     controlBaseline.center,
+	}
+	for _, opt := range opts {
+		opt(chart)
+	}
+	return chart, nil
+}
+
+func (c *EwmaChart) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	logger := utils.GetLogger(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if invalidValue(timeValue.Value) {
+		logger.Debug("skip invalid point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+
+	c.steps++
+	c.ewma = c.lambda*timeValue.Value + (1-c.lambda)*c.ewma
+
+	side := 1.0
+	if c.ewma < c.baseline.center {
+		side = -1
+	}
+	if side != c.runSide || c.runCnt == 0 {
+		c.runStart, c.runSide, c.runSum, c.runCnt = timeValue, side, 0, 0
+		c.alarmed = false
+	}
+	c.runSum += timeValue.Value
+	c.runCnt++
+
+	lower, upper := c.limits()
+	if (c.ewma >= lower && c.ewma <= upper) || c.alarmed {
+		return nil, false
+	}
+	c.alarmed = true
+
+	changePoint := newChangePoint(c.runStart, timeValue, c.baseline.center,
+		c.runSum/float64(c.runCnt), RuleEwma)
+	c.lastChangePoint = changePoint
+	copied := *changePoint
+	return &copied, true
+}
+
+// limits use the exact variance of the steps, so the first points are not too loose
+func (c *EwmaChart) limits() (float64, float64) {
+	steps := max(c.steps, 1)
+	variance := c.lambda / (2 - c.lambda) * (1 - math.Pow(1-c.lambda, float64(2*steps)))
+	halfWidth := c.width * c.baseline.sigma * math.Sqrt(variance)
+	return c.baseline.center - halfWidth, c.baseline.center + halfWidth
+}
+
+// Limits return the current lower and upper limits of the ewma
+func (c *EwmaChart) Limits() (float64, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limits()
+}
+
+func (c *EwmaChart) Ewma() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ewma
+}
+
+// SetBaseline update the control limits and restart the ewma from the new center
+func (c *EwmaChart) SetBaseline(baseline *model.DailyStatisticsData) error {
+	controlBaseline, err := newControlBaseline(baseline)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baseline = controlBaseline
+	c.ewma, c.steps, c.alarmed, c.runCnt = controlBaseline.center, 0, false, 0
+	return nil
+}
+
+func (c *EwmaChart) LastChangePoint() (*model.ChangePoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastChangePoint == nil {
+		return nil, false
+	}
+	copied := *c.lastChangePoint
+	return &copied, true
+}
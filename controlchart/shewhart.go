@@ -0,0 +1,217 @@
+package controlchart
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// the western electric rules, the zones are in the unit of the sigma of the subgroup mean
+const (
+	RuleBeyond3Sigma  = "western_electric_1" // one point beyond 3 sigma
+	Rule2Of3Beyond2   = "western_electric_2" // 2 of 3 consecutive points beyond 2 sigma on the same side
+	Rule4Of5Beyond1   = "western_electric_3" // 4 of 5 consecutive points beyond 1 sigma on the same side
+	Rule8OnSameSide   = "western_electric_4" // 8 consecutive points on the same side of the center
+	maxRuleWindowSize = 8
+)
+
+type westernElectricRule struct {
+	name       string
+	windowSize int
+	hitCnt     int
+	zone       float64
+}
+
+var westernElectricRules = []westernElectricRule{
+	{name: RuleBeyond3Sigma, windowSize: 1, hitCnt: 1, zone: 3},
+	{name: Rule2Of3Beyond2, windowSize: 3, hitCnt: 2, zone: 2},
+	{name: Rule4Of5Beyond1, windowSize: 5, hitCnt: 4, zone: 1},
+	{name: Rule8OnSameSide, windowSize: 8, hitCnt: 8, zone: 0},
+}
+
+type subgroupPoint struct {
+	start model.TimeValue // the first point of the subgroup
+	mean  float64
+	z     float64
+}
+
+// ShewhartChart is the x-bar chart with the western electric rules, the points are grouped
+// by the subgroup size, the rules are checked on the subgroup means. safe for concurrent use
+type ShewhartChart struct {
+	mu sync.Mutex
+
+	baseline     *controlBaseline
+	subgroupSize int
+	rules        map[string]bool
+
+	subgroup []model.TimeValue
+	history  []subgroupPoint // the recent subgroup means, at most maxRuleWindowSize
+
+	lastChangePoint *model.ChangePoint
+}
+
+type ShewhartOption func(*ShewhartChart)
+
+// WithSubgroupSize default is 1, which is the individuals chart
+func WithSubgroupSize(subgroupSize int) ShewhartOption {
+	return func(c *ShewhartChart) {
+		if subgroupSize > 0 {
+			c.subgroupSize = subgroupSize
+		}
+	}
+}
+
+// WithRules only check the rules, default check all the western electric rules,
+// NewShewhartChart return common.ErrorInvalidValue if there is unknown rule name
+func WithRules(rules ...string) ShewhartOption {
+	return func(c *ShewhartChart) {
+		c.rules = map[string]bool{}
+		for _, rule := range rules {
+			c.rules[rule] = true
+		}
+	}
+}
+
+func NewShewhartChart(baseline *model.DailyStatisticsData, opts ...ShewhartOption) (*ShewhartChart, error) {
+	controlBaseline, err := newControlBaseline(baseline)
+	if err != nil {
+		return nil, err
+	}
+	chart := &ShewhartChart{
+		baseline:     controlBaseline,
+		subgroupSize: 1,
+		rules:        map[string]bool{},
+		subgroup:     []model.TimeValue{},
+		history:      []subgroupPoint{},
+	}
+	for _, rule := range westernElectricRules {
+		chart.rules[rule.name] = true
+	}
+	for _, opt := range opts {
+		opt(chart)
+	}
+	for name := range chart.rules {
+		if !knownRule(name) {
+			return nil, common.ErrorInvalidValue
+		}
+	}
+	return chart, nil
+}
+
+func knownRule(name string) bool {
+	for _, rule := range westernElectricRules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendPoint check the rules when a subgroup is full, the history is cleared after a rule fired,
+// so the same points won't fire again
+func (c *ShewhartChart) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	logger := utils.GetLogger(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if invalidValue(timeValue.Value) {
+		logger.Debug("skip invalid point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+
+	c.subgroup = append(c.subgroup, timeValue)
+	if len(c.subgroup) < c.subgroupSize {
+		return nil, false
+	}
+
+	sum := 0.0
+	for _, v := range c.subgroup {
+		sum += v.Value
+	}
+	mean := sum / float64(len(c.subgroup))
+	sigma := c.baseline.sigma / math.Sqrt(float64(c.subgroupSize))
+	c.history = append(c.history, subgroupPoint{
+		start: c.subgroup[0],
+		mean:  mean,
+		z:     (mean - c.baseline.center) / sigma,
+	})
+	if len(c.history) > maxRuleWindowSize {
+		c.history = c.history[len(c.history)-maxRuleWindowSize:]
+	}
+	c.subgroup = c.subgroup[:0]
+
+	changePoint, found := c.checkRules(timeValue)
+	if !found {
+		return nil, false
+	}
+	c.history = c.history[:0]
+	c.lastChangePoint = changePoint
+	copied := *changePoint
+	return &copied, true
+}
+
+// checkRules check the rules in order, the window must end at the last subgroup
+func (c *ShewhartChart) checkRules(current model.TimeValue) (*model.ChangePoint, bool) {
+	for _, rule := range westernElectricRules {
+		if !c.rules[rule.name] || len(c.history) < rule.windowSize {
+			continue
+		}
+		window := c.history[len(c.history)-rule.windowSize:]
+		for _, side := range []float64{1, -1} {
+			hits := []subgroupPoint{}
+			for _, point := range window {
+				if point.z*side > rule.zone {
+					hits = append(hits, point)
+				}
+			}
+			// the last point must be a hit, otherwise the rule has fired at the last subgroup
+			if len(hits) < rule.hitCnt || window[len(window)-1].z*side <= rule.zone {
+				continue
+			}
+			sum := 0.0
+			for _, point := range hits {
+				sum += point.mean
+			}
+			return newChangePoint(hits[0].start, current, c.baseline.center,
+				sum/float64(len(hits)), rule.name), true
+		}
+	}
+	return nil, false
+}
+
+// Limits return the lower and upper 3 sigma limits of the subgroup mean
+func (c *ShewhartChart) Limits() (float64, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sigma := c.baseline.sigma / math.Sqrt(float64(c.subgroupSize))
+	return c.baseline.center - 3*sigma, c.baseline.center + 3*sigma
+}
+
+// SetBaseline update the control limits, like after the daily statistics updated
+func (c *ShewhartChart) SetBaseline(baseline *model.DailyStatisticsData) error {
+	controlBaseline, err := newControlBaseline(baseline)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baseline = controlBaseline
+	c.history = c.history[:0]
+	return nil
+}
+
+func (c *ShewhartChart) LastChangePoint() (*model.ChangePoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastChangePoint == nil {
+		return nil, false
+	}
+	copied := *c.lastChangePoint
+	return &copied, true
+}
@@ -0,0 +1,125 @@
+package cusum
+
+import (
+	"context"
+	"sync"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// CusumDetector is the two sided tabular cusum on the standardized value,
+//
+//	upper = max(0, upper + z - k), lower = max(0, lower - z - k), z = (x - mean) / stddev
+//
+// the change point is the first point after the sum last left 0.
+// the state and the cost of each point are O(1), safe for concurrent use
+type CusumDetector struct {
+	mu sync.Mutex
+
+	mean   float64
+	stddev float64
+	config *detectorConfig
+
+	upperSum float64
+	lowerSum float64
+	upperRun runStat
+	lowerRun runStat
+	// only used when not reset on detect, each direction alarm once until its sum back under the threshold
+	upperAlarmed bool
+	lowerAlarmed bool
+
+	lastChangePoint *model.ChangePoint
+}
+
+// NewCusumDetector mean and stddev are the in control level, stddev must be positive
+func NewCusumDetector(mean, stddev float64, opts ...Option) *CusumDetector {
+	return &CusumDetector{
+		mean:   mean,
+		stddev: stddev,
+		config: newDetectorConfig(0.5, 5, opts...),
+	}
+}
+
+func (d *CusumDetector) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	logger := utils.GetLogger(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if invalidValue(timeValue.Value) || d.stddev <= 0 {
+		logger.Debug("skip invalid point", zap.Any("timeValue", timeValue), zap.Float64("stddev", d.stddev))
+		return nil, false
+	}
+
+	z := (timeValue.Value - d.mean) / d.stddev
+	if d.upperSum == 0 {
+		d.upperRun.begin(timeValue)
+	}
+	if d.lowerSum == 0 {
+		d.lowerRun.begin(timeValue)
+	}
+	d.upperSum = max(0, d.upperSum+z-d.config.drift)
+	d.lowerSum = max(0, d.lowerSum-z-d.config.drift)
+	d.upperRun.add(timeValue.Value)
+	d.lowerRun.add(timeValue.Value)
+
+	var changePoint *model.ChangePoint
+	switch {
+	case d.upperSum > d.config.threshold && !d.upperAlarmed:
+		changePoint = newChangePoint(&d.upperRun, d.mean, timeValue, model.IncreaseChangePoint)
+		d.upperAlarmed = !d.config.resetOnDetect
+	case d.lowerSum > d.config.threshold && !d.lowerAlarmed:
+		changePoint = newChangePoint(&d.lowerRun, d.mean, timeValue, model.DecreaseChangePoint)
+		d.lowerAlarmed = !d.config.resetOnDetect
+	}
+	d.upperAlarmed = d.upperAlarmed && d.upperSum > d.config.threshold
+	d.lowerAlarmed = d.lowerAlarmed && d.lowerSum > d.config.threshold
+	if changePoint == nil {
+		return nil, false
+	}
+
+	if d.config.resetOnDetect {
+		// the shift is sustained usually, move the reference to the new level
+		d.mean = changePoint.PostMean
+		d.upperSum, d.lowerSum = 0, 0
+	}
+
+	d.lastChangePoint = changePoint
+	copied := *changePoint
+	return &copied, true
+}
+
+// Sums return the current upper and lower sums, in the unit of stddev
+func (d *CusumDetector) Sums() (float64, float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.upperSum, d.lowerSum
+}
+
+// Mean is the current reference mean, it's moved to the new level after reset on detect
+func (d *CusumDetector) Mean() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.mean
+}
+
+func (d *CusumDetector) LastChangePoint() (*model.ChangePoint, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastChangePoint == nil {
+		return nil, false
+	}
+	copied := *d.lastChangePoint
+	return &copied, true
+}
+
+// Reset clear the sums and use the new in control level
+func (d *CusumDetector) Reset(mean, stddev float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mean, d.stddev = mean, stddev
+	d.upperSum, d.lowerSum = 0, 0
+	d.upperAlarmed, d.lowerAlarmed = false, false
+}
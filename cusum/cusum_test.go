@@ -0,0 +1,162 @@
+package cusum
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// minutePoints is the minutely points of the values
+func minutePoints(values ...float64) []model.TimeValue {
+	res := make([]model.TimeValue, 0, len(values))
+	for i, value := range values {
+		res = append(res, model.TimeValue{Time: testStart.Add(time.Duration(i) * time.Minute), Value: value})
+	}
+	return res
+}
+
+func repeat(value float64, cnt int) []float64 {
+	res := make([]float64, cnt)
+	for i := range res {
+		res[i] = value
+	}
+	return res
+}
+
+type detector interface {
+	AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool)
+}
+
+// appendAll return the index of the detected points and the change points
+func appendAll(d detector, datas []model.TimeValue) ([]int, []*model.ChangePoint) {
+	indexes, changePoints := []int{}, []*model.ChangePoint{}
+	for i, timeValue := range datas {
+		if changePoint, ok := d.AppendPoint(context.Background(), timeValue); ok {
+			indexes = append(indexes, i)
+			changePoints = append(changePoints, changePoint)
+		}
+	}
+	return indexes, changePoints
+}
+
+func TestCusumDetector(t *testing.T) {
+	// z is 2 after the shift, the upper sum grow 1.5 each point and pass 5 at the 4th point
+	values := append(append(repeat(0, 10), repeat(2, 10)...), repeat(0, 10)...)
+	d := NewCusumDetector(0, 1)
+	indexes, changePoints := appendAll(d, minutePoints(values...))
+
+	if len(indexes) != 2 || indexes[0] != 13 || indexes[1] != 23 {
+		t.Fatalf("got alarms at %v, expected [13 23]", indexes)
+	}
+	increase, decrease := changePoints[0], changePoints[1]
+	if increase.ChangePointType != model.IncreaseChangePoint || !increase.TimeValue.Time.Equal(testStart.Add(10*time.Minute)) ||
+		increase.RunLength != 4 || increase.DetectionDelay != 3*time.Minute || increase.PreMean != 0 ||
+		increase.PostMean != 2 || increase.Magnitude != 2 {
+		t.Errorf("unexpected increase change point %+v", increase)
+	}
+	// the reference mean moved to 2 after the first alarm
+	if decrease.ChangePointType != model.DecreaseChangePoint || !decrease.TimeValue.Time.Equal(testStart.Add(20*time.Minute)) ||
+		decrease.PreMean != 2 || decrease.Magnitude != -2 || decrease.RelativeMagnitude != -1 {
+		t.Errorf("unexpected decrease change point %+v", decrease)
+	}
+	if d.Mean() != 0 {
+		t.Errorf("got mean %v after reset, expected 0", d.Mean())
+	}
+	if last, ok := d.LastChangePoint(); !ok || !last.TimeValue.Time.Equal(decrease.TimeValue.Time) {
+		t.Errorf("got last change point %+v", last)
+	}
+}
+
+func TestCusumDetectorSums(t *testing.T) {
+	d := NewCusumDetector(10, 2, WithDrift(0.25), WithThreshold(100))
+	appendAll(d, minutePoints(14, 14, 8, math.NaN()))
+	// z is 2, 2, -1, the nan is skipped
+	upper, lower := d.Sums()
+	if math.Abs(upper-2.25) > 1e-12 || math.Abs(lower-0.75) > 1e-12 {
+		t.Errorf("got sums %v %v, expected 2.25 0.75", upper, lower)
+	}
+
+	d.Reset(0, 0)
+	if _, ok := d.AppendPoint(context.Background(), model.TimeValue{Time: testStart, Value: 100}); ok {
+		t.Errorf("the zero stddev should skip the points")
+	}
+	if upper, lower := d.Sums(); upper != 0 || lower != 0 {
+		t.Errorf("got sums %v %v after reset, expected 0", upper, lower)
+	}
+}
+
+func TestCusumDetectorNoReset(t *testing.T) {
+	// each direction alarm once while its sum is above the threshold, the upper sum drop to 4.5
+	// at the first -10 so it alarm again at the 4th 2, the lower sum is still above the threshold then
+	values := append(append(repeat(2, 10), repeat(-10, 2)...), repeat(2, 10)...)
+	d := NewCusumDetector(0, 1, WithResetOnDetect(false))
+	indexes, changePoints := appendAll(d, minutePoints(values...))
+
+	if len(indexes) != 3 || indexes[0] != 3 || indexes[1] != 10 || indexes[2] != 15 {
+		t.Fatalf("got alarms at %v, expected [3 10 15]", indexes)
+	}
+	if changePoints[1].ChangePointType != model.DecreaseChangePoint ||
+		changePoints[2].ChangePointType != model.IncreaseChangePoint {
+		t.Errorf("got change points %+v %+v", changePoints[1], changePoints[2])
+	}
+	if d.Mean() != 0 {
+		t.Errorf("got mean %v, the mean is kept without reset", d.Mean())
+	}
+}
+
+func TestPageHinkleyDetector(t *testing.T) {
+	// the deviation of the k-th 1 is 20 / (20 + k), the sum pass 5 at k = 6
+	values := append(repeat(0, 20), repeat(1, 20)...)
+	d := NewPageHinkleyDetector(WithDrift(0), WithThreshold(5), WithMinInstances(10))
+	indexes, changePoints := appendAll(d, minutePoints(values...))
+
+	if len(indexes) != 1 || indexes[0] != 25 {
+		t.Fatalf("got alarms at %v, expected [25]", indexes)
+	}
+	changePoint := changePoints[0]
+	if changePoint.ChangePointType != model.IncreaseChangePoint ||
+		!changePoint.TimeValue.Time.Equal(testStart.Add(20*time.Minute)) || changePoint.RunLength != 6 ||
+		changePoint.PreMean != 0 || changePoint.PostMean != 1 {
+		t.Errorf("unexpected change point %+v", changePoint)
+	}
+	// the running mean restart at 1, so no more deviation
+	if upper, lower := d.Sums(); upper != 0 || lower != 0 {
+		t.Errorf("got sums %v %v, expected 0", upper, lower)
+	}
+}
+
+func TestPageHinkleyDetectorPreMeanAfterReset(t *testing.T) {
+	d := NewPageHinkleyDetector(WithDrift(0), WithThreshold(5), WithMinInstances(10))
+	d.AppendPoint(context.Background(), model.TimeValue{Time: testStart, Value: 5})
+	if d.upperPre != 5 || d.lowerPre != 5 {
+		t.Fatalf("got pre means %v %v of the first point, expected 5", d.upperPre, d.lowerPre)
+	}
+
+	// reset at the first alarm, the second change point is from 1 to 3
+	values := append(append(repeat(0, 20), repeat(1, 20)...), repeat(3, 20)...)
+	d = NewPageHinkleyDetector(WithDrift(0), WithThreshold(5), WithMinInstances(10))
+	indexes, changePoints := appendAll(d, minutePoints(values...))
+	if len(indexes) != 2 || !changePoints[1].TimeValue.Time.Equal(testStart.Add(40*time.Minute)) {
+		t.Fatalf("got alarms at %v, expected 2", indexes)
+	}
+	if changePoint := changePoints[1]; changePoint.PreMean != 1 || changePoint.PostMean != 3 ||
+		changePoint.Magnitude != 2 || changePoint.RelativeMagnitude != 2 {
+		t.Errorf("unexpected change point after reset %+v", changePoint)
+	}
+}
+
+func TestPageHinkleyDetectorMinInstances(t *testing.T) {
+	values := append(repeat(0, 20), repeat(1, 20)...)
+	d := NewPageHinkleyDetector(WithDrift(0), WithThreshold(5), WithMinInstances(30))
+	indexes, changePoints := appendAll(d, minutePoints(values...))
+
+	// the sum pass 5 at index 25, the alarm wait for the 30th point
+	if len(indexes) != 1 || indexes[0] != 29 || !changePoints[0].TimeValue.Time.Equal(testStart.Add(20*time.Minute)) {
+		t.Fatalf("got alarms at %v %+v, expected [29]", indexes, changePoints)
+	}
+}
@@ -0,0 +1,112 @@
+package cusum
+
+import (
+	"math"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+type detectorConfig struct {
+	drift         float64
+	threshold     float64
+	resetOnDetect bool
+	minInstances  int
+}
+
+type Option func(*detectorConfig)
+
+// WithDrift set the allowed slack of each point, the shift smaller than it won't accumulate.
+// for cusum it's in the unit of stddev, default 0.5; for page hinkley it's in the unit of value, default 0.005
+func WithDrift(drift float64) Option {
+	return func(c *detectorConfig) {
+		if drift >= 0 {
+			c.drift = drift
+		}
+	}
+}
+
+// WithThreshold set the alarm threshold of the accumulated sum.
+// for cusum it's in the unit of stddev, default 5; for page hinkley it's in the unit of value, default 50
+func WithThreshold(threshold float64) Option {
+	return func(c *detectorConfig) {
+		if threshold > 0 {
+			c.threshold = threshold
+		}
+	}
+}
+
+// WithResetOnDetect default is true, the sums are reset after a change point detected and the
+// reference mean is moved to the new level (for page hinkley the running mean restart).
+// otherwise each direction won't alarm again until its sum back under the threshold
+func WithResetOnDetect(resetOnDetect bool) Option {
+	return func(c *detectorConfig) {
+		c.resetOnDetect = resetOnDetect
+	}
+}
+
+// WithMinInstances the points needed before detecting, only used by page hinkley, default is 30
+func WithMinInstances(minInstances int) Option {
+	return func(c *detectorConfig) {
+		if minInstances >= 0 {
+			c.minInstances = minInstances
+		}
+	}
+}
+
+func newDetectorConfig(drift, threshold float64, opts ...Option) *detectorConfig {
+	config := &detectorConfig{
+		drift:         drift,
+		threshold:     threshold,
+		resetOnDetect: true,
+		minInstances:  30,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// runStat is the O(1) statistics of the points since the run begin
+type runStat struct {
+	start model.TimeValue
+	count int
+	sum   float64
+}
+
+func (r *runStat) begin(timeValue model.TimeValue) {
+	r.start, r.count, r.sum = timeValue, 0, 0
+}
+
+func (r *runStat) add(value float64) {
+	r.count++
+	r.sum += value
+}
+
+func (r *runStat) mean() float64 {
+	if r.count == 0 {
+		return 0
+	}
+	return r.sum / float64(r.count)
+}
+
+func newChangePoint(run *runStat, preMean float64, current model.TimeValue,
+	changePointType model.ChangePointType) *model.ChangePoint {
+	changePoint := &model.ChangePoint{
+		ChangePointType: changePointType,
+		TimeValue:       run.start,
+		RunLength:       run.count,
+		DetectTime:      current.Time,
+		DetectionDelay:  current.Time.Sub(run.start.Time),
+		PreMean:         preMean,
+		PostMean:        run.mean(),
+		Magnitude:       run.mean() - preMean,
+	}
+	if preMean != 0 {
+		changePoint.RelativeMagnitude = changePoint.Magnitude / math.Abs(preMean)
+	}
+	return changePoint
+}
+
+func invalidValue(value float64) bool {
+	return math.IsNaN(value) || math.IsInf(value, 0)
+}
@@ -0,0 +1,130 @@
+package cusum
+
+import (
+	"context"
+	"sync"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// PageHinkleyDetector is the two sided page hinkley test, it's like cusum but the reference is the
+// running mean of the points, so the in control level is not needed. the drift and threshold are
+// in the unit of value. the state and the cost of each point are O(1), safe for concurrent use
+type PageHinkleyDetector struct {
+	mu sync.Mutex
+
+	config *detectorConfig
+
+	count    int
+	mean     float64 // running mean of the points since the last reset
+	upperSum float64 // m_t - min(m), m_t = sum(x - mean - drift)
+	lowerSum float64 // max(m') - m'_t, m'_t = sum(x - mean + drift)
+	upperRun runStat
+	lowerRun runStat
+	upperPre float64 // the running mean when the run begin
+	lowerPre float64
+	// only used when not reset on detect, each direction alarm once until its sum back under the threshold
+	upperAlarmed bool
+	lowerAlarmed bool
+
+	lastChangePoint *model.ChangePoint
+}
+
+func NewPageHinkleyDetector(opts ...Option) *PageHinkleyDetector {
+	return &PageHinkleyDetector{
+		config: newDetectorConfig(0.005, 50, opts...),
+	}
+}
+
+func (d *PageHinkleyDetector) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool) {
+	logger := utils.GetLogger(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if invalidValue(timeValue.Value) {
+		logger.Debug("skip invalid point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+
+	// there is no running mean before the first point after new or reset, use the point itself
+	preMean := d.mean
+	if d.count == 0 {
+		preMean = timeValue.Value
+	}
+	if d.upperSum == 0 {
+		d.upperRun.begin(timeValue)
+		d.upperPre = preMean
+	}
+	if d.lowerSum == 0 {
+		d.lowerRun.begin(timeValue)
+		d.lowerPre = preMean
+	}
+
+	d.count++
+	d.mean += (timeValue.Value - d.mean) / float64(d.count)
+	deviation := timeValue.Value - d.mean
+	d.upperSum = max(0, d.upperSum+deviation-d.config.drift)
+	d.lowerSum = max(0, d.lowerSum-deviation-d.config.drift)
+	d.upperRun.add(timeValue.Value)
+	d.lowerRun.add(timeValue.Value)
+
+	if d.count < d.config.minInstances {
+		return nil, false
+	}
+
+	var changePoint *model.ChangePoint
+	switch {
+	case d.upperSum > d.config.threshold && !d.upperAlarmed:
+		changePoint = newChangePoint(&d.upperRun, d.upperPre, timeValue, model.IncreaseChangePoint)
+		d.upperAlarmed = !d.config.resetOnDetect
+	case d.lowerSum > d.config.threshold && !d.lowerAlarmed:
+		changePoint = newChangePoint(&d.lowerRun, d.lowerPre, timeValue, model.DecreaseChangePoint)
+		d.lowerAlarmed = !d.config.resetOnDetect
+	}
+	d.upperAlarmed = d.upperAlarmed && d.upperSum > d.config.threshold
+	d.lowerAlarmed = d.lowerAlarmed && d.lowerSum > d.config.threshold
+	if changePoint == nil {
+		return nil, false
+	}
+
+	if d.config.resetOnDetect {
+		d.reset()
+	}
+
+	d.lastChangePoint = changePoint
+	copied := *changePoint
+	return &copied, true
+}
+
+func (d *PageHinkleyDetector) reset() {
+	d.count, d.mean = 0, 0
+	d.upperSum, d.lowerSum = 0, 0
+	d.upperAlarmed, d.lowerAlarmed = false, false
+}
+
+// Sums return the current upper and lower statistics
+func (d *PageHinkleyDetector) Sums() (float64, float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.upperSum, d.lowerSum
+}
+
+func (d *PageHinkleyDetector) LastChangePoint() (*model.ChangePoint, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastChangePoint == nil {
+		return nil, false
+	}
+	copied := *d.lastChangePoint
+	return &copied, true
+}
+
+// Reset clear the running mean and the statistics
+func (d *PageHinkleyDetector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reset()
+}
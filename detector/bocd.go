@@ -0,0 +1,58 @@
+package detector
+
+import (
+	"context"
+
+	"github.com/uyouii/timeseries-algorithms/bocd"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+const BocdDetectorName = "bocd"
+
+type bocdHandlerDetector struct {
+	handler *bocd.BocdHandler
+}
+
+// NewBocdHandlerDetector append each point to the handler and pop the change points need trigger,
+// so the detections are the change points passed the observe duration and the suppression policies
+func NewBocdHandlerDetector(handler *bocd.BocdHandler) StreamingDetector {
+	return &bocdHandlerDetector{
+		handler: handler,
+	}
+}
+
+func (d *bocdHandlerDetector) Name() string {
+	return BocdDetectorName
+}
+
+func (d *bocdHandlerDetector) Detect(ctx context.Context, timeValue model.TimeValue) ([]*model.Detection, error) {
+	d.handler.AppendTimeSeriesData(ctx, &model.TimeSeries{Values: []model.TimeValue{timeValue}})
+	return changePointDetections(BocdDetectorName, d.handler.PopNeedTriggerChangePoints(ctx)), nil
+}
+
+type bocdBatchDetector struct {
+	varx    float64
+	mean0   float64
+	options []bocd.BocdCheckerOption
+}
+
+// NewBocdBatchDetector use bocd.DetectChangePointsBatch, varx <= 0 means estimate it from the series
+func NewBocdBatchDetector(varx, mean0 float64, opts ...bocd.BocdCheckerOption) BatchDetector {
+	return &bocdBatchDetector{
+		varx:    varx,
+		mean0:   mean0,
+		options: opts,
+	}
+}
+
+func (d *bocdBatchDetector) Name() string {
+	return BocdDetectorName
+}
+
+func (d *bocdBatchDetector) DetectBatch(ctx context.Context, timeSeries *model.TimeSeries) ([]*model.Detection, error) {
+	result, err := bocd.DetectChangePointsBatch(ctx, timeSeries, d.varx, d.mean0, d.options...)
+	if err != nil {
+		return nil, err
+	}
+	return changePointDetections(BocdDetectorName, result.ChangePoints), nil
+}
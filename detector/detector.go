@@ -0,0 +1,100 @@
+package detector
+
+import (
+	"context"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+)
+
+// StreamingDetector check the points one by one, the detections may be about the earlier points,
+// like the change point confirmed some minutes later
+type StreamingDetector interface {
+	Name() string
+	Detect(ctx context.Context, timeValue model.TimeValue) ([]*model.Detection, error)
+}
+
+// BatchDetector check the whole time series at once
+type BatchDetector interface {
+	Name() string
+	DetectBatch(ctx context.Context, timeSeries *model.TimeSeries) ([]*model.Detection, error)
+}
+
+// ChangePointAppender is the shape of the online change point checkers,
+// like bocd.BocdOnlineChecker, cusum.CusumDetector and controlchart.EwmaChart
+type ChangePointAppender interface {
+	AppendPoint(ctx context.Context, timeValue model.TimeValue) (*model.ChangePoint, bool)
+}
+
+type changePointDetector struct {
+	name     string
+	appender ChangePointAppender
+}
+
+// NewChangePointDetector wrap an online change point checker as StreamingDetector
+func NewChangePointDetector(name string, appender ChangePointAppender) StreamingDetector {
+	return &changePointDetector{
+		name:     name,
+		appender: appender,
+	}
+}
+
+func (d *changePointDetector) Name() string {
+	return d.name
+}
+
+func (d *changePointDetector) Detect(ctx context.Context, timeValue model.TimeValue) ([]*model.Detection, error) {
+	changePoint, found := d.appender.AppendPoint(ctx, timeValue)
+	if !found {
+		return nil, nil
+	}
+	return []*model.Detection{model.NewChangePointDetection(d.name, changePoint)}, nil
+}
+
+type streamingBatchDetector struct {
+	name    string
+	factory func() (StreamingDetector, error)
+}
+
+// NewStreamingBatchDetector run a new streaming detector over the sorted series for each batch,
+// the factory is called every batch so the state won't leak between the series
+func NewStreamingBatchDetector(name string, factory func() (StreamingDetector, error)) BatchDetector {
+	return &streamingBatchDetector{
+		name:    name,
+		factory: factory,
+	}
+}
+
+func (d *streamingBatchDetector) Name() string {
+	return d.name
+}
+
+func (d *streamingBatchDetector) DetectBatch(ctx context.Context,
+	timeSeries *model.TimeSeries) ([]*model.Detection, error) {
+	if timeSeries.IsEmpty() {
+		return nil, common.ErrorInvalidValue
+	}
+	streamingDetector, err := d.factory()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*model.Detection{}
+	for _, timeValue := range utils.SortedTimeValues(timeSeries.Values) {
+		detections, err := streamingDetector.Detect(ctx, timeValue)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, detections...)
+	}
+	return res, nil
+}
+
+func changePointDetections(name string, changePoints []*model.ChangePoint) []*model.Detection {
+	res := make([]*model.Detection, 0, len(changePoints))
+	for _, changePoint := range changePoints {
+		res = append(res, model.NewChangePointDetection(name, changePoint))
+	}
+	return res
+}
@@ -0,0 +1,183 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/cusum"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// stepTimeSeries is the minutely normal noise, the level is shift from 0 to level at the step
+func stepTimeSeries(cnt, step int, level float64) *model.TimeSeries {
+	random := rand.New(rand.NewSource(1))
+	res := &model.TimeSeries{}
+	for i := 0; i < cnt; i++ {
+		value := random.NormFloat64()
+		if i >= step {
+			value += level
+		}
+		res.Values = append(res.Values, model.TimeValue{Time: testStart.Add(time.Duration(i) * time.Minute),
+			Value: value})
+	}
+	return res
+}
+
+func TestNewRangeDetection(t *testing.T) {
+	testCases := []struct {
+		value     float64
+		isAnomaly bool
+		direction model.ChangePointType
+		score     float64
+	}{
+		{15, false, 0, 0},
+		{10, false, 0, 0},
+		{25, true, model.IncreaseChangePoint, 0.5},
+		{4, true, model.DecreaseChangePoint, 0.6},
+	}
+	for _, testCase := range testCases {
+		timeValue := model.TimeValue{Time: testStart, Value: testCase.value}
+		detection, isAnomaly := newRangeDetection("test", timeValue, 10, 20)
+		if isAnomaly != testCase.isAnomaly {
+			t.Errorf("value %v got anomaly %v", testCase.value, isAnomaly)
+			continue
+		}
+		if !isAnomaly {
+			continue
+		}
+		if detection.Direction != testCase.direction || math.Abs(detection.Score-testCase.score) > 1e-12 ||
+			detection.Kind != model.AnomalyDetection || detection.Lower != 10 || detection.Upper != 20 {
+			t.Errorf("value %v got detection %+v", testCase.value, detection)
+		}
+	}
+
+	// the empty range use the distance as the score
+	detection, _ := newRangeDetection("test", model.TimeValue{Value: 13}, 10, 10)
+	if detection.Score != 3 {
+		t.Errorf("got score %v for the empty range, expected 3", detection.Score)
+	}
+}
+
+func TestStreamingBatchDetector(t *testing.T) {
+	factory := func() (StreamingDetector, error) {
+		// without reset the shift alarm only once
+		return NewChangePointDetector("cusum", cusum.NewCusumDetector(0, 1, cusum.WithResetOnDetect(false))), nil
+	}
+	batchDetector := NewStreamingBatchDetector("cusum", factory)
+
+	timeSeries := stepTimeSeries(200, 100, 5)
+	// reverse the series, the detector sort it
+	reversed := &model.TimeSeries{}
+	for i := len(timeSeries.Values) - 1; i >= 0; i-- {
+		reversed.Values = append(reversed.Values, timeSeries.Values[i])
+	}
+
+	for round := 0; round < 2; round++ {
+		// the factory is called each batch, so the second run get the same result
+		detections, err := batchDetector.DetectBatch(context.Background(), reversed)
+		if err != nil {
+			t.Fatalf("detect failed: %v", err)
+		}
+		if len(detections) != 1 {
+			t.Fatalf("round %v got %v detections, expected 1", round, len(detections))
+		}
+		detection := detections[0]
+		if detection.Detector != "cusum" || detection.Kind != model.ChangePointDetection ||
+			detection.Direction != model.IncreaseChangePoint || detection.ChangePoint == nil ||
+			!detection.TimeValue.Time.Equal(testStart.Add(100*time.Minute)) ||
+			detection.Score != math.Abs(detection.ChangePoint.Magnitude) {
+			t.Errorf("round %v got detection %+v", round, detection)
+		}
+	}
+
+	if _, err := batchDetector.DetectBatch(context.Background(), &model.TimeSeries{}); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the empty series", err)
+	}
+	failed := NewStreamingBatchDetector("failed", func() (StreamingDetector, error) {
+		return nil, common.ErrorInvalidValue
+	})
+	if _, err := failed.DetectBatch(context.Background(), timeSeries); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v from the failed factory", err)
+	}
+}
+
+func TestKdeDetector(t *testing.T) {
+	timeValue := model.TimeValue{Time: testStart, Value: 100}
+	// the same minute of the last 30 days
+	history := []model.RecordValue{}
+	for day := 1; day <= 30; day++ {
+		history = append(history, model.RecordValue{
+			Timestamp: testStart.AddDate(0, 0, -day).Unix(),
+			Value:     100 + float64(day%5-2),
+		})
+	}
+	loader := func(ctx context.Context, timestamp int64) ([]model.RecordValue, error) {
+		if timestamp != testStart.Unix() {
+			t.Errorf("load timestamp %v, expected %v", timestamp, testStart.Unix())
+		}
+		return history, nil
+	}
+	kdeDetector := NewKdeDetector(loader, WithQuantiles(0.05, 0.95))
+	ctx := context.Background()
+
+	if detections, err := kdeDetector.Detect(ctx, timeValue); err != nil || len(detections) != 0 {
+		t.Errorf("got detections %v err %v for the normal value", detections, err)
+	}
+	timeValue.Value = 130
+	detections, err := kdeDetector.Detect(ctx, timeValue)
+	if err != nil || len(detections) != 1 {
+		t.Fatalf("got detections %v err %v for the high value", detections, err)
+	}
+	detection := detections[0]
+	if detection.Detector != KdeDetectorName || detection.Direction != model.IncreaseChangePoint ||
+		detection.Lower >= 100 || detection.Upper <= 100 || detection.Upper >= 130 ||
+		math.Abs(detection.Score-(130-detection.Upper)/(detection.Upper-detection.Lower)) > 1e-12 {
+		t.Errorf("got detection %+v", detection)
+	}
+
+	// too few history points is skipped, the loader error is returned
+	history = history[:3]
+	if detections, err := kdeDetector.Detect(ctx, timeValue); err != nil || len(detections) != 0 {
+		t.Errorf("got detections %v err %v with too few history", detections, err)
+	}
+	failed := NewKdeDetector(func(ctx context.Context, timestamp int64) ([]model.RecordValue, error) {
+		return nil, common.ErrorUnexpectedStatus
+	})
+	if _, err := failed.Detect(ctx, timeValue); !errors.Is(err, common.ErrorUnexpectedStatus) {
+		t.Errorf("got error %v from the failed loader", err)
+	}
+}
+
+func TestChangePointBatchDetectors(t *testing.T) {
+	timeSeries := stepTimeSeries(200, 100, 10)
+	batchDetectors := []BatchDetector{
+		NewPELTDetector(),
+		NewBinarySegmentationDetector(),
+		NewBottomUpDetector(),
+		NewBocdBatchDetector(1, 0),
+	}
+	for _, batchDetector := range batchDetectors {
+		detections, err := batchDetector.DetectBatch(context.Background(), timeSeries)
+		if err != nil {
+			t.Fatalf("%v detect failed: %v", batchDetector.Name(), err)
+		}
+		if len(detections) != 1 {
+			t.Errorf("%v got %v detections, expected 1", batchDetector.Name(), len(detections))
+			continue
+		}
+		detection := detections[0]
+		if detection.Detector != batchDetector.Name() || detection.Kind != model.ChangePointDetection ||
+			!detection.TimeValue.Time.Equal(testStart.Add(100*time.Minute)) ||
+			detection.Direction != model.IncreaseChangePoint {
+			t.Errorf("%v got detection %+v", batchDetector.Name(), detection)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package detector
+
+import (
+	"context"
+	"errors"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/holtwinters"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+const HoltWintersDetectorName = "holt_winters"
+
+type holtWintersDetector struct {
+	model *holtwinters.Model
+}
+
+// NewHoltWintersDetector update the fitted model with each point, the point out of the
+// prediction interval made before it is reported as anomaly
+func NewHoltWintersDetector(hwModel *holtwinters.Model) StreamingDetector {
+	return &holtWintersDetector{
+		model: hwModel,
+	}
+}
+
+func (d *holtWintersDetector) Name() string {
+	return HoltWintersDetectorName
+}
+
+func (d *holtWintersDetector) Detect(ctx context.Context, timeValue model.TimeValue) ([]*model.Detection, error) {
+	prediction, err := d.model.Update(ctx, timeValue)
+	if errors.Is(err, common.ErrorInvalidValue) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	detection, isAnomaly := newRangeDetection(HoltWintersDetectorName, timeValue, prediction.Lower, prediction.Upper)
+	if !isAnomaly {
+		return nil, nil
+	}
+	return []*model.Detection{detection}, nil
+}
@@ -0,0 +1,126 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/kde"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+const KdeDetectorName = "kde"
+
+// RecordValueLoader load the history record values used to calculate the kde at the timestamp,
+// like the values of the same minute in the last 30 days
+type RecordValueLoader func(ctx context.Context, timestamp int64) ([]model.RecordValue, error)
+
+// KdeDetector report the point out of the kde quantiles as anomaly
+type KdeDetector struct {
+	loader        RecordValueLoader
+	lowerQuantile float64
+	upperQuantile float64
+	kdeOptions    []kde.KdeOption
+}
+
+type KdeDetectorOption func(*KdeDetector)
+
+// WithQuantiles set the expected range, must be in kde.AllCalculateQuantiles, default is 0.01 and 0.99
+func WithQuantiles(lower, upper float64) KdeDetectorOption {
+	return func(d *KdeDetector) {
+		if lower < upper {
+			d.lowerQuantile, d.upperQuantile = lower, upper
+		}
+	}
+}
+
+// WithKdeOptions set the options of kde.CalculateKdeConfidences, like kde.WithMetrics
+func WithKdeOptions(opts ...kde.KdeOption) KdeDetectorOption {
+	return func(d *KdeDetector) {
+		d.kdeOptions = append(d.kdeOptions, opts...)
+	}
+}
+
+func NewKdeDetector(loader RecordValueLoader, opts ...KdeDetectorOption) *KdeDetector {
+	detector := &KdeDetector{
+		loader:        loader,
+		lowerQuantile: 0.01,
+		upperQuantile: 0.99,
+	}
+	for _, opt := range opts {
+		opt(detector)
+	}
+	return detector
+}
+
+func (d *KdeDetector) Name() string {
+	return KdeDetectorName
+}
+
+// Detect the point is skipped if the kde can't be calculated, like too few history points
+func (d *KdeDetector) Detect(ctx context.Context, timeValue model.TimeValue) ([]*model.Detection, error) {
+	logger := utils.GetLogger(ctx)
+
+	timestamp := timeValue.Time.Unix()
+	recordValues, err := d.loader(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	confidence, err := kde.CalculateKdeConfidences(ctx, timestamp, recordValues, d.kdeOptions...)
+	if errors.Is(err, common.ErrorInvalidValue) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lower, lowerOk := confidence.GetQuantileValue(d.lowerQuantile)
+	upper, upperOk := confidence.GetQuantileValue(d.upperQuantile)
+	if !lowerOk || !upperOk {
+		logger.Warn("kde quantile not found, skip detect", zap.Float64("lowerQuantile", d.lowerQuantile),
+			zap.Float64("upperQuantile", d.upperQuantile))
+		return nil, nil
+	}
+
+	detection, isAnomaly := newRangeDetection(KdeDetectorName, timeValue, lower.Value, upper.Value)
+	if !isAnomaly {
+		return nil, nil
+	}
+	return []*model.Detection{detection}, nil
+}
+
+// newRangeDetection the score is the distance out of the range in the unit of the range width
+func newRangeDetection(name string, timeValue model.TimeValue, lower, upper float64) (*model.Detection, bool) {
+	detection := &model.Detection{
+		Detector:  name,
+		Kind:      model.AnomalyDetection,
+		TimeValue: timeValue,
+		Lower:     lower,
+		Upper:     upper,
+	}
+
+	distance := 0.0
+	switch {
+	case timeValue.Value > upper:
+		distance = timeValue.Value - upper
+		detection.Direction = model.IncreaseChangePoint
+	case timeValue.Value < lower:
+		distance = lower - timeValue.Value
+		detection.Direction = model.DecreaseChangePoint
+	default:
+		return nil, false
+	}
+
+	detection.Score = distance
+	if width := upper - lower; width > 0 {
+		detection.Score = distance / width
+	}
+	if math.IsNaN(detection.Score) {
+		return nil, false
+	}
+	return detection, true
+}
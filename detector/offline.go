@@ -0,0 +1,41 @@
+package detector
+
+import (
+	"context"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/offline"
+)
+
+type offlineDetectFunc func(ctx context.Context, timeSeries *model.TimeSeries,
+	opts ...offline.Option) (*offline.Result, error)
+
+type offlineDetector struct {
+	name    string
+	detect  offlineDetectFunc
+	options []offline.Option
+}
+
+func NewPELTDetector(opts ...offline.Option) BatchDetector {
+	return &offlineDetector{name: "pelt", detect: offline.DetectPELT, options: opts}
+}
+
+func NewBinarySegmentationDetector(opts ...offline.Option) BatchDetector {
+	return &offlineDetector{name: "binary_segmentation", detect: offline.DetectBinarySegmentation, options: opts}
+}
+
+func NewBottomUpDetector(opts ...offline.Option) BatchDetector {
+	return &offlineDetector{name: "bottom_up", detect: offline.DetectBottomUp, options: opts}
+}
+
+func (d *offlineDetector) Name() string {
+	return d.name
+}
+
+func (d *offlineDetector) DetectBatch(ctx context.Context, timeSeries *model.TimeSeries) ([]*model.Detection, error) {
+	result, err := d.detect(ctx, timeSeries, d.options...)
+	if err != nil {
+		return nil, err
+	}
+	return changePointDetections(d.name, result.ChangePoints), nil
+}
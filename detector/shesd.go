@@ -0,0 +1,49 @@
+package detector
+
+import (
+	"context"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/shesd"
+)
+
+const SHESDDetectorName = "s_h_esd"
+
+type shesdDetector struct {
+	periods []time.Duration
+	options []shesd.Option
+}
+
+// NewSHESDDetector periods is the seasons of the series like 24h, see shesd.Detect
+func NewSHESDDetector(periods []time.Duration, opts ...shesd.Option) BatchDetector {
+	return &shesdDetector{
+		periods: periods,
+		options: opts,
+	}
+}
+
+func (d *shesdDetector) Name() string {
+	return SHESDDetectorName
+}
+
+func (d *shesdDetector) DetectBatch(ctx context.Context, timeSeries *model.TimeSeries) ([]*model.Detection, error) {
+	result, err := shesd.Detect(ctx, timeSeries, d.periods, d.options...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*model.Detection, 0, len(result.Anomalies))
+	for _, anomaly := range result.Anomalies {
+		res = append(res, &model.Detection{
+			Detector:  SHESDDetectorName,
+			Kind:      model.AnomalyDetection,
+			TimeValue: anomaly.TimeValue,
+			Direction: anomaly.Direction,
+			Score:     anomaly.Score,
+			Lower:     anomaly.Lower,
+			Upper:     anomaly.Upper,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package detector
+
+import (
+	"context"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/spectral"
+)
+
+const SpectralResidualDetectorName = "spectral_residual"
+
+type spectralResidualDetector struct {
+	detector *spectral.StreamDetector
+}
+
+// NewSpectralResidualDetector the anomaly has no expected range, the direction is the side of the
+// value to the previous point
+func NewSpectralResidualDetector(detector *spectral.StreamDetector) StreamingDetector {
+	return &spectralResidualDetector{
+		detector: detector,
+	}
+}
+
+func (d *spectralResidualDetector) Name() string {
+	return SpectralResidualDetectorName
+}
+
+func (d *spectralResidualDetector) Detect(ctx context.Context, timeValue model.TimeValue) ([]*model.Detection, error) {
+	previous := d.detector.LastValue()
+	score, ok := d.detector.AppendPoint(ctx, timeValue)
+	if !ok || !score.IsAnomaly {
+		return nil, nil
+	}
+
+	detection := &model.Detection{
+		Detector:  SpectralResidualDetectorName,
+		Kind:      model.AnomalyDetection,
+		TimeValue: timeValue,
+		Direction: model.IncreaseChangePoint,
+		Score:     score.Score,
+	}
+	if previous != nil && timeValue.Value < previous.Value {
+		detection.Direction = model.DecreaseChangePoint
+	}
+	return []*model.Detection{detection}, nil
+}
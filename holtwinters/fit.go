@@ -0,0 +1,127 @@
+package holtwinters
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/optimize"
+)
+
+// hwState is the level, trend and the seasonal of the next period points,
+// seasonals[next % period] is the seasonal of the next point
+type hwState struct {
+	level     float64
+	trend     float64
+	seasonals []float64
+	next      int
+}
+
+// initState use the mean of each full season, the level is the first season mean moved back
+// to the point before the first one, the trend is the slope of the season means
+func initState(values []float64, period int, seasonalType SeasonalType) hwState {
+	seasonCnt := len(values) / period
+	seasonMeans := make([]float64, seasonCnt)
+	for k := 0; k < seasonCnt; k++ {
+		for i := 0; i < period; i++ {
+			seasonMeans[k] += values[k*period+i]
+		}
+		seasonMeans[k] /= float64(period)
+	}
+
+	trend := (seasonMeans[seasonCnt-1] - seasonMeans[0]) / float64((seasonCnt-1)*period)
+	seasonals := make([]float64, period)
+	for i := 0; i < period; i++ {
+		for k := 0; k < seasonCnt; k++ {
+			// remove the trend inside the season
+			base := seasonMeans[k] + trend*(float64(i)-float64(period-1)/2)
+			if seasonalType == MultiplicativeSeasonal {
+				seasonals[i] += values[k*period+i] / base
+			} else {
+				seasonals[i] += values[k*period+i] - base
+			}
+		}
+		seasonals[i] /= float64(seasonCnt)
+	}
+
+	return hwState{
+		level:     seasonMeans[0] - trend*float64(period+1)/2,
+		trend:     trend,
+		seasonals: seasonals,
+	}
+}
+
+func (s *hwState) predict(h int, seasonalType SeasonalType) float64 {
+	seasonal := s.seasonals[(s.next+h-1)%len(s.seasonals)]
+	if seasonalType == MultiplicativeSeasonal {
+		return (s.level + float64(h)*s.trend) * seasonal
+	}
+	return s.level + float64(h)*s.trend + seasonal
+}
+
+func (s *hwState) update(value float64, params Params, seasonalType SeasonalType) {
+	index := s.next % len(s.seasonals)
+	seasonal := s.seasonals[index]
+
+	level := 0.0
+	if seasonalType == MultiplicativeSeasonal {
+		level = params.Alpha*value/seasonal + (1-params.Alpha)*(s.level+s.trend)
+		s.seasonals[index] = params.Gamma*value/level + (1-params.Gamma)*seasonal
+	} else {
+		level = params.Alpha*(value-seasonal) + (1-params.Alpha)*(s.level+s.trend)
+		s.seasonals[index] = params.Gamma*(value-level) + (1-params.Gamma)*seasonal
+	}
+	s.trend = params.Beta*(level-s.level) + (1-params.Beta)*s.trend
+	s.level = level
+	s.next = (index + 1) % len(s.seasonals)
+}
+
+func (s *hwState) copy() hwState {
+	res := *s
+	res.seasonals = append([]float64{}, s.seasonals...)
+	return res
+}
+
+// sse is the sum of the squared one step errors
+func sse(values []float64, initial hwState, params Params, seasonalType SeasonalType) float64 {
+	state := initial.copy()
+	res := 0.0
+	for _, v := range values {
+		e := v - state.predict(1, seasonalType)
+		res += e * e
+		state.update(v, params, seasonalType)
+	}
+	if math.IsNaN(res) {
+		return math.Inf(1)
+	}
+	return res
+}
+
+// fitParams minimize the sse with nelder mead, the params are mapped to (0, 1) by the logistic function
+func fitParams(values []float64, period int, seasonalType SeasonalType) *Params {
+	initial := initState(values, period, seasonalType)
+	toParams := func(x []float64) Params {
+		return Params{Alpha: logistic(x[0]), Beta: logistic(x[1]), Gamma: logistic(x[2])}
+	}
+	problem := optimize.Problem{
+		Func: func(x []float64) float64 {
+			return sse(values, initial, toParams(x), seasonalType)
+		},
+	}
+
+	res := &Params{Alpha: 0.3, Beta: 0.05, Gamma: 0.1}
+	x0 := []float64{logit(res.Alpha), logit(res.Beta), logit(res.Gamma)}
+	result, err := optimize.Minimize(problem, x0, &optimize.Settings{FuncEvaluations: 1000}, &optimize.NelderMead{})
+	// the result is still the best found when stopped by the evaluation limit
+	if result != nil && !math.IsInf(result.F, 1) && (err == nil || result.Status == optimize.FunctionEvaluationLimit) {
+		params := toParams(result.X)
+		res = &params
+	}
+	return res
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func logit(p float64) float64 {
+	return math.Log(p / (1 - p))
+}
@@ -0,0 +1,337 @@
+package holtwinters
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+type SeasonalType int
+
+const (
+	AdditiveSeasonal SeasonalType = iota
+	// MultiplicativeSeasonal the seasonal amplitude is proportional to the level, need positive values
+	MultiplicativeSeasonal
+)
+
+func (t SeasonalType) String() string {
+	switch t {
+	case AdditiveSeasonal:
+		return "additive"
+	case MultiplicativeSeasonal:
+		return "multiplicative"
+	default:
+		return "unknown"
+	}
+}
+
+type fitConfig struct {
+	seasonalType SeasonalType
+	params       *Params // nil means fit the params by minimizing the sse
+	confidence   float64
+}
+
+type Option func(*fitConfig)
+
+// WithSeasonalType default is AdditiveSeasonal
+func WithSeasonalType(seasonalType SeasonalType) Option {
+	return func(c *fitConfig) {
+		c.seasonalType = seasonalType
+	}
+}
+
+// WithParams use the fixed smoothing params instead of fitting them, all of them need be in [0, 1]
+func WithParams(alpha, beta, gamma float64) Option {
+	return func(c *fitConfig) {
+		c.params = &Params{Alpha: alpha, Beta: beta, Gamma: gamma}
+	}
+}
+
+// WithConfidence the coverage of the prediction intervals, default is 0.95
+func WithConfidence(confidence float64) Option {
+	return func(c *fitConfig) {
+		if confidence > 0 && confidence < 1 {
+			c.confidence = confidence
+		}
+	}
+}
+
+func newFitConfig(opts ...Option) *fitConfig {
+	config := &fitConfig{
+		seasonalType: AdditiveSeasonal,
+		confidence:   0.95,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// Params is the smoothing params of the level, trend and seasonal
+type Params struct {
+	Alpha float64 `json:"alpha"`
+	Beta  float64 `json:"beta"`
+	Gamma float64 `json:"gamma"`
+}
+
+func (p *Params) valid() bool {
+	for _, v := range []float64{p.Alpha, p.Beta, p.Gamma} {
+		if math.IsNaN(v) || v < 0 || v > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Prediction is the forecast of one point, Lower and Upper are the prediction interval
+type Prediction struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+// Contains return whether the value is in the prediction interval
+func (p *Prediction) Contains(value float64) bool {
+	return value >= p.Lower && value <= p.Upper
+}
+
+// Forecast is the predictions as time series, they have the same labels as the fitted series
+type Forecast struct {
+	Mean  *model.TimeSeries `json:"mean"`
+	Lower *model.TimeSeries `json:"lower"`
+	Upper *model.TimeSeries `json:"upper"`
+}
+
+// Model is the holt winters triple exponential smoothing, safe for concurrent use
+type Model struct {
+	mu sync.Mutex
+
+	labels       map[string]string
+	period       int
+	interval     time.Duration
+	seasonalType SeasonalType
+	params       Params
+	confidence   float64
+
+	state    hwState
+	lastTime time.Time
+
+	// the one step errors used by the prediction intervals,
+	// relative to the prediction when multiplicative
+	sumSquaredError float64
+	errorCnt        int
+}
+
+// Fit fit the model on the series, the period is the season length like 24h. the series need be regular
+// spaced without gap and at least 2 full seasons, the interval is the median of the time diffs
+func Fit(ctx context.Context, timeSeries *model.TimeSeries, period time.Duration, opts ...Option) (*Model, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := newFitConfig(opts...)
+	if timeSeries.IsEmpty() || len(timeSeries.Values) < 2 {
+		logger.Error("time series too short to fit holt winters")
+		return nil, common.ErrorInvalidValue
+	}
+	if config.params != nil && !config.params.valid() {
+		logger.Error("invalid holt winters params", zap.Any("params", config.params))
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := utils.SortedTimeValues(timeSeries.Values)
+
+	interval := utils.MedianInterval(datas)
+	if interval <= 0 {
+		logger.Error("can not infer the interval of time series", zap.String("timeSeries", timeSeries.DebugString()))
+		return nil, common.ErrorInvalidValue
+	}
+	if !utils.RegularSpaced(datas, interval) {
+		logger.Error("time series is not regular spaced", zap.Duration("interval", interval),
+			zap.String("timeSeries", timeSeries.DebugString()))
+		return nil, common.ErrorInvalidValue
+	}
+	periodCnt := int((period + interval/2) / interval)
+
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) ||
+			(config.seasonalType == MultiplicativeSeasonal && timeValue.Value <= 0) {
+			logger.Error("invalid value to fit holt winters", zap.Any("timeValue", timeValue),
+				zap.Stringer("seasonalType", config.seasonalType))
+			return nil, common.ErrorInvalidValue
+		}
+		values = append(values, timeValue.Value)
+	}
+	if periodCnt < 2 || len(values) < 2*periodCnt {
+		logger.Error("need at least 2 full seasons to fit holt winters", zap.Int("periodCnt", periodCnt),
+			zap.Int("pointCnt", len(values)))
+		return nil, common.ErrorInvalidValue
+	}
+
+	params := config.params
+	if params == nil {
+		params = fitParams(values, periodCnt, config.seasonalType)
+	}
+
+	m := &Model{
+		labels:       map[string]string{},
+		period:       periodCnt,
+		interval:     interval,
+		seasonalType: config.seasonalType,
+		params:       *params,
+		confidence:   config.confidence,
+		state:        initState(values, periodCnt, config.seasonalType),
+		lastTime:     datas[len(datas)-1].Time,
+	}
+	for key, value := range timeSeries.Labels {
+		m.labels[key] = value
+	}
+	for i, v := range values {
+		prediction := m.state.predict(1, m.seasonalType)
+		// the first season is used by the initial state, its errors are too optimistic
+		if i >= periodCnt {
+			m.addError(v, prediction)
+		}
+		m.state.update(v, m.params, m.seasonalType)
+	}
+
+	logger.Info("fit holt winters", zap.Any("params", m.params), zap.Stringer("seasonalType", m.seasonalType),
+		zap.Int("periodCnt", periodCnt), zap.Duration("interval", interval), zap.Float64("sigma", m.sigma()))
+	return m, nil
+}
+
+func (m *Model) Params() Params {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.params
+}
+
+func (m *Model) Interval() time.Duration {
+	return m.interval
+}
+
+// Update append the new point without refitting the params, return the prediction of the point made before it,
+// so the caller can check whether the point is in the prediction interval.
+// the missing points since the last one are filled by the predictions, the points not after the last one are skipped
+func (m *Model) Update(ctx context.Context, timeValue model.TimeValue) (*Prediction, error) {
+	logger := utils.GetLogger(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value := timeValue.Value
+	if math.IsNaN(value) || math.IsInf(value, 0) || (m.seasonalType == MultiplicativeSeasonal && value <= 0) {
+		logger.Warn("skip invalid point", zap.Any("timeValue", timeValue))
+		return nil, common.ErrorInvalidValue
+	}
+	steps := int((timeValue.Time.Sub(m.lastTime) + m.interval/2) / m.interval)
+	if steps < 1 {
+		logger.Warn("skip the point not after the last point", zap.Any("timeValue", timeValue),
+			zap.Time("lastTime", m.lastTime))
+		return nil, common.ErrorInvalidValue
+	}
+	if steps > 1 {
+		logger.Debug("fill the missing points", zap.Int("missingCnt", steps-1))
+	}
+
+	prediction := m.prediction(steps, timeValue.Time)
+	for i := 1; i < steps; i++ {
+		m.state.update(m.state.predict(1, m.seasonalType), m.params, m.seasonalType)
+	}
+	m.addError(value, m.state.predict(1, m.seasonalType))
+	m.state.update(value, m.params, m.seasonalType)
+	m.lastTime = timeValue.Time
+	return prediction, nil
+}
+
+// Forecast predict the next horizon points after the last point
+func (m *Model) Forecast(ctx context.Context, horizon int) (*Forecast, error) {
+	logger := utils.GetLogger(ctx)
+
+	if horizon <= 0 {
+		logger.Error("invalid forecast horizon", zap.Int("horizon", horizon))
+		return nil, common.ErrorInvalidValue
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := &Forecast{
+		Mean:  m.newTimeSeries(horizon),
+		Lower: m.newTimeSeries(horizon),
+		Upper: m.newTimeSeries(horizon),
+	}
+	for h := 1; h <= horizon; h++ {
+		prediction := m.prediction(h, m.lastTime.Add(time.Duration(h)*m.interval))
+		res.Mean.Values = append(res.Mean.Values, model.TimeValue{Time: prediction.Time, Value: prediction.Value})
+		res.Lower.Values = append(res.Lower.Values, model.TimeValue{Time: prediction.Time, Value: prediction.Lower})
+		res.Upper.Values = append(res.Upper.Values, model.TimeValue{Time: prediction.Time, Value: prediction.Upper})
+	}
+	return res, nil
+}
+
+// prediction is the h step ahead forecast, the variance is the additive model one of Hyndman et al. 2008,
+//
+//	var(h) = sigma^2 * (1 + sum((alpha * (1 + j * beta) + gamma * (1 - alpha) * [j % period == 0])^2)), j in [1, h)
+//
+// the multiplicative model use the relative sigma, so it's an approximation
+func (m *Model) prediction(h int, t time.Time) *Prediction {
+	value := m.state.predict(h, m.seasonalType)
+
+	factor := 1.0
+	for j := 1; j < h; j++ {
+		c := m.params.Alpha * (1 + float64(j)*m.params.Beta)
+		if j%m.period == 0 {
+			c += m.params.Gamma * (1 - m.params.Alpha)
+		}
+		factor += c * c
+	}
+	width := distuv.UnitNormal.Quantile(0.5+m.confidence/2) * m.sigma() * math.Sqrt(factor)
+	if m.seasonalType == MultiplicativeSeasonal {
+		width *= math.Abs(value)
+	}
+
+	return &Prediction{
+		Time:  t,
+		Value: value,
+		Lower: value - width,
+		Upper: value + width,
+	}
+}
+
+func (m *Model) addError(value, prediction float64) {
+	e := value - prediction
+	if m.seasonalType == MultiplicativeSeasonal && prediction != 0 {
+		e /= prediction
+	}
+	if math.IsNaN(e) || math.IsInf(e, 0) {
+		return
+	}
+	m.sumSquaredError += e * e
+	m.errorCnt++
+}
+
+func (m *Model) sigma() float64 {
+	if m.errorCnt == 0 {
+		return 0
+	}
+	return math.Sqrt(m.sumSquaredError / float64(m.errorCnt))
+}
+
+func (m *Model) newTimeSeries(size int) *model.TimeSeries {
+	res := &model.TimeSeries{
+		Labels: map[string]string{},
+		Values: make([]model.TimeValue, 0, size),
+	}
+	for key, value := range m.labels {
+		res.Labels[key] = value
+	}
+	return res
+}
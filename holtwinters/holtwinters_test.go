@@ -0,0 +1,209 @@
+package holtwinters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var testSeasonals = []float64{1, -1, 2, -2}
+
+// linearSeasonal is 10 + 0.5 * i plus the seasonal of period 4 without noise
+func linearSeasonal(i int) float64 {
+	return 10 + 0.5*float64(i) + testSeasonals[i%4]
+}
+
+func newTimeSeries(n int, value func(i int) float64) *model.TimeSeries {
+	res := &model.TimeSeries{Labels: map[string]string{"host": "a"}}
+	for i := 0; i < n; i++ {
+		res.Values = append(res.Values, model.TimeValue{Time: testStart.Add(time.Duration(i) * time.Minute),
+			Value: value(i)})
+	}
+	return res
+}
+
+func TestInitState(t *testing.T) {
+	values := make([]float64, 12)
+	for i := range values {
+		values[i] = linearSeasonal(i)
+	}
+	state := initState(values, 4, AdditiveSeasonal)
+	// the level is the value of the trend at the point before the first one
+	if math.Abs(state.level-9.5) > 1e-12 || math.Abs(state.trend-0.5) > 1e-12 {
+		t.Errorf("got level %v trend %v, expected 9.5 0.5", state.level, state.trend)
+	}
+	for i, seasonal := range state.seasonals {
+		if math.Abs(seasonal-testSeasonals[i]) > 1e-12 {
+			t.Errorf("got seasonal %v at %v, expected %v", seasonal, i, testSeasonals[i])
+		}
+	}
+
+	factors := []float64{1.2, 0.8, 1.1, 0.9}
+	for i := range values {
+		values[i] = 100 * factors[i%4]
+	}
+	state = initState(values, 4, MultiplicativeSeasonal)
+	for i, seasonal := range state.seasonals {
+		if math.Abs(seasonal-factors[i]) > 1e-12 {
+			t.Errorf("got multiplicative seasonal %v at %v, expected %v", seasonal, i, factors[i])
+		}
+	}
+}
+
+func TestFitExact(t *testing.T) {
+	ctx := context.Background()
+	m, err := Fit(ctx, newTimeSeries(40, linearSeasonal), 4*time.Minute, WithParams(0.5, 0.1, 0.2))
+	if err != nil {
+		t.Fatalf("fit failed: %v", err)
+	}
+	if m.Interval() != time.Minute || m.Params() != (Params{Alpha: 0.5, Beta: 0.1, Gamma: 0.2}) {
+		t.Errorf("got interval %v params %+v", m.Interval(), m.Params())
+	}
+
+	// the series has no noise, the forecast is exact and the interval is empty
+	forecast, err := m.Forecast(ctx, 6)
+	if err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+	for h, timeValue := range forecast.Mean.Values {
+		i := 40 + h
+		if !timeValue.Time.Equal(testStart.Add(time.Duration(i)*time.Minute)) ||
+			math.Abs(timeValue.Value-linearSeasonal(i)) > 1e-9 ||
+			math.Abs(forecast.Upper.Values[h].Value-forecast.Lower.Values[h].Value) > 1e-9 {
+			t.Errorf("got forecast %+v at %v, expected %v", timeValue, i, linearSeasonal(i))
+		}
+	}
+	if forecast.Mean.Labels["host"] != "a" {
+		t.Errorf("got labels %v", forecast.Mean.Labels)
+	}
+	data, err := json.Marshal(forecast)
+	if err != nil {
+		t.Fatalf("marshal forecast failed: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 3 ||
+		fields["mean"] == nil || fields["lower"] == nil || fields["upper"] == nil {
+		t.Errorf("got forecast json %s, err %v", data, err)
+	}
+
+	// the missing point is filled by the prediction, the returned prediction is 2 steps ahead
+	prediction, err := m.Update(ctx, model.TimeValue{Time: testStart.Add(41 * time.Minute), Value: linearSeasonal(41)})
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if math.Abs(prediction.Value-linearSeasonal(41)) > 1e-9 || !prediction.Contains(linearSeasonal(41)) {
+		t.Errorf("got prediction %+v, expected %v", prediction, linearSeasonal(41))
+	}
+	if _, err := m.Update(ctx, model.TimeValue{Time: testStart.Add(41 * time.Minute), Value: 1}); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the point not after the last one", err)
+	}
+}
+
+func TestFitMultiplicative(t *testing.T) {
+	factors := []float64{1.2, 0.8, 1.1, 0.9}
+	timeSeries := newTimeSeries(24, func(i int) float64 {
+		return 100 * factors[i%4]
+	})
+	m, err := Fit(context.Background(), timeSeries, 4*time.Minute, WithSeasonalType(MultiplicativeSeasonal),
+		WithParams(0.3, 0.1, 0.1))
+	if err != nil {
+		t.Fatalf("fit failed: %v", err)
+	}
+	forecast, err := m.Forecast(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+	for h, timeValue := range forecast.Mean.Values {
+		if expected := 100 * factors[(24+h)%4]; math.Abs(timeValue.Value-expected) > 1e-9 {
+			t.Errorf("got forecast %v at %v, expected %v", timeValue.Value, h, expected)
+		}
+	}
+
+	timeSeries.Values[3].Value = 0
+	if _, err := Fit(context.Background(), timeSeries, 4*time.Minute,
+		WithSeasonalType(MultiplicativeSeasonal)); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the non positive value", err)
+	}
+}
+
+func TestFitParamsAndInterval(t *testing.T) {
+	ctx := context.Background()
+	random := rand.New(rand.NewSource(1))
+	noisy := func(i int) float64 {
+		return 100 + 10*math.Sin(2*math.Pi*float64(i)/24) + random.NormFloat64()
+	}
+	m, err := Fit(ctx, newTimeSeries(24*10, noisy), 24*time.Minute)
+	if err != nil {
+		t.Fatalf("fit failed: %v", err)
+	}
+	params := m.Params()
+	if !params.valid() || params.Alpha <= 0 || params.Alpha >= 1 {
+		t.Errorf("got params %+v", params)
+	}
+	// the one step sigma is about the noise stddev
+	if sigma := m.sigma(); sigma < 0.8 || sigma > 1.3 {
+		t.Errorf("got sigma %v, expected about 1", sigma)
+	}
+
+	forecast, err := m.Forecast(ctx, 24)
+	if err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+	for h := 1; h < 24; h++ {
+		// the interval widen with the horizon
+		width := forecast.Upper.Values[h].Value - forecast.Lower.Values[h].Value
+		previous := forecast.Upper.Values[h-1].Value - forecast.Lower.Values[h-1].Value
+		if width < previous-1e-9 {
+			t.Errorf("got width %v at %v smaller than %v", width, h, previous)
+		}
+		expected := 100 + 10*math.Sin(2*math.Pi*float64(240+h)/24)
+		if math.Abs(forecast.Mean.Values[h].Value-expected) > 2 {
+			t.Errorf("got forecast %v at %v, expected about %v", forecast.Mean.Values[h].Value, h, expected)
+		}
+	}
+
+	// the spike is out of the prediction interval
+	spike := model.TimeValue{Time: testStart.Add(240 * time.Minute), Value: noisy(240) + 20}
+	prediction, err := m.Update(ctx, spike)
+	if err != nil || prediction.Contains(spike.Value) {
+		t.Errorf("got prediction %+v err %v for the spike %v", prediction, err, spike.Value)
+	}
+}
+
+func TestFitInvalid(t *testing.T) {
+	ctx := context.Background()
+	timeSeries := newTimeSeries(6, linearSeasonal)
+	if _, err := Fit(ctx, timeSeries, 4*time.Minute); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v with less than 2 seasons", err)
+	}
+	timeSeries = newTimeSeries(12, linearSeasonal)
+	if _, err := Fit(ctx, timeSeries, 4*time.Minute, WithParams(1.5, 0, 0)); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v with invalid params", err)
+	}
+	if _, err := Fit(ctx, &model.TimeSeries{}, 4*time.Minute); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the empty series", err)
+	}
+	gap := &model.TimeSeries{Values: append(append([]model.TimeValue{}, timeSeries.Values[:5]...),
+		timeSeries.Values[6:]...)}
+	if _, err := Fit(ctx, gap, 4*time.Minute); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the series with gap", err)
+	}
+	m, err := Fit(ctx, timeSeries, 4*time.Minute)
+	if err != nil {
+		t.Fatalf("fit failed: %v", err)
+	}
+	if _, err := m.Forecast(ctx, 0); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the zero horizon", err)
+	}
+}
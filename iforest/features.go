@@ -0,0 +1,189 @@
+package iforest
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/bocd"
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	FeatureValue                = "value"
+	FeatureKdeTailProbability   = "kde_tail_probability"
+	FeatureRunLengthProbability = "run_length_probability"
+	FeatureWeekOverWeekRatio    = "week_over_week_ratio"
+)
+
+const week = 7 * 24 * time.Hour
+
+type featureConfig struct {
+	kdeConfidences map[int64]*model.KdeConfidence
+	posterior      *bocd.RunLengthPosterior
+	weekOverWeek   bool
+}
+
+type FeatureOption func(*featureConfig)
+
+// WithKdeConfidences add the kde tail probability, min(q, 1 - q) of the quantile q of the value.
+// the key is the unix timestamp in seconds, the same as kde.CalculateKdeConfidences.
+// the point without confidence is 0.5
+func WithKdeConfidences(confidences map[int64]*model.KdeConfidence) FeatureOption {
+	return func(c *featureConfig) {
+		c.kdeConfidences = confidences
+	}
+}
+
+// WithRunLengthPosterior add the posterior probability of the most likely run length, it's small when bocd
+// is not sure about the run, like around the change points. the point not in the posterior is 1
+func WithRunLengthPosterior(posterior *bocd.RunLengthPosterior) FeatureOption {
+	return func(c *featureConfig) {
+		c.posterior = posterior
+	}
+}
+
+// WithWeekOverWeek add the ratio of the value to the value of the same time last week in the series,
+// the point without the last week value or the last week value is 0 is 1
+func WithWeekOverWeek() FeatureOption {
+	return func(c *featureConfig) {
+		c.weekOverWeek = true
+	}
+}
+
+// FeatureSet is the feature vectors of the points, Vectors[i][j] is the feature Names[j] of the point at Times[i]
+type FeatureSet struct {
+	Names   []string
+	Times   []time.Time
+	Vectors [][]float64
+}
+
+// BuildFeatures build the feature vector of each point in the time order, the value is always the first
+// feature and the others are in the order of the constants. the missing features are filled with the neutral
+// values so every point has a full vector
+func BuildFeatures(ctx context.Context, timeSeries *model.TimeSeries, opts ...FeatureOption) (*FeatureSet, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := &featureConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if timeSeries.IsEmpty() {
+		logger.Error("empty time series to build features")
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := make([]model.TimeValue, 0, len(timeSeries.Values))
+	for _, timeValue := range timeSeries.Values {
+		if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) {
+			logger.Warn("skip invalid point", zap.Any("timeValue", timeValue))
+			continue
+		}
+		datas = append(datas, timeValue)
+	}
+	datas = utils.SortedTimeValues(datas)
+
+	res := &FeatureSet{
+		Names:   []string{FeatureValue},
+		Times:   make([]time.Time, 0, len(datas)),
+		Vectors: make([][]float64, 0, len(datas)),
+	}
+	if config.kdeConfidences != nil {
+		res.Names = append(res.Names, FeatureKdeTailProbability)
+	}
+	var runLengthProbabilities map[int64]float64
+	if config.posterior != nil {
+		res.Names = append(res.Names, FeatureRunLengthProbability)
+		runLengthProbabilities = maxRunLengthProbabilities(config.posterior)
+	}
+	var values map[int64]float64
+	if config.weekOverWeek {
+		res.Names = append(res.Names, FeatureWeekOverWeekRatio)
+		values = make(map[int64]float64, len(datas))
+		for _, timeValue := range datas {
+			values[timeValue.Time.UnixNano()] = timeValue.Value
+		}
+	}
+
+	for _, timeValue := range datas {
+		vector := make([]float64, 0, len(res.Names))
+		vector = append(vector, timeValue.Value)
+		if config.kdeConfidences != nil {
+			vector = append(vector, kdeTailProbability(config.kdeConfidences[timeValue.Time.Unix()], timeValue.Value))
+		}
+		if runLengthProbabilities != nil {
+			probability, ok := runLengthProbabilities[timeValue.Time.UnixNano()]
+			if !ok {
+				probability = 1
+			}
+			vector = append(vector, probability)
+		}
+		if values != nil {
+			ratio := 1.0
+			if lastWeek, ok := values[timeValue.Time.Add(-week).UnixNano()]; ok && lastWeek != 0 {
+				ratio = timeValue.Value / lastWeek
+			}
+			vector = append(vector, ratio)
+		}
+		res.Times = append(res.Times, timeValue.Time)
+		res.Vectors = append(res.Vectors, vector)
+	}
+	return res, nil
+}
+
+// kdeTailProbability interpolate the quantile of the value with the quantile values of the confidence,
+// the value out of the quantiles use the nearest quantile
+func kdeTailProbability(confidence *model.KdeConfidence, value float64) float64 {
+	if confidence == nil || len(confidence.QuantileValues) == 0 {
+		return 0.5
+	}
+	quantileValues := make([]*model.QuantileValue, 0, len(confidence.QuantileValues))
+	for _, quantileValue := range confidence.QuantileValues {
+		if quantileValue != nil {
+			quantileValues = append(quantileValues, quantileValue)
+		}
+	}
+	if len(quantileValues) == 0 {
+		return 0.5
+	}
+	sort.Slice(quantileValues, func(i, j int) bool {
+		return quantileValues[i].Quantile < quantileValues[j].Quantile
+	})
+
+	quantile := quantileValues[len(quantileValues)-1].Quantile
+	switch {
+	case value <= quantileValues[0].Value:
+		quantile = quantileValues[0].Quantile
+	case value < quantileValues[len(quantileValues)-1].Value:
+		for i := 1; i < len(quantileValues); i++ {
+			lower, upper := quantileValues[i-1], quantileValues[i]
+			if value > upper.Value {
+				continue
+			}
+			quantile = upper.Quantile
+			if upper.Value > lower.Value {
+				ratio := (value - lower.Value) / (upper.Value - lower.Value)
+				quantile = lower.Quantile + (upper.Quantile-lower.Quantile)*ratio
+			}
+			break
+		}
+	}
+	return math.Min(quantile, 1-quantile)
+}
+
+// maxRunLengthProbabilities the key is the unix nano of the point
+func maxRunLengthProbabilities(posterior *bocd.RunLengthPosterior) map[int64]float64 {
+	res := make(map[int64]float64, len(posterior.Datas))
+	for _, entry := range posterior.Entries {
+		if entry.Step >= len(posterior.Datas) || entry.Step >= len(posterior.MaxRunLengths) ||
+			entry.RunLength != posterior.MaxRunLengths[entry.Step] {
+			continue
+		}
+		res[posterior.Datas[entry.Step].Time.UnixNano()] = entry.Probability
+	}
+	return res
+}
@@ -0,0 +1,306 @@
+package iforest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// eulerGamma is used by the average path length of the unsuccessful search in the binary search tree
+const eulerGamma = 0.5772156649
+
+type forestConfig struct {
+	treeCnt        int
+	sampleSize     int
+	extensionLevel int
+	seed           int64
+}
+
+type Option func(*forestConfig)
+
+// WithTreeCnt default is 100
+func WithTreeCnt(treeCnt int) Option {
+	return func(c *forestConfig) {
+		if treeCnt > 0 {
+			c.treeCnt = treeCnt
+		}
+	}
+}
+
+// WithSampleSize the points used by each tree, default is 256, limited by the sample count
+func WithSampleSize(sampleSize int) Option {
+	return func(c *forestConfig) {
+		if sampleSize > 1 {
+			c.sampleSize = sampleSize
+		}
+	}
+}
+
+// WithExtensionLevel the extended isolation forest of Hariri et al. 2019, the split is the random hyperplane
+// with extensionLevel + 1 non zero coefficients. default is 0, the standard isolation forest with the axis
+// parallel splits. -1 means the full extension, dims - 1. the hyperplanes are not scale invariant,
+// the features should be in the similar scales
+func WithExtensionLevel(extensionLevel int) Option {
+	return func(c *forestConfig) {
+		c.extensionLevel = extensionLevel
+	}
+}
+
+// WithSeed the forest is the same for the same samples and seed, default is 0
+func WithSeed(seed int64) Option {
+	return func(c *forestConfig) {
+		c.seed = seed
+	}
+}
+
+func newForestConfig(opts ...Option) *forestConfig {
+	config := &forestConfig{
+		treeCnt:    100,
+		sampleSize: 256,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// Node is the split node or leaf of the tree. the axis parallel split go left if x[Feature] < Threshold,
+// the hyperplane split go left if dot(x, Normal) < Threshold
+type Node struct {
+	Feature   int       `json:"feature,omitempty"`
+	Normal    []float64 `json:"normal,omitempty"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Left      *Node     `json:"left,omitempty"`
+	Right     *Node     `json:"right,omitempty"`
+	// Size is the sample count of the leaf
+	Size int `json:"size,omitempty"`
+}
+
+func (n *Node) isLeaf() bool {
+	return n.Left == nil || n.Right == nil
+}
+
+func (n *Node) goLeft(x []float64) bool {
+	if n.Normal == nil {
+		return x[n.Feature] < n.Threshold
+	}
+	return dot(x, n.Normal) < n.Threshold
+}
+
+// Forest is the fitted isolation forest, the json is the serialization of it
+type Forest struct {
+	Dims           int     `json:"dims"`
+	SampleSize     int     `json:"sample_size"`
+	ExtensionLevel int     `json:"extension_level"`
+	Seed           int64   `json:"seed"`
+	Trees          []*Node `json:"trees"`
+}
+
+// Fit build the forest with the samples, all of them need the same dims and no NaN or Inf
+func Fit(ctx context.Context, samples [][]float64, opts ...Option) (*Forest, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := newForestConfig(opts...)
+	if len(samples) < 2 || len(samples[0]) == 0 {
+		logger.Error("too few samples to fit isolation forest", zap.Int("sampleCnt", len(samples)))
+		return nil, common.ErrorInvalidValue
+	}
+	dims := len(samples[0])
+	for _, sample := range samples {
+		if len(sample) != dims || invalidSample(sample) {
+			logger.Error("invalid sample to fit isolation forest", zap.Float64s("sample", sample), zap.Int("dims", dims))
+			return nil, common.ErrorInvalidValue
+		}
+	}
+
+	extensionLevel := config.extensionLevel
+	if extensionLevel < 0 || extensionLevel > dims-1 {
+		extensionLevel = dims - 1
+	}
+	forest := &Forest{
+		Dims:           dims,
+		SampleSize:     min(config.sampleSize, len(samples)),
+		ExtensionLevel: extensionLevel,
+		Seed:           config.seed,
+		Trees:          make([]*Node, 0, config.treeCnt),
+	}
+
+	builder := &treeBuilder{
+		random:         rand.New(rand.NewSource(config.seed)),
+		dims:           dims,
+		extensionLevel: extensionLevel,
+		maxDepth:       int(math.Ceil(math.Log2(float64(forest.SampleSize)))),
+	}
+	for i := 0; i < config.treeCnt; i++ {
+		indexes := builder.random.Perm(len(samples))[:forest.SampleSize]
+		subSamples := make([][]float64, 0, len(indexes))
+		for _, index := range indexes {
+			subSamples = append(subSamples, samples[index])
+		}
+		forest.Trees = append(forest.Trees, builder.build(subSamples, 0))
+	}
+
+	logger.Debug("fit isolation forest", zap.Int("sampleCnt", len(samples)), zap.Int("dims", dims),
+		zap.Int("treeCnt", config.treeCnt), zap.Int("sampleSize", forest.SampleSize),
+		zap.Int("extensionLevel", extensionLevel))
+	return forest, nil
+}
+
+// Score is the anomaly score 2^(-E(h(x)) / c(sampleSize)) in (0, 1], close to 1 is anomaly,
+// much smaller than 0.5 is normal
+func (f *Forest) Score(x []float64) (float64, error) {
+	if len(x) != f.Dims || invalidSample(x) || len(f.Trees) == 0 {
+		return 0, common.ErrorInvalidValue
+	}
+
+	sumPathLength := 0.0
+	for _, tree := range f.Trees {
+		sumPathLength += pathLength(tree, x)
+	}
+	averagePathLength := sumPathLength / float64(len(f.Trees))
+	return math.Pow(2, -averagePathLength/averageSearchLength(f.SampleSize)), nil
+}
+
+// ScoreBatch score each sample, the invalid sample is NaN
+func (f *Forest) ScoreBatch(samples [][]float64) []float64 {
+	res := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		score, err := f.Score(sample)
+		if err != nil {
+			score = math.NaN()
+		}
+		res = append(res, score)
+	}
+	return res
+}
+
+func (f *Forest) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(f)
+}
+
+// ReadJSON read the forest written by WriteJSON
+func ReadJSON(r io.Reader) (*Forest, error) {
+	forest := &Forest{}
+	if err := json.NewDecoder(r).Decode(forest); err != nil {
+		return nil, err
+	}
+	if forest.Dims <= 0 || forest.SampleSize <= 0 || len(forest.Trees) == 0 {
+		return nil, common.ErrorInvalidValue
+	}
+	return forest, nil
+}
+
+type treeBuilder struct {
+	random         *rand.Rand
+	dims           int
+	extensionLevel int
+	maxDepth       int
+}
+
+func (b *treeBuilder) build(samples [][]float64, depth int) *Node {
+	if depth >= b.maxDepth || len(samples) <= 1 {
+		return &Node{Size: len(samples)}
+	}
+
+	lower, upper := bounds(samples, b.dims)
+	features := []int{}
+	for i := 0; i < b.dims; i++ {
+		if upper[i] > lower[i] {
+			features = append(features, i)
+		}
+	}
+	// all the samples are the same
+	if len(features) == 0 {
+		return &Node{Size: len(samples)}
+	}
+
+	node := &Node{}
+	if b.extensionLevel == 0 {
+		node.Feature = features[b.random.Intn(len(features))]
+		node.Threshold = lower[node.Feature] + b.random.Float64()*(upper[node.Feature]-lower[node.Feature])
+	} else {
+		// the normal has extensionLevel + 1 non zero coefficients, the intercept is uniform in the bounding box
+		node.Normal = make([]float64, b.dims)
+		for _, i := range b.random.Perm(b.dims)[:b.extensionLevel+1] {
+			node.Normal[i] = b.random.NormFloat64()
+		}
+		intercept := make([]float64, b.dims)
+		for i := range intercept {
+			intercept[i] = lower[i] + b.random.Float64()*(upper[i]-lower[i])
+		}
+		node.Threshold = dot(intercept, node.Normal)
+	}
+
+	left, right := [][]float64{}, [][]float64{}
+	for _, sample := range samples {
+		if node.goLeft(sample) {
+			left = append(left, sample)
+		} else {
+			right = append(right, sample)
+		}
+	}
+	node.Left = b.build(left, depth+1)
+	node.Right = b.build(right, depth+1)
+	return node
+}
+
+// pathLength the leaf add the average path length of its samples as the unbuilt subtree
+func pathLength(node *Node, x []float64) float64 {
+	depth := 0.0
+	for !node.isLeaf() {
+		if node.goLeft(x) {
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+		depth++
+	}
+	return depth + averageSearchLength(node.Size)
+}
+
+// averageSearchLength is c(n) = 2 * H(n - 1) - 2 * (n - 1) / n
+func averageSearchLength(n int) float64 {
+	switch {
+	case n <= 1:
+		return 0
+	case n == 2:
+		return 1
+	}
+	m := float64(n)
+	return 2*(math.Log(m-1)+eulerGamma) - 2*(m-1)/m
+}
+
+func bounds(samples [][]float64, dims int) ([]float64, []float64) {
+	lower := append([]float64{}, samples[0]...)
+	upper := append([]float64{}, samples[0]...)
+	for _, sample := range samples[1:] {
+		for i := 0; i < dims; i++ {
+			lower[i] = min(lower[i], sample[i])
+			upper[i] = max(upper[i], sample[i])
+		}
+	}
+	return lower, upper
+}
+
+func dot(a, b []float64) float64 {
+	res := 0.0
+	for i := range a {
+		res += a[i] * b[i]
+	}
+	return res
+}
+
+func invalidSample(sample []float64) bool {
+	for _, v := range sample {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package iforest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/bocd"
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestSamples is a 2d gaussian cluster with one far point at the end
+func newTestSamples(n int) [][]float64 {
+	random := rand.New(rand.NewSource(1))
+	res := make([][]float64, 0, n+1)
+	for i := 0; i < n; i++ {
+		res = append(res, []float64{random.NormFloat64(), random.NormFloat64()})
+	}
+	return append(res, []float64{6, -6})
+}
+
+func TestAverageSearchLength(t *testing.T) {
+	cases := []struct {
+		n        int
+		expected float64
+	}{
+		{n: 0, expected: 0},
+		{n: 1, expected: 0},
+		{n: 2, expected: 1},
+		// 2 * (ln(255) + 0.5772156649) - 2 * 255 / 256
+		{n: 256, expected: 10.244770920116851},
+	}
+	for _, c := range cases {
+		if got := averageSearchLength(c.n); math.Abs(got-c.expected) > 1e-9 {
+			t.Errorf("c(%v) got %v, expected %v", c.n, got, c.expected)
+		}
+	}
+}
+
+func TestFitScore(t *testing.T) {
+	ctx := context.Background()
+	samples := newTestSamples(500)
+	for _, extensionLevel := range []int{0, -1} {
+		forest, err := Fit(ctx, samples, WithSeed(1), WithExtensionLevel(extensionLevel))
+		if err != nil {
+			t.Fatalf("fit failed: %v", err)
+		}
+		if len(forest.Trees) != 100 || forest.SampleSize != 256 || forest.Dims != 2 {
+			t.Fatalf("got forest of %v trees, sample size %v, dims %v", len(forest.Trees), forest.SampleSize,
+				forest.Dims)
+		}
+		if extensionLevel == -1 && forest.ExtensionLevel != 1 {
+			t.Errorf("got extension level %v, expected 1", forest.ExtensionLevel)
+		}
+
+		scores := forest.ScoreBatch(samples)
+		outlierScore := scores[len(scores)-1]
+		for i, score := range scores[:len(scores)-1] {
+			if score >= outlierScore {
+				t.Errorf("extension level %v sample %v got score %v, larger than the outlier %v", extensionLevel,
+					samples[i], score, outlierScore)
+			}
+		}
+		center, _ := forest.Score([]float64{0, 0})
+		if outlierScore < 0.7 || center > 0.5 {
+			t.Errorf("extension level %v got outlier score %v, center score %v", extensionLevel, outlierScore, center)
+		}
+	}
+}
+
+func TestFitSeed(t *testing.T) {
+	ctx := context.Background()
+	samples := newTestSamples(300)
+	forest1, _ := Fit(ctx, samples, WithSeed(7), WithTreeCnt(20))
+	forest2, _ := Fit(ctx, samples, WithSeed(7), WithTreeCnt(20))
+	forest3, _ := Fit(ctx, samples, WithSeed(8), WithTreeCnt(20))
+
+	x := []float64{1.5, 0.5}
+	score1, _ := forest1.Score(x)
+	score2, _ := forest2.Score(x)
+	score3, _ := forest3.Score(x)
+	if score1 != score2 {
+		t.Errorf("the same seed got scores %v and %v", score1, score2)
+	}
+	if score1 == score3 {
+		t.Errorf("the different seeds got the same score %v", score1)
+	}
+}
+
+func TestForestJSON(t *testing.T) {
+	ctx := context.Background()
+	samples := newTestSamples(300)
+	for _, extensionLevel := range []int{0, 1} {
+		forest, err := Fit(ctx, samples, WithSeed(1), WithTreeCnt(10), WithExtensionLevel(extensionLevel))
+		if err != nil {
+			t.Fatalf("fit failed: %v", err)
+		}
+		buf := &bytes.Buffer{}
+		if err := forest.WriteJSON(buf); err != nil {
+			t.Fatalf("write json failed: %v", err)
+		}
+		read, err := ReadJSON(buf)
+		if err != nil {
+			t.Fatalf("read json failed: %v", err)
+		}
+		if read.ExtensionLevel != extensionLevel || read.Seed != 1 || len(read.Trees) != 10 {
+			t.Fatalf("got forest %+v", read)
+		}
+		expected, got := forest.ScoreBatch(samples), read.ScoreBatch(samples)
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("extension level %v sample %v got score %v, expected %v", extensionLevel, i, got[i],
+					expected[i])
+			}
+		}
+	}
+
+	if _, err := ReadJSON(bytes.NewBufferString(`{"dims": 2, "sample_size": 10, "trees": []}`)); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the forest without trees", err)
+	}
+}
+
+func TestFitInvalid(t *testing.T) {
+	ctx := context.Background()
+	cases := [][][]float64{
+		{{1, 2}},
+		{{}, {}},
+		{{1, 2}, {1}},
+		{{1, 2}, {math.NaN(), 1}},
+		{{1, 2}, {math.Inf(1), 1}},
+	}
+	for _, samples := range cases {
+		if _, err := Fit(ctx, samples); !errors.Is(err, common.ErrorInvalidValue) {
+			t.Errorf("samples %v got error %v", samples, err)
+		}
+	}
+
+	forest, _ := Fit(ctx, newTestSamples(50), WithSeed(1))
+	if _, err := forest.Score([]float64{1}); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the wrong dims", err)
+	}
+	if scores := forest.ScoreBatch([][]float64{{math.NaN(), 0}}); !math.IsNaN(scores[0]) {
+		t.Errorf("got score %v for nan", scores[0])
+	}
+}
+
+func TestKdeTailProbability(t *testing.T) {
+	confidence := &model.KdeConfidence{QuantileValues: map[string]*model.QuantileValue{
+		"0.1": {Quantile: 0.1, Value: 10},
+		"0.5": {Quantile: 0.5, Value: 20},
+		"0.9": {Quantile: 0.9, Value: 40},
+	}}
+	cases := []struct {
+		value    float64
+		expected float64
+	}{
+		{value: 0, expected: 0.1},
+		{value: 15, expected: 0.3},
+		{value: 20, expected: 0.5},
+		{value: 30, expected: 0.3},
+		{value: 50, expected: 0.1},
+	}
+	for _, c := range cases {
+		if got := kdeTailProbability(confidence, c.value); math.Abs(got-c.expected) > 1e-9 {
+			t.Errorf("value %v got %v, expected %v", c.value, got, c.expected)
+		}
+	}
+	if got := kdeTailProbability(nil, 1); got != 0.5 {
+		t.Errorf("got %v without confidence, expected 0.5", got)
+	}
+}
+
+func TestBuildFeatures(t *testing.T) {
+	ctx := context.Background()
+	timeSeries := &model.TimeSeries{}
+	// one point a day for two weeks, in the reversed order with a nan
+	for day := 13; day >= 0; day-- {
+		timeSeries.Values = append(timeSeries.Values, model.TimeValue{Time: testStart.Add(time.Duration(day) * 24 *
+			time.Hour), Value: float64(day + 1)})
+	}
+	timeSeries.Values = append(timeSeries.Values, model.TimeValue{Time: testStart.Add(time.Hour), Value: math.NaN()})
+
+	confidences := map[int64]*model.KdeConfidence{
+		testStart.Unix(): {QuantileValues: map[string]*model.QuantileValue{
+			"0.05": {Quantile: 0.05, Value: 1},
+			"0.95": {Quantile: 0.95, Value: 3},
+		}},
+	}
+	posterior := &bocd.RunLengthPosterior{
+		Datas:         []model.TimeValue{{Time: testStart.Add(24 * time.Hour), Value: 2}},
+		MaxRunLengths: []int{1},
+		Entries: []bocd.RunLengthEntry{
+			{Step: 0, RunLength: 0, Probability: 0.4},
+			{Step: 0, RunLength: 1, Probability: 0.6},
+		},
+	}
+
+	features, err := BuildFeatures(ctx, timeSeries, WithWeekOverWeek(), WithRunLengthPosterior(posterior),
+		WithKdeConfidences(confidences))
+	if err != nil {
+		t.Fatalf("build features failed: %v", err)
+	}
+	expectedNames := []string{FeatureValue, FeatureKdeTailProbability, FeatureRunLengthProbability,
+		FeatureWeekOverWeekRatio}
+	if len(features.Names) != len(expectedNames) {
+		t.Fatalf("got names %v, expected %v", features.Names, expectedNames)
+	}
+	for i := range expectedNames {
+		if features.Names[i] != expectedNames[i] {
+			t.Errorf("got names %v, expected %v", features.Names, expectedNames)
+		}
+	}
+	if len(features.Vectors) != 14 || !features.Times[0].Equal(testStart) {
+		t.Fatalf("got %v vectors begin at %v", len(features.Vectors), features.Times[0])
+	}
+
+	expectedVectors := map[int][]float64{
+		0:  {1, 0.05, 1, 1},
+		1:  {2, 0.5, 0.6, 1},
+		7:  {8, 0.5, 1, 8},
+		13: {14, 0.5, 1, 14.0 / 7},
+	}
+	for day, expected := range expectedVectors {
+		for i := range expected {
+			if math.Abs(features.Vectors[day][i]-expected[i]) > 1e-9 {
+				t.Errorf("day %v got %v, expected %v", day, features.Vectors[day], expected)
+				break
+			}
+		}
+	}
+
+	if _, err := BuildFeatures(ctx, &model.TimeSeries{}); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the empty series", err)
+	}
+}
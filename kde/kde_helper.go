@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/metrics"
 	"github.com/uyouii/timeseries-algorithms/model"
 	"github.com/uyouii/timeseries-algorithms/utils"
 	"go.uber.org/zap"
@@ -20,12 +22,32 @@ func getMinCalculatePointCnt() int {
 	return KdeMinCalculatePointCnt
 }
 
+type kdeCalculateConfig struct {
+	metrics   *metrics.KdeMetrics
+	seriesKey string
+}
+
+type KdeOption func(*kdeCalculateConfig)
+
+// WithMetrics record the calculation metrics labelled by the series key
+func WithMetrics(kdeMetrics *metrics.KdeMetrics, seriesKey string) KdeOption {
+	return func(c *kdeCalculateConfig) {
+		c.metrics = kdeMetrics
+		c.seriesKey = seriesKey
+	}
+}
+
 // kde algorithms need the record values,
 // then calcualte the kde confidence
 func CalculateKdeConfidences(ctx context.Context, timestamp int64,
-	recordValues []model.RecordValue) (*model.KdeConfidence, error) {
+	recordValues []model.RecordValue, opts ...KdeOption) (*model.KdeConfidence, error) {
 	logger := utils.GetLogger(ctx)
 
+	config := &kdeCalculateConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	defer func() {
 		if err := recover(); err != nil {
 			logger.Error("CalculateKdeConfidences recover panic error!", zap.Any("err", err),
@@ -56,6 +78,7 @@ func CalculateKdeConfidences(ctx context.Context, timestamp int64,
 
 	if len(values) < getMinCalculatePointCnt() {
 		logger.Error("point too little, skip calculate", zap.Int("cnt", len(values)))
+		config.metrics.IncSkipped(config.seriesKey, metrics.KdeSkipReasonTooFewPoints)
 		return nil, common.ErrorInvalidValue
 	}
 
@@ -71,12 +94,15 @@ func CalculateKdeConfidences(ctx context.Context, timestamp int64,
 	if mean < getMinCalculateSpeed() {
 		logger.Error("metric speed is too low, don't need calcualte kde",
 			zap.Float64("mean", mean))
+		config.metrics.IncSkipped(config.seriesKey, metrics.KdeSkipReasonLowSpeed)
 		return nil, common.ErrorInvalidValue
 	}
 
+	beginTime := time.Now()
 	k, err := NewKDEUnivariate(values, weights, 1.0, 4.0, clip)
 	if err != nil {
 		logger.Error("NewKDEUnivariate failed", zap.Error(err))
+		config.metrics.IncSkipped(config.seriesKey, metrics.KdeSkipReasonFitFailed)
 		return nil, err
 	}
 
@@ -92,6 +118,8 @@ func CalculateKdeConfidences(ctx context.Context, timestamp int64,
 		calculatedQuantiles[fmt.Sprintf("%v", value)] = quantile
 	}
 
+	config.metrics.ObserveFit(config.seriesKey, time.Since(beginTime), len(k.Endog))
+
 	return &model.KdeConfidence{
 		QuantileValues: calculatedQuantiles,
 	}, nil
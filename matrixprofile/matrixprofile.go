@@ -0,0 +1,295 @@
+package matrixprofile
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// constantStd the subsequence with smaller stddev is treated as constant
+const constantStd = 1e-8
+
+type profileConfig struct {
+	exclusionZone float64
+	maxPoints     int // only used by stampi, 0 means no limit
+}
+
+type Option func(*profileConfig)
+
+// WithExclusionZone the neighbours within window * zone of the subsequence are the trivial matches
+// and ignored, default is 0.25
+func WithExclusionZone(zone float64) Option {
+	return func(c *profileConfig) {
+		if zone >= 0 {
+			c.exclusionZone = zone
+		}
+	}
+}
+
+// WithMaxPoints limit the points kept by stampi, the oldest half is dropped when exceeded and the
+// profile is rebuilt with stomp, so the amortized cost of each point is still O(n)
+func WithMaxPoints(maxPoints int) Option {
+	return func(c *profileConfig) {
+		if maxPoints >= 0 {
+			c.maxPoints = maxPoints
+		}
+	}
+}
+
+func newProfileConfig(opts ...Option) *profileConfig {
+	config := &profileConfig{
+		exclusionZone: 0.25,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+func (c *profileConfig) exclusion(window int) int {
+	return int(math.Ceil(float64(window) * c.exclusionZone))
+}
+
+// Profile is the matrix profile of the self join, Distances[i] is the z-normalized euclidean distance of the
+// subsequence starting at i to its nearest neighbour Indexes[i], the distance is +Inf and the index is -1
+// if there is no neighbour out of the exclusion zone
+type Profile struct {
+	Window    int
+	Times     []time.Time // the start time of each subsequence
+	Distances []float64
+	Indexes   []int
+	exclusion int
+}
+
+// Pattern is one discord or motif, the subsequences are datas[Index : Index+Window]
+// and datas[NeighborIndex : NeighborIndex+Window]
+type Pattern struct {
+	Index         int       `json:"index"`
+	Time          time.Time `json:"time"`
+	NeighborIndex int       `json:"neighbor_index"`
+	NeighborTime  time.Time `json:"neighbor_time"`
+	Distance      float64   `json:"distance"`
+}
+
+// Discords return the k subsequences farthest to their nearest neighbours, the most unusual shapes.
+// the discords don't overlap with each other
+func (p *Profile) Discords(k int) []*Pattern {
+	order := p.order(func(i, j int) bool {
+		return p.Distances[i] > p.Distances[j]
+	})
+
+	res := []*Pattern{}
+	used := make([]bool, len(p.Distances))
+	for _, i := range order {
+		if len(res) >= k {
+			break
+		}
+		if used[i] {
+			continue
+		}
+		res = append(res, p.newPattern(i))
+		p.markUsed(used, i)
+	}
+	return res
+}
+
+// Motifs return the k closest subsequence pairs, the most repeated shapes.
+// the motifs don't overlap with each other
+func (p *Profile) Motifs(k int) []*Pattern {
+	order := p.order(func(i, j int) bool {
+		return p.Distances[i] < p.Distances[j]
+	})
+
+	res := []*Pattern{}
+	used := make([]bool, len(p.Distances))
+	for _, i := range order {
+		if len(res) >= k {
+			break
+		}
+		neighbor := p.Indexes[i]
+		if used[i] || used[neighbor] {
+			continue
+		}
+		res = append(res, p.newPattern(i))
+		p.markUsed(used, i)
+		p.markUsed(used, neighbor)
+	}
+	return res
+}
+
+// order return the indexes with the neighbour sorted by less
+func (p *Profile) order(less func(i, j int) bool) []int {
+	res := make([]int, 0, len(p.Distances))
+	for i, distance := range p.Distances {
+		if p.Indexes[i] >= 0 && !math.IsInf(distance, 0) {
+			res = append(res, i)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return less(res[i], res[j])
+	})
+	return res
+}
+
+func (p *Profile) markUsed(used []bool, index int) {
+	for i := max(index-p.Window+1, 0); i < min(index+p.Window, len(used)); i++ {
+		used[i] = true
+	}
+}
+
+func (p *Profile) newPattern(i int) *Pattern {
+	return &Pattern{
+		Index:         i,
+		Time:          p.Times[i],
+		NeighborIndex: p.Indexes[i],
+		NeighborTime:  p.Times[p.Indexes[i]],
+		Distance:      p.Distances[i],
+	}
+}
+
+// STOMP compute the matrix profile of the series with the subsequence length window in O(n^2) time and
+// O(n) memory, Zhu et al. 2016. the series need be regular spaced, the missing points should be filled before
+func STOMP(ctx context.Context, timeSeries *model.TimeSeries, window int, opts ...Option) (*Profile, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := newProfileConfig(opts...)
+	datas, err := sortedDatas(timeSeries)
+	if err != nil {
+		logger.Error("invalid time series to compute matrix profile", zap.Error(err))
+		return nil, err
+	}
+	if window < 3 || len(datas) < 2*window {
+		logger.Error("time series too short to compute matrix profile", zap.Int("window", window),
+			zap.Int("pointCnt", len(datas)))
+		return nil, common.ErrorInvalidValue
+	}
+
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		values = append(values, timeValue.Value)
+	}
+	profile, _, _ := stomp(datas, values, window, config.exclusion(window))
+
+	logger.Debug("stomp finished", zap.Int("pointCnt", len(values)), zap.Int("window", window))
+	return profile, nil
+}
+
+// stomp return the profile, the stats of the subsequences and the dot products of the last subsequence
+func stomp(datas []model.TimeValue, values []float64, window, exclusion int) (*Profile, *windowStats, []float64) {
+	stats := newWindowStats(values, window)
+	profile := newProfile(datas, window, exclusion)
+
+	cnt := len(values) - window + 1
+	firstRow := slidingDotProduct(values, 0, window)
+	qt := append([]float64{}, firstRow...)
+	for i := 0; i < cnt; i++ {
+		if i > 0 {
+			// update from the previous row backward so qt[j-1] is still the previous row
+			for j := cnt - 1; j > 0; j-- {
+				qt[j] = qt[j-1] - values[i-1]*values[j-1] + values[i+window-1]*values[j+window-1]
+			}
+			qt[0] = firstRow[i]
+		}
+		for j := 0; j < cnt; j++ {
+			if intAbs(i-j) <= exclusion {
+				continue
+			}
+			distance := stats.distance(qt[j], i, j)
+			if distance < profile.Distances[i] {
+				profile.Distances[i], profile.Indexes[i] = distance, j
+			}
+		}
+	}
+	return profile, stats, qt
+}
+
+func newProfile(datas []model.TimeValue, window, exclusion int) *Profile {
+	cnt := len(datas) - window + 1
+	profile := &Profile{
+		Window:    window,
+		Times:     make([]time.Time, 0, cnt),
+		Distances: make([]float64, 0, cnt),
+		Indexes:   make([]int, 0, cnt),
+		exclusion: exclusion,
+	}
+	for i := 0; i < cnt; i++ {
+		profile.Times = append(profile.Times, datas[i].Time)
+		profile.Distances = append(profile.Distances, math.Inf(1))
+		profile.Indexes = append(profile.Indexes, -1)
+	}
+	return profile
+}
+
+func sortedDatas(timeSeries *model.TimeSeries) ([]model.TimeValue, error) {
+	if timeSeries.IsEmpty() {
+		return nil, common.ErrorInvalidValue
+	}
+	for _, timeValue := range timeSeries.Values {
+		if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) {
+			return nil, common.ErrorInvalidValue
+		}
+	}
+	return utils.SortedTimeValues(timeSeries.Values), nil
+}
+
+// slidingDotProduct is the dot products of values[start : start+window] and all the subsequences
+func slidingDotProduct(values []float64, start, window int) []float64 {
+	res := make([]float64, len(values)-window+1)
+	for j := range res {
+		for k := 0; k < window; k++ {
+			res[j] += values[start+k] * values[j+k]
+		}
+	}
+	return res
+}
+
+// windowStats is the mean and stddev of each subsequence
+type windowStats struct {
+	window int
+	means  []float64
+	stds   []float64
+}
+
+func newWindowStats(values []float64, window int) *windowStats {
+	stats := &windowStats{window: window}
+	for i := 0; i+window <= len(values); i++ {
+		stats.append(values[i : i+window])
+	}
+	return stats
+}
+
+func (s *windowStats) append(subsequence []float64) {
+	mean := 0.0
+	for _, v := range subsequence {
+		mean += v
+	}
+	mean /= float64(len(subsequence))
+	variance := 0.0
+	for _, v := range subsequence {
+		variance += (v - mean) * (v - mean)
+	}
+	s.means = append(s.means, mean)
+	s.stds = append(s.stds, math.Sqrt(variance/float64(len(subsequence))))
+}
+
+// distance is the z-normalized euclidean distance from the dot product, two constant subsequences
+// are the same and a constant one is sqrt(window) to the others
+func (s *windowStats) distance(qt float64, i, j int) float64 {
+	m := float64(s.window)
+	iConstant, jConstant := s.stds[i] < constantStd, s.stds[j] < constantStd
+	switch {
+	case iConstant && jConstant:
+		return 0
+	case iConstant || jConstant:
+		return math.Sqrt(m)
+	}
+	corr := (qt - m*s.means[i]*s.means[j]) / (m * s.stds[i] * s.stds[j])
+	corr = min(max(corr, -1), 1)
+	return math.Sqrt(2 * m * (1 - corr))
+}
@@ -0,0 +1,243 @@
+package matrixprofile
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTimeSeries(values []float64) *model.TimeSeries {
+	res := &model.TimeSeries{}
+	for i, value := range values {
+		res.Values = append(res.Values, model.TimeValue{Time: testStart.Add(time.Duration(i) * time.Minute),
+			Value: value})
+	}
+	return res
+}
+
+func randomWalk(n int, seed int64) []float64 {
+	random := rand.New(rand.NewSource(seed))
+	res := make([]float64, n)
+	for i := 1; i < n; i++ {
+		res[i] = res[i-1] + random.NormFloat64()
+	}
+	return res
+}
+
+// naiveDistance z-normalize the subsequences and compute the euclidean distance directly
+func naiveDistance(values []float64, i, j, window int) float64 {
+	normalize := func(start int) []float64 {
+		mean, variance := 0.0, 0.0
+		for _, v := range values[start : start+window] {
+			mean += v
+		}
+		mean /= float64(window)
+		for _, v := range values[start : start+window] {
+			variance += (v - mean) * (v - mean)
+		}
+		std := math.Sqrt(variance / float64(window))
+		res := make([]float64, window)
+		for k, v := range values[start : start+window] {
+			res[k] = (v - mean) / std
+		}
+		return res
+	}
+	a, b := normalize(i), normalize(j)
+	res := 0.0
+	for k := range a {
+		res += (a[k] - b[k]) * (a[k] - b[k])
+	}
+	return math.Sqrt(res)
+}
+
+func TestSTOMP(t *testing.T) {
+	values := randomWalk(120, 1)
+	window := 10
+	profile, err := STOMP(context.Background(), newTimeSeries(values), window)
+	if err != nil {
+		t.Fatalf("stomp failed: %v", err)
+	}
+
+	exclusion := 3 // ceil(10 * 0.25)
+	cnt := len(values) - window + 1
+	if len(profile.Distances) != cnt || profile.Window != window || !profile.Times[5].Equal(testStart.Add(5*time.Minute)) {
+		t.Fatalf("got %v distances, expected %v", len(profile.Distances), cnt)
+	}
+	for i := 0; i < cnt; i++ {
+		expected, expectedIndex := math.Inf(1), -1
+		for j := 0; j < cnt; j++ {
+			if intAbs(i-j) <= exclusion {
+				continue
+			}
+			if distance := naiveDistance(values, i, j, window); distance < expected {
+				expected, expectedIndex = distance, j
+			}
+		}
+		if math.Abs(profile.Distances[i]-expected) > 1e-6 || profile.Indexes[i] != expectedIndex {
+			t.Errorf("subsequence %v got %v at %v, expected %v at %v", i, profile.Distances[i], profile.Indexes[i],
+				expected, expectedIndex)
+		}
+	}
+}
+
+func TestWindowStatsDistance(t *testing.T) {
+	values := []float64{1, 1, 1, 1, 2, 3, 4, 5, 5, 5, 5}
+	stats := newWindowStats(values, 4)
+	qt := slidingDotProduct(values, 0, 4)
+	// two constant subsequences are the same, a constant one is sqrt(window) to the others
+	if d := stats.distance(qt[7], 0, 7); d != 0 {
+		t.Errorf("got distance %v of the constant subsequences", d)
+	}
+	if d := stats.distance(qt[3], 0, 3); d != 2 {
+		t.Errorf("got distance %v of the constant and the ramp, expected 2", d)
+	}
+	// the ramps 1 2 3 4 and 2 3 4 5 are the same after the z-normalization
+	qt = slidingDotProduct(values, 3, 4)
+	if d := stats.distance(qt[4], 3, 4); d > 1e-6 {
+		t.Errorf("got distance %v of the same shape", d)
+	}
+}
+
+func TestDiscordsAndMotifs(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	values := make([]float64, 400)
+	for i := range values {
+		values[i] = math.Sin(2*math.Pi*float64(i)/25) + 0.05*random.NormFloat64()
+	}
+	// the sine is flattened in [200, 212), the subsequences around it have no similar shape
+	for i := 200; i < 212; i++ {
+		values[i] = 0
+	}
+
+	window := 25
+	profile, err := STOMP(context.Background(), newTimeSeries(values), window)
+	if err != nil {
+		t.Fatalf("stomp failed: %v", err)
+	}
+	discords := profile.Discords(2)
+	if len(discords) != 2 || discords[0].Index < 200-window || discords[0].Index >= 212 {
+		t.Fatalf("got discords %+v, expected the first near 200", discords)
+	}
+	// the discords don't overlap
+	if intAbs(discords[0].Index-discords[1].Index) < window || discords[0].Distance < discords[1].Distance {
+		t.Errorf("got overlapped discords %+v %+v", discords[0], discords[1])
+	}
+	if !discords[0].Time.Equal(testStart.Add(time.Duration(discords[0].Index)*time.Minute)) ||
+		discords[0].NeighborIndex != profile.Indexes[discords[0].Index] {
+		t.Errorf("got discord %+v", discords[0])
+	}
+
+	// the sine repeat every period, the motifs are one period apart or more
+	motifs := profile.Motifs(3)
+	if len(motifs) != 3 {
+		t.Fatalf("got %v motifs, expected 3", len(motifs))
+	}
+	for _, motif := range motifs {
+		if motif.Distance > discords[0].Distance/2 || intAbs(motif.Index-motif.NeighborIndex)%window > 2 &&
+			window-intAbs(motif.Index-motif.NeighborIndex)%window > 2 {
+			t.Errorf("got motif %+v", motif)
+		}
+	}
+}
+
+func TestSTOMPInvalid(t *testing.T) {
+	ctx := context.Background()
+	values := randomWalk(20, 1)
+	if _, err := STOMP(ctx, newTimeSeries(values), 11); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the series shorter than 2 windows", err)
+	}
+	if _, err := STOMP(ctx, newTimeSeries(values), 2); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the window 2", err)
+	}
+	values[3] = math.NaN()
+	if _, err := STOMP(ctx, newTimeSeries(values), 5); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for nan", err)
+	}
+}
+
+func TestSTAMPI(t *testing.T) {
+	ctx := context.Background()
+	values := randomWalk(150, 2)
+	window := 12
+	timeSeries := newTimeSeries(values)
+
+	stampi := NewSTAMPI(window)
+	for i, timeValue := range timeSeries.Values {
+		pattern, ok := stampi.AppendPoint(ctx, timeValue)
+		// the first subsequence with a neighbour out of the exclusion zone end at window + exclusion
+		if ok != (i >= window+3) {
+			t.Fatalf("point %v got pattern %v", i, ok)
+		}
+		if ok && (pattern.Index != i-window+1 || !pattern.Time.Equal(timeSeries.Values[i-window+1].Time)) {
+			t.Fatalf("point %v got pattern %+v", i, pattern)
+		}
+	}
+
+	expected, err := STOMP(ctx, timeSeries, window)
+	if err != nil {
+		t.Fatalf("stomp failed: %v", err)
+	}
+	got := stampi.Profile()
+	if len(got.Distances) != len(expected.Distances) {
+		t.Fatalf("got %v distances, expected %v", len(got.Distances), len(expected.Distances))
+	}
+	for i := range got.Distances {
+		if math.Abs(got.Distances[i]-expected.Distances[i]) > 1e-10 || got.Indexes[i] != expected.Indexes[i] {
+			t.Errorf("subsequence %v got %v at %v, expected %v at %v", i, got.Distances[i], got.Indexes[i],
+				expected.Distances[i], expected.Indexes[i])
+		}
+	}
+
+	// the old point and the nan are skipped
+	if _, ok := stampi.AppendPoint(ctx, timeSeries.Values[10]); ok {
+		t.Errorf("the old point is appended")
+	}
+	if _, ok := stampi.AppendPoint(ctx, model.TimeValue{Time: testStart.Add(time.Hour * 10),
+		Value: math.NaN()}); ok {
+		t.Errorf("the nan is appended")
+	}
+	stampi.Reset()
+	if len(stampi.Profile().Distances) != 0 {
+		t.Errorf("the profile is not cleared")
+	}
+}
+
+func TestSTAMPIMaxPoints(t *testing.T) {
+	ctx := context.Background()
+	values := randomWalk(150, 3)
+	window := 10
+	timeSeries := newTimeSeries(values)
+
+	// the oldest half is dropped when more than 60 points
+	stampi := NewSTAMPI(window, WithMaxPoints(60))
+	for _, timeValue := range timeSeries.Values {
+		stampi.AppendPoint(ctx, timeValue)
+	}
+
+	// 61 points -> 30 at the point 60, 91 and 122, then 27 points appended
+	kept := 57
+	expected, err := STOMP(ctx, newTimeSeries(values[len(values)-kept:]), window)
+	if err != nil {
+		t.Fatalf("stomp failed: %v", err)
+	}
+	got := stampi.Profile()
+	if len(got.Distances) != len(expected.Distances) ||
+		!got.Times[0].Equal(timeSeries.Values[len(values)-kept].Time) {
+		t.Fatalf("got %v distances begin at %v, expected %v", len(got.Distances), got.Times[0],
+			len(expected.Distances))
+	}
+	for i := range got.Distances {
+		if math.Abs(got.Distances[i]-expected.Distances[i]) > 1e-10 || got.Indexes[i] != expected.Indexes[i] {
+			t.Errorf("subsequence %v got %v at %v, expected %v at %v", i, got.Distances[i], got.Indexes[i],
+				expected.Distances[i], expected.Indexes[i])
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package matrixprofile
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// STAMPI is the incremental matrix profile of Yeh et al. 2016, each new point add one subsequence and
+// update the profile in O(n) time. safe for concurrent use
+type STAMPI struct {
+	mu sync.Mutex
+
+	window int
+	config *profileConfig
+
+	datas   []model.TimeValue
+	values  []float64
+	stats   *windowStats
+	lastQT  []float64 // the dot products of the last subsequence and all the subsequences
+	profile *Profile
+}
+
+func NewSTAMPI(window int, opts ...Option) *STAMPI {
+	config := newProfileConfig(opts...)
+	if config.maxPoints > 0 {
+		config.maxPoints = max(config.maxPoints, 4*window)
+	}
+	res := &STAMPI{
+		window: max(window, 3),
+		config: config,
+	}
+	res.reset()
+	return res
+}
+
+// AppendPoint return the new subsequence ending at the point and its nearest neighbour, the distance is
+// the discord score of the subsequence, the indexes are relative to the kept points.
+// return false if the point is skipped or no neighbour yet
+func (s *STAMPI) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*Pattern, bool) {
+	logger := utils.GetLogger(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) {
+		logger.Debug("skip invalid point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+	if len(s.datas) > 0 && !s.datas[len(s.datas)-1].Before(timeValue) {
+		logger.Debug("skip the point not after the last point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+
+	s.datas = append(s.datas, timeValue)
+	s.values = append(s.values, timeValue.Value)
+	if len(s.values) < s.window {
+		return nil, false
+	}
+
+	if s.config.maxPoints > 0 && len(s.values) > s.config.maxPoints {
+		s.rebuild(len(s.values) - s.config.maxPoints/2)
+		logger.Debug("drop the oldest points of stampi", zap.Int("pointCnt", len(s.values)))
+	} else {
+		s.appendSubsequence()
+	}
+
+	last := len(s.profile.Distances) - 1
+	if s.profile.Indexes[last] < 0 {
+		return nil, false
+	}
+	return s.profile.newPattern(last), true
+}
+
+// appendSubsequence add the subsequence ending at the last point
+func (s *STAMPI) appendSubsequence() {
+	k, window := len(s.values)-s.window, s.window
+	s.stats.append(s.values[k:])
+	s.profile.Times = append(s.profile.Times, s.datas[k].Time)
+	s.profile.Distances = append(s.profile.Distances, math.Inf(1))
+	s.profile.Indexes = append(s.profile.Indexes, -1)
+
+	qt := make([]float64, k+1)
+	for j := 1; j <= k; j++ {
+		qt[j] = s.lastQT[j-1] - s.values[k-1]*s.values[j-1] + s.values[k+window-1]*s.values[j+window-1]
+	}
+	for i := 0; i < window; i++ {
+		qt[0] += s.values[k+i] * s.values[i]
+	}
+	s.lastQT = qt
+
+	for j := 0; j < k-s.profile.exclusion; j++ {
+		distance := s.stats.distance(qt[j], k, j)
+		if distance < s.profile.Distances[k] {
+			s.profile.Distances[k], s.profile.Indexes[k] = distance, j
+		}
+		if distance < s.profile.Distances[j] {
+			s.profile.Distances[j], s.profile.Indexes[j] = distance, k
+		}
+	}
+}
+
+// rebuild drop the first start points and compute the profile of the others with stomp
+func (s *STAMPI) rebuild(start int) {
+	s.datas = append([]model.TimeValue{}, s.datas[start:]...)
+	s.values = append([]float64{}, s.values[start:]...)
+	if len(s.values) < s.window {
+		s.stats, s.lastQT = newWindowStats(s.values, s.window), nil
+		s.profile = &Profile{Window: s.window, exclusion: s.config.exclusion(s.window)}
+		return
+	}
+	s.profile, s.stats, s.lastQT = stomp(s.datas, s.values, s.window, s.config.exclusion(s.window))
+}
+
+// Profile return the copy of the current profile, the indexes are relative to the kept points
+func (s *STAMPI) Profile() *Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &Profile{
+		Window:    s.profile.Window,
+		Times:     append([]time.Time{}, s.profile.Times...),
+		Distances: append([]float64{}, s.profile.Distances...),
+		Indexes:   append([]int{}, s.profile.Indexes...),
+		exclusion: s.profile.exclusion,
+	}
+}
+
+// Reset drop all the points
+func (s *STAMPI) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+}
+
+func (s *STAMPI) reset() {
+	s.datas, s.values = nil, nil
+	s.rebuild(0)
+}
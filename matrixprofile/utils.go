@@ -0,0 +1,8 @@
+package matrixprofile
+
+func intAbs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
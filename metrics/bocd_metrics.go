@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	seriesLabel = "series"
+	policyLabel = "policy"
+	reasonLabel = "reason"
+)
+
+// BocdMetrics is the collectors of the bocd detector, all the methods are safe to call on nil,
+// so the detector don't need check whether the metrics is enabled
+type BocdMetrics struct {
+	pointsProcessed        *prometheus.CounterVec
+	changePointsFound      *prometheus.CounterVec
+	changePointsTriggered  *prometheus.CounterVec
+	changePointsSuppressed *prometheus.CounterVec
+	rebalances             *prometheus.CounterVec
+	maxRunLength           *prometheus.GaugeVec
+	appendLatency          *prometheus.HistogramVec
+}
+
+// NewBocdMetrics create the collectors and register them on the registerer
+func NewBocdMetrics(registerer prometheus.Registerer) (*BocdMetrics, error) {
+	m := &BocdMetrics{
+		pointsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bocd_points_processed_total",
+			Help: "Number of points appended to the bocd checker.",
+		}, []string{seriesLabel}),
+		changePointsFound: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bocd_change_points_found_total",
+			Help: "Number of change points found by the bocd checker.",
+		}, []string{seriesLabel}),
+		changePointsTriggered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bocd_change_points_triggered_total",
+			Help: "Number of change points popped to trigger.",
+		}, []string{seriesLabel}),
+		changePointsSuppressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bocd_change_points_suppressed_total",
+			Help: "Number of change points dropped by the suppression policies.",
+		}, []string{seriesLabel, policyLabel}),
+		rebalances: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bocd_rebalances_total",
+			Help: "Number of times the bocd checker is rebuilt to release the cached data.",
+		}, []string{seriesLabel}),
+		maxRunLength: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bocd_max_run_length",
+			Help: "Run length with the max posterior probability after the last point.",
+		}, []string{seriesLabel}),
+		appendLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "bocd_append_duration_seconds",
+			Help:    "Latency of appending one point to the bocd handler.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{seriesLabel}),
+	}
+
+	if err := register(registerer, m.pointsProcessed, m.changePointsFound, m.changePointsTriggered,
+		m.changePointsSuppressed, m.rebalances, m.maxRunLength, m.appendLatency); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *BocdMetrics) ObserveAppend(series string, latency time.Duration, maxRunLength int, found bool) {
+	if m == nil {
+		return
+	}
+	m.pointsProcessed.WithLabelValues(series).Inc()
+	m.appendLatency.WithLabelValues(series).Observe(latency.Seconds())
+	m.maxRunLength.WithLabelValues(series).Set(float64(maxRunLength))
+	if found {
+		m.changePointsFound.WithLabelValues(series).Inc()
+	}
+}
+
+func (m *BocdMetrics) AddTriggered(series string, cnt int) {
+	if m == nil {
+		return
+	}
+	m.changePointsTriggered.WithLabelValues(series).Add(float64(cnt))
+}
+
+func (m *BocdMetrics) IncSuppressed(series, policy string) {
+	if m == nil {
+		return
+	}
+	m.changePointsSuppressed.WithLabelValues(series, policy).Inc()
+}
+
+func (m *BocdMetrics) IncRebalance(series string) {
+	if m == nil {
+		return
+	}
+	m.rebalances.WithLabelValues(series).Inc()
+}
+
+// DeleteSeries remove the series labels, call it when the series is evicted
+func (m *BocdMetrics) DeleteSeries(series string) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{seriesLabel: series}
+	for _, vec := range []*prometheus.MetricVec{m.pointsProcessed.MetricVec, m.changePointsFound.MetricVec,
+		m.changePointsTriggered.MetricVec, m.changePointsSuppressed.MetricVec, m.rebalances.MetricVec,
+		m.maxRunLength.MetricVec, m.appendLatency.MetricVec} {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+func register(registerer prometheus.Registerer, collectors ...prometheus.Collector) error {
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBocdMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewBocdMetrics(registry)
+	if err != nil {
+		t.Fatalf("new metrics failed: %v", err)
+	}
+
+	m.ObserveAppend("s1", time.Millisecond, 10, false)
+	m.ObserveAppend("s1", time.Millisecond, 3, true)
+	m.ObserveAppend("s2", time.Millisecond, 7, false)
+	m.AddTriggered("s1", 2)
+	m.IncSuppressed("s1", "rate_limit")
+	m.IncSuppressed("s1", "min_magnitude")
+	m.IncRebalance("s2")
+
+	expects := []struct {
+		collector prometheus.Collector
+		labels    []string
+		value     float64
+	}{
+		{m.pointsProcessed, []string{"s1"}, 2},
+		{m.pointsProcessed, []string{"s2"}, 1},
+		{m.changePointsFound, []string{"s1"}, 1},
+		{m.maxRunLength, []string{"s1"}, 3}, // the gauge keep the last value
+		{m.maxRunLength, []string{"s2"}, 7},
+		{m.changePointsTriggered, []string{"s1"}, 2},
+		{m.changePointsSuppressed, []string{"s1", "rate_limit"}, 1},
+		{m.rebalances, []string{"s2"}, 1},
+	}
+	for _, expect := range expects {
+		var got float64
+		switch vec := expect.collector.(type) {
+		case *prometheus.CounterVec:
+			got = testutil.ToFloat64(vec.WithLabelValues(expect.labels...))
+		case *prometheus.GaugeVec:
+			got = testutil.ToFloat64(vec.WithLabelValues(expect.labels...))
+		}
+		if got != expect.value {
+			t.Errorf("%v got %v, expected %v", expect.labels, got, expect.value)
+		}
+	}
+	if cnt := testutil.CollectAndCount(m.appendLatency); cnt != 2 {
+		t.Errorf("got %v latency series, expected 2", cnt)
+	}
+	if cnt, err := testutil.GatherAndCount(registry, "bocd_change_points_suppressed_total"); err != nil || cnt != 2 {
+		t.Errorf("got %v suppressed series, err %v, expected 2", cnt, err)
+	}
+
+	// the collectors are registered, create again on the same registry fails
+	if _, err := NewBocdMetrics(registry); err == nil {
+		t.Errorf("register twice should fail")
+	}
+}
+
+func TestBocdMetricsDeleteSeries(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewBocdMetrics(registry)
+	if err != nil {
+		t.Fatalf("new metrics failed: %v", err)
+	}
+	for _, series := range []string{"s1", "s2"} {
+		m.ObserveAppend(series, time.Millisecond, 1, true)
+		m.AddTriggered(series, 1)
+		m.IncSuppressed(series, "rate_limit")
+		m.IncRebalance(series)
+	}
+
+	m.DeleteSeries("s1")
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+	if len(families) != 7 {
+		t.Errorf("got %v metric families, expected 7", len(families))
+	}
+	for _, family := range families {
+		if len(family.GetMetric()) != 1 {
+			t.Errorf("%v got %v series, expected 1", family.GetName(), len(family.GetMetric()))
+			continue
+		}
+		for _, label := range family.GetMetric()[0].GetLabel() {
+			if label.GetName() == seriesLabel && label.GetValue() != "s2" {
+				t.Errorf("%v keep the series %v", family.GetName(), label.GetValue())
+			}
+		}
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	// the detector call the methods without checking whether the metrics is enabled
+	var bocdMetrics *BocdMetrics
+	bocdMetrics.ObserveAppend("s", time.Millisecond, 1, true)
+	bocdMetrics.AddTriggered("s", 1)
+	bocdMetrics.IncSuppressed("s", "rate_limit")
+	bocdMetrics.IncRebalance("s")
+	bocdMetrics.DeleteSeries("s")
+
+	var kdeMetrics *KdeMetrics
+	kdeMetrics.ObserveFit("s", time.Millisecond, 1)
+	kdeMetrics.IncSkipped("s", KdeSkipReasonTooFewPoints)
+	kdeMetrics.DeleteSeries("s")
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the reasons of skipping the kde calculation
+const (
+	KdeSkipReasonTooFewPoints = "too_few_points"
+	KdeSkipReasonLowSpeed     = "low_speed"
+	KdeSkipReasonFitFailed    = "fit_failed"
+)
+
+// KdeMetrics is the collectors of the kde calculation, all the methods are safe to call on nil
+type KdeMetrics struct {
+	fitLatency  *prometheus.HistogramVec
+	sampleCount *prometheus.GaugeVec
+	skipped     *prometheus.CounterVec
+}
+
+// NewKdeMetrics create the collectors and register them on the registerer
+func NewKdeMetrics(registerer prometheus.Registerer) (*KdeMetrics, error) {
+	m := &KdeMetrics{
+		fitLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kde_fit_duration_seconds",
+			Help:    "Latency of fitting the kde and calculating the quantiles.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{seriesLabel}),
+		sampleCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kde_sample_count",
+			Help: "Number of samples used to fit the kde after clipping.",
+		}, []string{seriesLabel}),
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kde_calculations_skipped_total",
+			Help: "Number of kde calculations skipped.",
+		}, []string{seriesLabel, reasonLabel}),
+	}
+
+	if err := register(registerer, m.fitLatency, m.sampleCount, m.skipped); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *KdeMetrics) ObserveFit(series string, latency time.Duration, sampleCnt int) {
+	if m == nil {
+		return
+	}
+	m.fitLatency.WithLabelValues(series).Observe(latency.Seconds())
+	m.sampleCount.WithLabelValues(series).Set(float64(sampleCnt))
+}
+
+func (m *KdeMetrics) IncSkipped(series, reason string) {
+	if m == nil {
+		return
+	}
+	m.skipped.WithLabelValues(series, reason).Inc()
+}
+
+// DeleteSeries remove the series labels
+func (m *KdeMetrics) DeleteSeries(series string) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{seriesLabel: series}
+	for _, vec := range []*prometheus.MetricVec{m.fitLatency.MetricVec, m.sampleCount.MetricVec,
+		m.skipped.MetricVec} {
+		vec.DeletePartialMatch(labels)
+	}
+}
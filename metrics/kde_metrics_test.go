@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestKdeMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewKdeMetrics(registry)
+	if err != nil {
+		t.Fatalf("new metrics failed: %v", err)
+	}
+
+	m.ObserveFit("s1", time.Millisecond, 100)
+	m.ObserveFit("s1", time.Millisecond, 120)
+	m.IncSkipped("s1", KdeSkipReasonLowSpeed)
+	m.IncSkipped("s1", KdeSkipReasonLowSpeed)
+	m.IncSkipped("s2", KdeSkipReasonTooFewPoints)
+
+	if got := testutil.ToFloat64(m.sampleCount.WithLabelValues("s1")); got != 120 {
+		t.Errorf("got sample count %v, expected 120", got)
+	}
+	if got := testutil.ToFloat64(m.skipped.WithLabelValues("s1", KdeSkipReasonLowSpeed)); got != 2 {
+		t.Errorf("got skipped %v, expected 2", got)
+	}
+	if cnt := testutil.CollectAndCount(m.skipped); cnt != 2 {
+		t.Errorf("got %v skipped series, expected 2", cnt)
+	}
+
+	m.DeleteSeries("s1")
+	if cnt := testutil.CollectAndCount(m.fitLatency) + testutil.CollectAndCount(m.sampleCount); cnt != 0 {
+		t.Errorf("got %v series of s1 after delete", cnt)
+	}
+	if got := testutil.ToFloat64(m.skipped.WithLabelValues("s2", KdeSkipReasonTooFewPoints)); got != 1 {
+		t.Errorf("got skipped %v of s2, expected 1", got)
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+type DetectionKind int
+
+const (
+	// AnomalyDetection is a point out of the expected range, like out of the kde quantiles
+	AnomalyDetection DetectionKind = 1
+	// ChangePointDetection is a level shift begin at the point
+	ChangePointDetection DetectionKind = 2
+)
+
+func (k DetectionKind) String() string {
+	switch k {
+	case AnomalyDetection:
+		return "anomaly"
+	case ChangePointDetection:
+		return "change_point"
+	}
+	return "unknown"
+}
+
+// Detection is the unified result of all the detectors
+type Detection struct {
+	Detector  string        `json:"detector"`
+	Kind      DetectionKind `json:"kind"`
+	TimeValue TimeValue     `json:"time_value"`
+	// Direction is IncreaseChangePoint if the value is higher than expected
+	Direction ChangePointType `json:"direction"`
+	// Score is detector specific, the larger the more anomalous
+	Score float64 `json:"score"`
+
+	// Lower and Upper are the expected range of the value, only set by the detectors have a range
+	Lower float64 `json:"lower,omitempty"`
+	Upper float64 `json:"upper,omitempty"`
+
+	// ChangePoint is the detail of the change point detection
+	ChangePoint *ChangePoint `json:"change_point,omitempty"`
+}
+
+// NewChangePointDetection the score is the posterior probability if the detector provide it,
+// otherwise the absolute magnitude
+func NewChangePointDetection(detector string, changePoint *ChangePoint) *Detection {
+	score := changePoint.Probability
+	if score == 0 {
+		score = changePoint.Magnitude
+		if score < 0 {
+			score = -score
+		}
+	}
+	return &Detection{
+		Detector:    detector,
+		Kind:        ChangePointDetection,
+		TimeValue:   changePoint.TimeValue,
+		Direction:   changePoint.ChangePointType,
+		Score:       score,
+		ChangePoint: changePoint,
+	}
+}
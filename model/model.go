@@ -1,7 +1,10 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,13 +16,67 @@ const (
 )
 
 type ChangePoint struct {
-	ChangePointType ChangePointType
-	TimeValue       TimeValue
+	ChangePointType ChangePointType `json:"type"`
+	TimeValue       TimeValue       `json:"time_value"`
+
+	// Probability is the posterior probability of the run length when the change point detected
+	Probability    float64       `json:"probability,omitempty"`
+	RunLength      int           `json:"run_length,omitempty"`
+	DetectTime     time.Time     `json:"detect_time,omitempty"`
+	DetectionDelay time.Duration `json:"detection_delay,omitempty"`
+
+	// the statistics of the segment before and after the change point
+	PreMean      float64 `json:"pre_mean,omitempty"`
+	PreVariance  float64 `json:"pre_var,omitempty"`
+	PostMean     float64 `json:"post_mean,omitempty"`
+	PostVariance float64 `json:"post_var,omitempty"`
+
+	Magnitude         float64 `json:"magnitude,omitempty"`          // PostMean - PreMean
+	RelativeMagnitude float64 `json:"relative_magnitude,omitempty"` // Magnitude / |PreMean|
+
+	// Rule is the detection rule fired, only set by the rule based detectors like control charts
+	Rule string `json:"rule,omitempty"`
+}
+
+type changePointAlias ChangePoint
+
+// MarshalJSON omit the zero DetectTime, the omitempty tag doesn't work for the struct
+func (c ChangePoint) MarshalJSON() ([]byte, error) {
+	res := struct {
+		changePointAlias
+		DetectTime *time.Time `json:"detect_time,omitempty"`
+	}{changePointAlias: changePointAlias(c)}
+	if !c.DetectTime.IsZero() {
+		res.DetectTime = &c.DetectTime
+	}
+	return json.Marshal(res)
 }
 
+// UnmarshalJSON also accept the keys before the json tags were added, ChangePointType and TimeValue
+func (c *ChangePoint) UnmarshalJSON(data []byte) error {
+	res := struct {
+		changePointAlias
+		OldChangePointType *ChangePointType `json:"ChangePointType"`
+		OldTimeValue       *TimeValue       `json:"TimeValue"`
+	}{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+
+	*c = ChangePoint(res.changePointAlias)
+	if res.OldChangePointType != nil {
+		c.ChangePointType = *res.OldChangePointType
+	}
+	if res.OldTimeValue != nil {
+		c.TimeValue = *res.OldTimeValue
+	}
+	return nil
+}
+
+// TimeValue the json keys are case insensitive when decoding, so the old keys Time and Value still work
 type TimeValue struct {
-	Time  time.Time
-	Value float64
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
 }
 
 func (v *TimeValue) Less(timeValue TimeValue) bool {
@@ -41,6 +98,24 @@ func (s *TimeSeries) DebugString() string {
 	return res
 }
 
+// Key is the sorted labels, like {instance="localhost:9091",job="api"}
+func (s *TimeSeries) Key() string {
+	if s == nil {
+		return "{}"
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for key := range s.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, s.Labels[key]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
 func (s *TimeSeries) IsEmpty() bool {
 	if s == nil {
 		return true
@@ -61,13 +136,18 @@ type DailyStatisticsData struct {
 }
 
 func (d *DailyStatisticsData) Valid(expiredDuration time.Duration) bool {
+	return d.ValidAt(time.Now(), expiredDuration)
+}
+
+// ValidAt is Valid with the given current time, like the time of an injected clock
+func (d *DailyStatisticsData) ValidAt(now time.Time, expiredDuration time.Duration) bool {
 	if d == nil || d.UpdateTimestamp == 0 {
 		return false
 	}
 	if d.Mean == 0 || d.RecentNormalMean == 0 || d.NormalMean == 0 {
 		return false
 	}
-	if time.Since(time.Unix(d.UpdateTimestamp, 0)) > expiredDuration {
+	if now.Sub(time.Unix(d.UpdateTimestamp, 0)) > expiredDuration {
 		return false
 	}
 
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDailyStatisticsDataValid(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	data := &DailyStatisticsData{Mean: 1, NormalMean: 1, RecentNormalMean: 1,
+		UpdateTimestamp: now.Add(-2 * time.Hour).Unix()}
+
+	if !data.ValidAt(now, 3*time.Hour) {
+		t.Errorf("the data updated 2 hours ago should be valid in 3 hours")
+	}
+	if data.ValidAt(now, time.Hour) {
+		t.Errorf("the data updated 2 hours ago should be expired in 1 hour")
+	}
+	// the data of 2024 is expired now
+	if data.Valid(time.Hour) {
+		t.Errorf("Valid should use the current time")
+	}
+
+	var nilData *DailyStatisticsData
+	if nilData.ValidAt(now, time.Hour) || (&DailyStatisticsData{}).ValidAt(now, time.Hour) {
+		t.Errorf("the nil or never updated data should be invalid")
+	}
+	zeroMean := *data
+	zeroMean.RecentNormalMean = 0
+	if zeroMean.ValidAt(now, 3*time.Hour) {
+		t.Errorf("the data with zero mean should be invalid")
+	}
+}
+
+func TestChangePointJSON(t *testing.T) {
+	pointTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	changePoint := &ChangePoint{
+		ChangePointType: IncreaseChangePoint,
+		TimeValue:       TimeValue{Time: pointTime, Value: 3},
+		Probability:     0.9,
+	}
+
+	bytes, err := json.Marshal(changePoint)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	want := `{"type":1,"time_value":{"time":"2024-01-01T12:00:00Z","value":3},"probability":0.9}`
+	if string(bytes) != want {
+		t.Errorf("want %s, got %s", want, bytes)
+	}
+
+	changePoint.DetectTime = pointTime.Add(time.Minute)
+	bytes, _ = json.Marshal(changePoint)
+	decoded := &ChangePoint{}
+	if err := json.Unmarshal(bytes, decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if *decoded != *changePoint {
+		t.Errorf("round trip want %+v, got %+v", changePoint, decoded)
+	}
+}
+
+func TestChangePointUnmarshalOldJSON(t *testing.T) {
+	// the json before the tags were added
+	old := `{"ChangePointType":2,"TimeValue":{"Time":"2024-01-01T12:00:00Z","Value":3}}`
+
+	changePoint := &ChangePoint{}
+	if err := json.Unmarshal([]byte(old), changePoint); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	want := ChangePoint{
+		ChangePointType: DecreaseChangePoint,
+		TimeValue:       TimeValue{Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Value: 3},
+	}
+	if *changePoint != want {
+		t.Errorf("want %+v, got %+v", want, changePoint)
+	}
+}
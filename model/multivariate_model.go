@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// MultiTimeValue is a vector value at one timestamp, like qps, latency and error rate of one service
+type MultiTimeValue struct {
+	Time   time.Time `json:"time"`
+	Values []float64 `json:"values"`
+}
+
+type MultiChangePoint struct {
+	TimeValue   MultiTimeValue `json:"time_value"`
+	Probability float64        `json:"probability"`
+	RunLength   int            `json:"run_length"`
+	PreMeans    []float64      `json:"pre_means"`
+	PostMeans   []float64      `json:"post_means"`
+	// ChangePointTypes is the change direction of each dimension
+	ChangePointTypes []ChangePointType `json:"change_point_types"`
+	// Contributions is the share of each dimension in the change, the sum is 1
+	Contributions []float64 `json:"contributions"`
+	// TopDimensions is the dimension indexes sort by contribution desc
+	TopDimensions []int `json:"top_dimensions"`
+}
@@ -0,0 +1,79 @@
+package offline
+
+import (
+	"context"
+	"math"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+type split struct {
+	start, end, loc int
+	gain            float64 // the cost reduced by the split
+}
+
+// bestSplit find the split of [start, end) reduce the most cost
+func (p *detectProblem) bestSplit(start, end int) (split, bool) {
+	minSize := p.config.minSegmentLength
+	res := split{start: start, end: end, gain: math.Inf(-1)}
+	if end-start < 2*minSize {
+		return res, false
+	}
+	total := p.cost.Cost(start, end)
+	for loc := start + minSize; loc <= end-minSize; loc++ {
+		gain := total - p.cost.Cost(start, loc) - p.cost.Cost(loc, end)
+		if gain > res.gain {
+			res.loc, res.gain = loc, gain
+		}
+	}
+	return res, true
+}
+
+// DetectBinarySegmentation split the segment with the largest cost reduction greedily,
+// stop when no split reduce more than the penalty or reach the max change points
+func DetectBinarySegmentation(ctx context.Context, timeSeries *model.TimeSeries, opts ...Option) (*Result, error) {
+	logger := utils.GetLogger(ctx)
+
+	problem, err := newDetectProblem(ctx, timeSeries, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	changePointLocs := []int{}
+	splits := []split{}
+	if s, ok := problem.bestSplit(0, len(problem.values)); ok {
+		splits = append(splits, s)
+	}
+
+	for len(splits) > 0 {
+		if problem.config.maxChangePoints > 0 && len(changePointLocs) >= problem.config.maxChangePoints {
+			break
+		}
+
+		bestIndex := 0
+		for i := range splits {
+			if splits[i].gain > splits[bestIndex].gain {
+				bestIndex = i
+			}
+		}
+		best := splits[bestIndex]
+		if best.gain <= problem.penalty {
+			break
+		}
+
+		changePointLocs = append(changePointLocs, best.loc)
+		splits = append(splits[:bestIndex], splits[bestIndex+1:]...)
+		for _, bound := range [][2]int{{best.start, best.loc}, {best.loc, best.end}} {
+			if s, ok := problem.bestSplit(bound[0], bound[1]); ok {
+				splits = append(splits, s)
+			}
+		}
+	}
+
+	res := problem.newResult(changePointLocs)
+	logger.Info("binary segmentation detect change points success", zap.Int("pointCnt", len(problem.values)),
+		zap.Int("changePointCnt", len(res.ChangePoints)), zap.Float64("penalty", problem.penalty))
+	return res, nil
+}
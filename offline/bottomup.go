@@ -0,0 +1,70 @@
+package offline
+
+import (
+	"context"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// DetectBottomUp begin with the segments of the min segment length, then merge the adjacent
+// segments with the smallest cost increase, stop when any merge increase more than the penalty
+// and the change points not more than the max change points
+func DetectBottomUp(ctx context.Context, timeSeries *model.TimeSeries, opts ...Option) (*Result, error) {
+	logger := utils.GetLogger(ctx)
+
+	problem, err := newDetectProblem(ctx, timeSeries, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	n, minSize := len(problem.values), problem.config.minSegmentLength
+
+	// bounds are the begin of each segment and n, the last segment contain the remain points
+	bounds := []int{}
+	for start := 0; start+minSize <= n; start += minSize {
+		bounds = append(bounds, start)
+	}
+	bounds = append(bounds, n)
+
+	// mergeCost is the cost increase of merge the segment i and i+1
+	mergeCost := func(i int) float64 {
+		start, mid, end := bounds[i], bounds[i+1], bounds[i+2]
+		return problem.cost.Cost(start, end) - problem.cost.Cost(start, mid) - problem.cost.Cost(mid, end)
+	}
+	mergeCosts := make([]float64, 0, len(bounds))
+	for i := 0; i+2 < len(bounds); i++ {
+		mergeCosts = append(mergeCosts, mergeCost(i))
+	}
+
+	for len(mergeCosts) > 0 {
+		bestIndex := 0
+		for i := range mergeCosts {
+			if mergeCosts[i] < mergeCosts[bestIndex] {
+				bestIndex = i
+			}
+		}
+
+		changePointCnt := len(bounds) - 2
+		overLimit := problem.config.maxChangePoints > 0 && changePointCnt > problem.config.maxChangePoints
+		if mergeCosts[bestIndex] >= problem.penalty && !overLimit {
+			break
+		}
+
+		// remove the bound between the two segments, then update the merge costs of the neighbours
+		bounds = append(bounds[:bestIndex+1], bounds[bestIndex+2:]...)
+		mergeCosts = append(mergeCosts[:bestIndex], mergeCosts[bestIndex+1:]...)
+		if bestIndex > 0 {
+			mergeCosts[bestIndex-1] = mergeCost(bestIndex - 1)
+		}
+		if bestIndex < len(mergeCosts) {
+			mergeCosts[bestIndex] = mergeCost(bestIndex)
+		}
+	}
+
+	res := problem.newResult(append([]int{}, bounds[1:len(bounds)-1]...))
+	logger.Info("bottom up detect change points success", zap.Int("pointCnt", n),
+		zap.Int("changePointCnt", len(res.ChangePoints)), zap.Float64("penalty", problem.penalty))
+	return res, nil
+}
@@ -0,0 +1,161 @@
+package offline
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+type CostFunction int
+
+const (
+	// CostMean detect the mean shift of normal data, the variance is known and estimated from the diffs
+	CostMean CostFunction = iota
+	// CostVariance detect the variance change of normal data, the mean is known as the mean of all data
+	CostVariance
+	// CostMeanVariance detect the change of both mean and variance of normal data
+	CostMeanVariance
+	// CostPoisson detect the rate change of count data, the values must be non negative
+	CostPoisson
+)
+
+func (c CostFunction) String() string {
+	switch c {
+	case CostMean:
+		return "mean"
+	case CostVariance:
+		return "variance"
+	case CostMeanVariance:
+		return "mean_variance"
+	case CostPoisson:
+		return "poisson"
+	}
+	return "unknown"
+}
+
+// the variance of a segment is at least minVariance, so the log won't be -inf for the constant segment
+const minVariance = 1e-10
+
+// segmentCost is -2 * max log likelihood of the segment [start, end),
+// the prefix sums are used so the cost of any segment is O(1)
+type segmentCost interface {
+	Cost(start, end int) float64
+	// ParamCount is the number of the changed params of each segment, used by the penalty
+	ParamCount() int
+}
+
+type prefixSums struct {
+	sum   []float64
+	sumSq []float64
+}
+
+func newPrefixSums(values []float64, mean float64) *prefixSums {
+	res := &prefixSums{
+		sum:   make([]float64, len(values)+1),
+		sumSq: make([]float64, len(values)+1),
+	}
+	// shift by the mean to reduce the float error
+	for i, v := range values {
+		res.sum[i+1] = res.sum[i] + (v - mean)
+		res.sumSq[i+1] = res.sumSq[i] + (v-mean)*(v-mean)
+	}
+	return res
+}
+
+func (p *prefixSums) sums(start, end int) (float64, float64, float64) {
+	return float64(end - start), p.sum[end] - p.sum[start], p.sumSq[end] - p.sumSq[start]
+}
+
+func newSegmentCost(costFunction CostFunction, values []float64) segmentCost {
+	mean := stat.Mean(values, nil)
+	switch costFunction {
+	case CostVariance:
+		return &varianceCost{prefixSums: newPrefixSums(values, mean)}
+	case CostMeanVariance:
+		return &meanVarianceCost{prefixSums: newPrefixSums(values, mean)}
+	case CostPoisson:
+		return &poissonCost{prefixSums: newPrefixSums(values, 0)}
+	}
+
+	varx := estimateVariance(values)
+	return &meanCost{prefixSums: newPrefixSums(values, mean), varx: varx}
+}
+
+// estimateVariance use the diffs so the mean shifts won't enlarge the variance
+func estimateVariance(values []float64) float64 {
+	if len(values) < 2 {
+		return 1
+	}
+	diffs := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		diffs = append(diffs, values[i]-values[i-1])
+	}
+	res := stat.Variance(diffs, nil) / 2
+	if res <= minVariance || math.IsNaN(res) {
+		res = stat.Variance(values, nil)
+	}
+	if res <= minVariance || math.IsNaN(res) {
+		res = 1
+	}
+	return res
+}
+
+type meanCost struct {
+	*prefixSums
+	varx float64
+}
+
+func (c *meanCost) Cost(start, end int) float64 {
+	n, sum, sumSq := c.sums(start, end)
+	return (sumSq - sum*sum/n) / c.varx
+}
+
+func (c *meanCost) ParamCount() int {
+	return 1
+}
+
+type varianceCost struct {
+	*prefixSums
+}
+
+func (c *varianceCost) Cost(start, end int) float64 {
+	n, _, sumSq := c.sums(start, end)
+	variance := math.Max(sumSq/n, minVariance)
+	return n * (math.Log(2*math.Pi*variance) + 1)
+}
+
+func (c *varianceCost) ParamCount() int {
+	return 1
+}
+
+type meanVarianceCost struct {
+	*prefixSums
+}
+
+func (c *meanVarianceCost) Cost(start, end int) float64 {
+	n, sum, sumSq := c.sums(start, end)
+	variance := math.Max(sumSq/n-(sum/n)*(sum/n), minVariance)
+	return n * (math.Log(2*math.Pi*variance) + 1)
+}
+
+func (c *meanVarianceCost) ParamCount() int {
+	return 2
+}
+
+// poissonCost drop the log(x!) term, it's the same for any segmentation
+type poissonCost struct {
+	*prefixSums
+}
+
+func (c *poissonCost) Cost(start, end int) float64 {
+	n, sum, _ := c.sums(start, end)
+	if sum <= 0 {
+		return 0
+	}
+	rate := sum / n
+	return 2 * (n*rate - sum*math.Log(rate))
+}
+
+func (c *poissonCost) ParamCount() int {
+	return 1
+}
@@ -0,0 +1,201 @@
+package offline
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat"
+)
+
+type detectConfig struct {
+	costFunction     CostFunction
+	penaltyType      PenaltyType
+	manualPenalty    float64
+	minSegmentLength int
+	maxChangePoints  int // 0 means no limit, only used by binary segmentation and bottom up
+}
+
+type Option func(*detectConfig)
+
+// WithCostFunction default is CostMean
+func WithCostFunction(costFunction CostFunction) Option {
+	return func(c *detectConfig) {
+		c.costFunction = costFunction
+	}
+}
+
+// WithPenalty default is PenaltyMBIC
+func WithPenalty(penaltyType PenaltyType) Option {
+	return func(c *detectConfig) {
+		c.penaltyType = penaltyType
+	}
+}
+
+// WithManualPenalty set the cost of each change point, the penalty type is set to PenaltyManual
+func WithManualPenalty(penalty float64) Option {
+	return func(c *detectConfig) {
+		if penalty >= 0 {
+			c.penaltyType = PenaltyManual
+			c.manualPenalty = penalty
+		}
+	}
+}
+
+// WithMinSegmentLength set the min points of each segment, default is 2
+func WithMinSegmentLength(minSegmentLength int) Option {
+	return func(c *detectConfig) {
+		if minSegmentLength > 0 {
+			c.minSegmentLength = minSegmentLength
+		}
+	}
+}
+
+// WithMaxChangePoints limit the count of the change points, not used by pelt
+func WithMaxChangePoints(maxChangePoints int) Option {
+	return func(c *detectConfig) {
+		if maxChangePoints >= 0 {
+			c.maxChangePoints = maxChangePoints
+		}
+	}
+}
+
+func newDetectConfig(opts ...Option) *detectConfig {
+	config := &detectConfig{
+		costFunction:     CostMean,
+		penaltyType:      PenaltyMBIC,
+		minSegmentLength: 2,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// Segment is one segment of the result, StartIndex and EndIndex are the indexes of
+// the first and last point in the sorted series
+type Segment struct {
+	StartIndex int       `json:"start_index"`
+	EndIndex   int       `json:"end_index"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Count      int       `json:"count"`
+	Mean       float64   `json:"mean"`
+	Variance   float64   `json:"variance"`
+}
+
+type Result struct {
+	// ChangePoints is the first point of each new segment, the same as the bocd change point
+	ChangePoints []*model.ChangePoint `json:"change_points"`
+	Segments     []*Segment           `json:"segments"`
+	// Cost is the total segment cost without the penalty
+	Cost    float64 `json:"cost"`
+	Penalty float64 `json:"penalty"`
+}
+
+// detectProblem is the sorted datas and the cost of the segments
+type detectProblem struct {
+	datas   []model.TimeValue
+	values  []float64
+	cost    segmentCost
+	penalty float64
+	config  *detectConfig
+}
+
+func newDetectProblem(ctx context.Context, timeSeries *model.TimeSeries, opts ...Option) (*detectProblem, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := newDetectConfig(opts...)
+	if timeSeries.IsEmpty() || len(timeSeries.Values) < 2*config.minSegmentLength {
+		logger.Error("time series too short to detect change points",
+			zap.Int("minSegmentLength", config.minSegmentLength))
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := utils.SortedTimeValues(timeSeries.Values)
+
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		if math.IsNaN(timeValue.Value) || math.IsInf(timeValue.Value, 0) {
+			logger.Error("time series contains invalid value", zap.Any("timeValue", timeValue))
+			return nil, common.ErrorInvalidValue
+		}
+		if config.costFunction == CostPoisson && timeValue.Value < 0 {
+			logger.Error("poisson cost need non negative value", zap.Any("timeValue", timeValue))
+			return nil, common.ErrorInvalidValue
+		}
+		values = append(values, timeValue.Value)
+	}
+
+	cost := newSegmentCost(config.costFunction, values)
+	return &detectProblem{
+		datas:   datas,
+		values:  values,
+		cost:    cost,
+		penalty: penaltyValue(config.penaltyType, config.manualPenalty, cost.ParamCount(), len(values)),
+		config:  config,
+	}, nil
+}
+
+// newResult build the result by the sorted change point indexes
+func (p *detectProblem) newResult(changePointLocs []int) *Result {
+	sort.Ints(changePointLocs)
+	bounds := append(append([]int{0}, changePointLocs...), len(p.values))
+
+	res := &Result{
+		ChangePoints: []*model.ChangePoint{},
+		Segments:     []*Segment{},
+		Penalty:      p.penalty,
+	}
+	for i := 0; i+1 < len(bounds); i++ {
+		start, end := bounds[i], bounds[i+1]
+		mean, variance := meanVariance(p.values[start:end])
+		res.Segments = append(res.Segments, &Segment{
+			StartIndex: start,
+			EndIndex:   end - 1,
+			StartTime:  p.datas[start].Time,
+			EndTime:    p.datas[end-1].Time,
+			Count:      end - start,
+			Mean:       mean,
+			Variance:   variance,
+		})
+		res.Cost += p.cost.Cost(start, end)
+	}
+
+	for i := 1; i < len(res.Segments); i++ {
+		pre, post := res.Segments[i-1], res.Segments[i]
+		changePoint := &model.ChangePoint{
+			TimeValue:    p.datas[post.StartIndex],
+			DetectTime:   p.datas[len(p.datas)-1].Time,
+			RunLength:    post.Count,
+			PreMean:      pre.Mean,
+			PreVariance:  pre.Variance,
+			PostMean:     post.Mean,
+			PostVariance: post.Variance,
+			Magnitude:    post.Mean - pre.Mean,
+		}
+		changePoint.DetectionDelay = changePoint.DetectTime.Sub(changePoint.TimeValue.Time)
+		if pre.Mean != 0 {
+			changePoint.RelativeMagnitude = changePoint.Magnitude / math.Abs(pre.Mean)
+		}
+		if changePoint.Magnitude > 0 {
+			changePoint.ChangePointType = model.IncreaseChangePoint
+		} else {
+			changePoint.ChangePointType = model.DecreaseChangePoint
+		}
+		res.ChangePoints = append(res.ChangePoints, changePoint)
+	}
+	return res
+}
+
+func meanVariance(values []float64) (float64, float64) {
+	if len(values) == 1 {
+		return values[0], 0
+	}
+	return stat.MeanVariance(values, nil)
+}
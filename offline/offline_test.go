@@ -0,0 +1,191 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// segmentedTimeSeries is the minutely series of normal noise, the segment i begin at bounds[i]
+func segmentedTimeSeries(n int, bounds []int, means, stddevs []float64) *model.TimeSeries {
+	random := rand.New(rand.NewSource(1))
+	res := &model.TimeSeries{}
+	for i := 0; i < n; i++ {
+		segment := 0
+		for j, bound := range bounds {
+			if i >= bound {
+				segment = j
+			}
+		}
+		res.Values = append(res.Values, model.TimeValue{
+			Time:  testStart.Add(time.Duration(i) * time.Minute),
+			Value: means[segment] + stddevs[segment]*random.NormFloat64(),
+		})
+	}
+	return res
+}
+
+func changePointIndexes(res *Result) []int {
+	indexes := []int{}
+	for _, segment := range res.Segments[1:] {
+		indexes = append(indexes, segment.StartIndex)
+	}
+	return indexes
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPenaltyValue(t *testing.T) {
+	n := 100
+	testCases := []struct {
+		penaltyType PenaltyType
+		paramCount  int
+		expected    float64
+	}{
+		{PenaltyBIC, 1, 2 * math.Log(100)},
+		{PenaltyBIC, 2, 3 * math.Log(100)},
+		{PenaltyMBIC, 1, 3 * math.Log(100)},
+		{PenaltyMBIC, 2, 4 * math.Log(100)},
+		{PenaltyManual, 1, 7.5},
+	}
+	for _, testCase := range testCases {
+		got := penaltyValue(testCase.penaltyType, 7.5, testCase.paramCount, n)
+		if math.Abs(got-testCase.expected) > 1e-12 {
+			t.Errorf("%v with %v params got %v, expected %v", testCase.penaltyType, testCase.paramCount,
+				got, testCase.expected)
+		}
+	}
+}
+
+func TestDetectMeanShift(t *testing.T) {
+	timeSeries := segmentedTimeSeries(600, []int{0, 200, 400}, []float64{0, 5, -3}, []float64{1, 1, 1})
+	detectors := map[string]func(context.Context, *model.TimeSeries, ...Option) (*Result, error){
+		"pelt":   DetectPELT,
+		"binseg": DetectBinarySegmentation,
+		"bottom": DetectBottomUp,
+	}
+	// the mean cost has one param
+	penalties := map[PenaltyType]float64{PenaltyBIC: 2 * math.Log(600), PenaltyMBIC: 3 * math.Log(600)}
+	for name, detect := range detectors {
+		for penaltyType, expectedPenalty := range penalties {
+			res, err := detect(context.Background(), timeSeries, WithPenalty(penaltyType))
+			if err != nil {
+				t.Fatalf("%v detect failed: %v", name, err)
+			}
+			if indexes := changePointIndexes(res); !equalInts(indexes, []int{200, 400}) {
+				t.Errorf("%v %v got change points %v, expected [200 400]", name, penaltyType, indexes)
+				continue
+			}
+
+			if math.Abs(res.Penalty-expectedPenalty) > 1e-9 {
+				t.Errorf("%v got penalty %v, expected %v", name, res.Penalty, expectedPenalty)
+			}
+			first, second := res.ChangePoints[0], res.ChangePoints[1]
+			if first.ChangePointType != model.IncreaseChangePoint || second.ChangePointType != model.DecreaseChangePoint ||
+				!first.TimeValue.Time.Equal(testStart.Add(200*time.Minute)) || first.RunLength != 200 {
+				t.Errorf("%v got change points %+v %+v", name, first, second)
+			}
+			if math.Abs(first.Magnitude-5) > 0.3 || math.Abs(second.Magnitude+8) > 0.3 {
+				t.Errorf("%v got magnitudes %v %v, expected 5 -8", name, first.Magnitude, second.Magnitude)
+			}
+		}
+	}
+}
+
+// TestDetectPELTOptimal compare with the optimal partition without pruning, pelt is exact
+func TestDetectPELTOptimal(t *testing.T) {
+	timeSeries := segmentedTimeSeries(60, []int{0, 20, 35}, []float64{0, 1.5, 0.5}, []float64{1, 1, 1})
+	penalty, minSize := 4.0, 3
+
+	res, err := DetectPELT(context.Background(), timeSeries, WithManualPenalty(penalty),
+		WithMinSegmentLength(minSize))
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+
+	values := make([]float64, 0, len(timeSeries.Values))
+	for _, timeValue := range timeSeries.Values {
+		values = append(values, timeValue.Value)
+	}
+	cost := newSegmentCost(CostMean, values)
+	n := len(values)
+	optimal := make([]float64, n+1)
+	for t := 1; t <= n; t++ {
+		optimal[t] = math.Inf(1)
+		if t >= minSize {
+			optimal[t] = cost.Cost(0, t)
+		}
+		for s := minSize; s+minSize <= t; s++ {
+			optimal[t] = math.Min(optimal[t], optimal[s]+penalty+cost.Cost(s, t))
+		}
+	}
+
+	got := res.Cost + penalty*float64(len(res.ChangePoints))
+	if math.Abs(got-optimal[n]) > 1e-9 {
+		t.Errorf("got total cost %v, expected the optimal %v", got, optimal[n])
+	}
+}
+
+func TestDetectVarianceChange(t *testing.T) {
+	timeSeries := segmentedTimeSeries(400, []int{0, 200}, []float64{0, 0}, []float64{1, 4})
+	res, err := DetectPELT(context.Background(), timeSeries, WithCostFunction(CostVariance))
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	indexes := changePointIndexes(res)
+	if len(indexes) != 1 || math.Abs(float64(indexes[0]-200)) > 5 {
+		t.Fatalf("got change points %v, expected near 200", indexes)
+	}
+	if variance := res.Segments[1].Variance; math.Abs(variance-16) > 4 {
+		t.Errorf("got variance %v, expected about 16", variance)
+	}
+}
+
+func TestDetectBinarySegmentationMaxChangePoints(t *testing.T) {
+	timeSeries := segmentedTimeSeries(600, []int{0, 200, 400}, []float64{0, 5, -3}, []float64{1, 1, 1})
+	res, err := DetectBinarySegmentation(context.Background(), timeSeries, WithMaxChangePoints(1))
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	// the split at 400 reduce more cost, the means are 2.5 and -3
+	if indexes := changePointIndexes(res); !equalInts(indexes, []int{400}) {
+		t.Errorf("got change points %v, expected [400]", indexes)
+	}
+}
+
+func TestDetectInvalidSeries(t *testing.T) {
+	ctx := context.Background()
+	short := segmentedTimeSeries(3, []int{0}, []float64{0}, []float64{1})
+	if _, err := DetectPELT(ctx, short); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the short series", err)
+	}
+
+	negative := segmentedTimeSeries(20, []int{0}, []float64{0}, []float64{1})
+	if _, err := DetectPELT(ctx, negative, WithCostFunction(CostPoisson)); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the negative poisson series", err)
+	}
+
+	nan := segmentedTimeSeries(20, []int{0}, []float64{0}, []float64{1})
+	nan.Values[5].Value = math.NaN()
+	if _, err := DetectBottomUp(ctx, nan); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the nan series", err)
+	}
+}
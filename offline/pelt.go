@@ -0,0 +1,71 @@
+package offline
+
+import (
+	"context"
+	"math"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// DetectPELT find the segmentation minimize the total cost plus penalty exactly,
+// the candidates can't be the last change point anymore are pruned, so the cost is nearly linear.
+// Killick, Fearnhead and Eckley 2012
+func DetectPELT(ctx context.Context, timeSeries *model.TimeSeries, opts ...Option) (*Result, error) {
+	logger := utils.GetLogger(ctx)
+
+	problem, err := newDetectProblem(ctx, timeSeries, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	n, minSize, penalty := len(problem.values), problem.config.minSegmentLength, problem.penalty
+
+	// optimalCosts[t] is the min cost of values[:t], lastChangePoints[t] is the begin of the last segment
+	optimalCosts := make([]float64, n+1)
+	lastChangePoints := make([]int, n+1)
+	for t := 1; t <= n; t++ {
+		optimalCosts[t] = math.Inf(1)
+	}
+	optimalCosts[0] = -penalty
+
+	candidates := []int{0}
+	for t := minSize; t <= n; t++ {
+		segmentCosts := make([]float64, len(candidates))
+		for i, s := range candidates {
+			segmentCosts[i] = math.Inf(1)
+			if t-s < minSize {
+				continue
+			}
+			segmentCosts[i] = optimalCosts[s] + problem.cost.Cost(s, t)
+			if segmentCosts[i]+penalty < optimalCosts[t] {
+				optimalCosts[t] = segmentCosts[i] + penalty
+				lastChangePoints[t] = s
+			}
+		}
+
+		// prune the candidates which never be better than t
+		newCandidates := make([]int, 0, len(candidates)+1)
+		for i, s := range candidates {
+			if t-s < minSize || segmentCosts[i] <= optimalCosts[t] {
+				newCandidates = append(newCandidates, s)
+			}
+		}
+		// t can be the begin of the next segment
+		if t+minSize <= n {
+			newCandidates = append(newCandidates, t)
+		}
+		candidates = newCandidates
+	}
+
+	changePointLocs := []int{}
+	for t := lastChangePoints[n]; t > 0; t = lastChangePoints[t] {
+		changePointLocs = append(changePointLocs, t)
+	}
+
+	res := problem.newResult(changePointLocs)
+	logger.Info("pelt detect change points success", zap.Int("pointCnt", n),
+		zap.Int("changePointCnt", len(res.ChangePoints)), zap.Float64("penalty", penalty))
+	return res, nil
+}
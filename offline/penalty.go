@@ -0,0 +1,38 @@
+package offline
+
+import "math"
+
+type PenaltyType int
+
+const (
+	// PenaltyBIC is (paramCount + 1) * log(n), the params of the new segment and the change point location
+	PenaltyBIC PenaltyType = iota
+	// PenaltyMBIC is (paramCount + 2) * log(n), the modified bic of zhang and siegmund
+	// without the segment length term, same as the changepoint package of R
+	PenaltyMBIC
+	// PenaltyManual use the value set by WithManualPenalty
+	PenaltyManual
+)
+
+func (p PenaltyType) String() string {
+	switch p {
+	case PenaltyBIC:
+		return "bic"
+	case PenaltyMBIC:
+		return "mbic"
+	case PenaltyManual:
+		return "manual"
+	}
+	return "unknown"
+}
+
+// penaltyValue is the cost added for each change point
+func penaltyValue(penaltyType PenaltyType, manualPenalty float64, paramCount, n int) float64 {
+	switch penaltyType {
+	case PenaltyMBIC:
+		return float64(paramCount+2) * math.Log(float64(n))
+	case PenaltyManual:
+		return manualPenalty
+	}
+	return float64(paramCount+1) * math.Log(float64(n))
+}
@@ -0,0 +1,255 @@
+package shesd
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/stl"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// madScale make the mad a consistent estimator of the stddev of the normal distribution
+const madScale = 1.4826
+
+type Direction int
+
+const (
+	DirectionBoth Direction = iota
+	DirectionPositive
+	DirectionNegative
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionBoth:
+		return "both"
+	case DirectionPositive:
+		return "pos"
+	case DirectionNegative:
+		return "neg"
+	default:
+		return "unknown"
+	}
+}
+
+type detectConfig struct {
+	maxAnomalies float64
+	alpha        float64
+	direction    Direction
+	stlOptions   []stl.Option
+}
+
+type Option func(*detectConfig)
+
+// WithMaxAnomalies the max fraction of the anomalies in the series, in (0, 0.5], default is 0.1
+func WithMaxAnomalies(fraction float64) Option {
+	return func(c *detectConfig) {
+		if fraction > 0 && fraction <= 0.5 {
+			c.maxAnomalies = fraction
+		}
+	}
+}
+
+// WithAlpha the significance level of the esd test, default is 0.05
+func WithAlpha(alpha float64) Option {
+	return func(c *detectConfig) {
+		if alpha > 0 && alpha < 1 {
+			c.alpha = alpha
+		}
+	}
+}
+
+// WithDirection default is DirectionBoth
+func WithDirection(direction Direction) Option {
+	return func(c *detectConfig) {
+		c.direction = direction
+	}
+}
+
+// WithStlOptions set the options of the decomposition, default is the robust and periodic stl
+func WithStlOptions(opts ...stl.Option) Option {
+	return func(c *detectConfig) {
+		c.stlOptions = append(c.stlOptions, opts...)
+	}
+}
+
+func newDetectConfig(opts ...Option) *detectConfig {
+	config := &detectConfig{
+		maxAnomalies: 0.1,
+		alpha:        0.05,
+		direction:    DirectionBoth,
+		stlOptions:   []stl.Option{stl.WithRobust(true), stl.WithPeriodicSeasonal()},
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+type Anomaly struct {
+	model.TimeValue
+	// Expected is the seasonal plus the median of the series
+	Expected  float64               `json:"expected"`
+	Residual  float64               `json:"residual"`
+	Direction model.ChangePointType `json:"direction"`
+	// Score is the test statistic, |residual - median| / mad of the remaining residuals when removed
+	Score    float64 `json:"score"`
+	Critical float64 `json:"critical"`
+	// Lower and Upper is the expected range when the point is removed
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+type Result struct {
+	// Anomalies is in the time order
+	Anomalies     []*Anomaly         `json:"anomalies"`
+	Decomposition *stl.Decomposition `json:"decomposition"`
+}
+
+// Detect is the seasonal hybrid esd of Hochenbaum et al. 2017, the residual is the value minus the seasonal
+// of the robust periodic stl and the median of the series, then the generalized esd test with the median and mad
+// find the anomalies on it. periods is the seasons of the series like 24h, more periods use mstl
+func Detect(ctx context.Context, timeSeries *model.TimeSeries, periods []time.Duration,
+	opts ...Option) (*Result, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := newDetectConfig(opts...)
+	decomposition, err := stl.Decompose(ctx, timeSeries, periods, config.stlOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := decomposition.Seasonal.Values
+	n := len(values)
+	datas := make([]model.TimeValue, 0, n)
+	sortedValues := make([]float64, 0, n)
+	for i := range decomposition.Trend.Values {
+		// the components are sorted by time, the value is the sum of them
+		value := decomposition.Trend.Values[i].Value + values[i].Value + decomposition.Residual.Values[i].Value
+		datas = append(datas, model.TimeValue{Time: values[i].Time, Value: value})
+		sortedValues = append(sortedValues, value)
+	}
+	sort.Float64s(sortedValues)
+	level := utils.Median(sortedValues)
+
+	residuals := make([]float64, n)
+	for i := range datas {
+		residuals[i] = datas[i].Value - values[i].Value - level
+	}
+
+	maxAnomalies := max(int(float64(n)*config.maxAnomalies), 1)
+	steps := esd(residuals, maxAnomalies, config.alpha, config.direction)
+
+	anomalies := []*Anomaly{}
+	for _, step := range steps {
+		if !step.anomaly {
+			continue
+		}
+		expected := values[step.index].Value + level
+		anomaly := &Anomaly{
+			TimeValue: datas[step.index],
+			Expected:  expected,
+			Residual:  residuals[step.index],
+			Score:     step.score,
+			Critical:  step.critical,
+			Lower:     expected + step.center - step.critical*step.mad,
+			Upper:     expected + step.center + step.critical*step.mad,
+		}
+		if anomaly.Residual > step.center {
+			anomaly.Direction = model.IncreaseChangePoint
+		} else {
+			anomaly.Direction = model.DecreaseChangePoint
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Before(anomalies[j].TimeValue)
+	})
+
+	logger.Debug("s-h-esd detect finished", zap.Int("pointCnt", n), zap.Int("maxAnomalies", maxAnomalies),
+		zap.Int("anomalyCnt", len(anomalies)), zap.Stringer("direction", config.direction))
+	return &Result{
+		Anomalies:     anomalies,
+		Decomposition: decomposition,
+	}, nil
+}
+
+// esdStep is the point removed in one step of the test
+type esdStep struct {
+	index    int
+	score    float64
+	critical float64
+	center   float64 // the median of the remaining residuals
+	mad      float64 // the scaled mad of the remaining residuals
+	anomaly  bool
+}
+
+// esd is the generalized esd test of Rosner 1983 with the median and mad instead of the mean and stddev,
+// the points removed before the last step whose score > critical are the anomalies
+func esd(residuals []float64, maxAnomalies int, alpha float64, direction Direction) []*esdStep {
+	n := len(residuals)
+	removed := make([]bool, n)
+	steps := make([]*esdStep, 0, maxAnomalies)
+	anomalyCnt := 0
+
+	remaining := make([]float64, 0, n)
+	for i := 1; i <= maxAnomalies && i < n-1; i++ {
+		remaining = remaining[:0]
+		for j, r := range residuals {
+			if !removed[j] {
+				remaining = append(remaining, r)
+			}
+		}
+		sort.Float64s(remaining)
+		center := utils.Median(remaining)
+		for k := range remaining {
+			remaining[k] = math.Abs(remaining[k] - center)
+		}
+		sort.Float64s(remaining)
+		mad := utils.Median(remaining) * madScale
+		if mad == 0 {
+			break
+		}
+
+		step := &esdStep{index: -1, center: center, mad: mad}
+		for j, r := range residuals {
+			if removed[j] {
+				continue
+			}
+			diff := r - center
+			switch direction {
+			case DirectionPositive:
+			case DirectionNegative:
+				diff = -diff
+			default:
+				diff = math.Abs(diff)
+			}
+			if step.index < 0 || diff/mad > step.score {
+				step.index, step.score = j, diff/mad
+			}
+		}
+		removed[step.index] = true
+
+		p := 1 - alpha/float64(n-i+1)
+		if direction == DirectionBoth {
+			p = 1 - alpha/float64(2*(n-i+1))
+		}
+		df := float64(n - i - 1)
+		t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: df}.Quantile(p)
+		step.critical = t * float64(n-i) / math.Sqrt((df+t*t)*float64(n-i+1))
+		if step.score > step.critical {
+			anomalyCnt = i
+		}
+		steps = append(steps, step)
+	}
+
+	for i := 0; i < anomalyCnt; i++ {
+		steps[i].anomaly = true
+	}
+	return steps
+}
@@ -0,0 +1,164 @@
+package shesd
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// dailySeries is 7 days of hourly points, the daily sine plus the uniform noise in [-0.5, 0.5)
+// and the injected offsets, the noise is bounded so no point is far from the others by chance
+func dailySeries(offsets map[int]float64) *model.TimeSeries {
+	random := rand.New(rand.NewSource(1))
+	res := &model.TimeSeries{}
+	for i := 0; i < 24*7; i++ {
+		value := 100 + 10*math.Sin(2*math.Pi*float64(i)/24) + random.Float64() - 0.5 + offsets[i]
+		res.Values = append(res.Values, model.TimeValue{Time: testStart.Add(time.Duration(i) * time.Hour),
+			Value: value})
+	}
+	return res
+}
+
+func anomalyIndexes(res *Result) []int {
+	indexes := []int{}
+	for _, anomaly := range res.Anomalies {
+		indexes = append(indexes, int(anomaly.Time.Sub(testStart)/time.Hour))
+	}
+	return indexes
+}
+
+func TestDetect(t *testing.T) {
+	offsets := map[int]float64{30: 15, 80: -15, 130: 20}
+	res, err := Detect(context.Background(), dailySeries(offsets), []time.Duration{24 * time.Hour})
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+
+	indexes := anomalyIndexes(res)
+	if len(indexes) != 3 || indexes[0] != 30 || indexes[1] != 80 || indexes[2] != 130 {
+		t.Fatalf("got anomalies at %v, expected [30 80 130]", indexes)
+	}
+	for i, anomaly := range res.Anomalies {
+		expectedDirection := model.IncreaseChangePoint
+		if offsets[indexes[i]] < 0 {
+			expectedDirection = model.DecreaseChangePoint
+		}
+		// the residual is about the offset, the expected value is the sine without it
+		if anomaly.Direction != expectedDirection || math.Abs(anomaly.Residual-offsets[indexes[i]]) > 2 ||
+			math.Abs(anomaly.Expected-(100+10*math.Sin(2*math.Pi*float64(indexes[i])/24))) > 2 ||
+			anomaly.Score <= anomaly.Critical || (anomaly.Value >= anomaly.Lower && anomaly.Value <= anomaly.Upper) {
+			t.Errorf("unexpected anomaly %+v", anomaly)
+		}
+	}
+	if len(res.Decomposition.Residual.Values) != 24*7 {
+		t.Errorf("got %v residuals, expected %v", len(res.Decomposition.Residual.Values), 24*7)
+	}
+}
+
+func TestDetectDirection(t *testing.T) {
+	offsets := map[int]float64{30: 15, 80: -15, 130: 20}
+	timeSeries := dailySeries(offsets)
+	testCases := []struct {
+		direction Direction
+		expected  []int
+	}{
+		{DirectionPositive, []int{30, 130}},
+		{DirectionNegative, []int{80}},
+	}
+	for _, testCase := range testCases {
+		res, err := Detect(context.Background(), timeSeries, []time.Duration{24 * time.Hour},
+			WithDirection(testCase.direction))
+		if err != nil {
+			t.Fatalf("detect failed: %v", err)
+		}
+		indexes := anomalyIndexes(res)
+		if len(indexes) != len(testCase.expected) {
+			t.Errorf("%v got anomalies at %v, expected %v", testCase.direction, indexes, testCase.expected)
+			continue
+		}
+		for i := range indexes {
+			if indexes[i] != testCase.expected[i] {
+				t.Errorf("%v got anomalies at %v, expected %v", testCase.direction, indexes, testCase.expected)
+			}
+		}
+	}
+
+	// the max anomalies limit the count, the largest ones are kept
+	res, err := Detect(context.Background(), timeSeries, []time.Duration{24 * time.Hour}, WithMaxAnomalies(1.0/168))
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if indexes := anomalyIndexes(res); len(indexes) != 1 || indexes[0] != 130 {
+		t.Errorf("got anomalies at %v with max 1, expected [130]", indexes)
+	}
+}
+
+func TestDetectNoAnomaly(t *testing.T) {
+	res, err := Detect(context.Background(), dailySeries(nil), []time.Duration{24 * time.Hour})
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if len(res.Anomalies) != 0 {
+		t.Errorf("got anomalies at %v, expected none", anomalyIndexes(res))
+	}
+}
+
+func TestEsd(t *testing.T) {
+	// the residuals are symmetric around 0 except the outlier, the mad of the 10 points is 1 * 1.4826
+	residuals := []float64{-2, -1, -1, 0, 0, 0, 0, 1, 1, 2, 30}
+	steps := esd(residuals, 2, 0.05, DirectionBoth)
+	if len(steps) != 2 || steps[0].index != 10 || !steps[0].anomaly || steps[1].anomaly {
+		t.Fatalf("got steps %+v %+v", steps[0], steps[1])
+	}
+	if math.Abs(steps[0].score-30/madScale) > 1e-12 {
+		t.Errorf("got score %v, expected %v", steps[0].score, 30/madScale)
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	res, err := Detect(context.Background(), dailySeries(map[int]float64{30: 15}), []time.Duration{24 * time.Hour})
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	decoded := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	keys := []string{}
+	for key := range decoded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "anomalies,decomposition" {
+		t.Errorf("got keys %v", keys)
+	}
+	for _, key := range []string{`"time":`, `"value":`, `"expected":`, `"critical":`, `"trend":`,
+		`"seasonals":`, `"robust_weights":`, `"period":`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("json missing %v", key)
+		}
+	}
+
+	roundTrip := &Result{}
+	if err := json.Unmarshal(data, roundTrip); err != nil {
+		t.Fatalf("unmarshal result failed: %v", err)
+	}
+	if len(roundTrip.Anomalies) != 1 || len(res.Anomalies) != 1 || !roundTrip.Anomalies[0].Time.Equal(res.Anomalies[0].Time) ||
+		roundTrip.Anomalies[0].Score != res.Anomalies[0].Score {
+		t.Errorf("got anomalies %+v after round trip", roundTrip.Anomalies)
+	}
+}
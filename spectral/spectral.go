@@ -0,0 +1,252 @@
+package spectral
+
+import (
+	"context"
+	"math"
+	"math/cmplx"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+const eps = 1e-8
+
+type srConfig struct {
+	amplitudeWindow int
+	scoreWindow     int
+	extendPoints    int
+	threshold       float64
+	windowSize      int
+}
+
+type Option func(*srConfig)
+
+// WithAmplitudeWindow the moving average window of the log amplitude spectrum, default is 3
+func WithAmplitudeWindow(window int) Option {
+	return func(c *srConfig) {
+		if window > 0 {
+			c.amplitudeWindow = window
+		}
+	}
+}
+
+// WithScoreWindow the moving average window of the saliency map, default is 21
+func WithScoreWindow(window int) Option {
+	return func(c *srConfig) {
+		if window > 0 {
+			c.scoreWindow = window
+		}
+	}
+}
+
+// WithExtendPoints the estimated points appended after the last point, so the last point is
+// in the middle of the fft window instead of the edge, default is 5
+func WithExtendPoints(extendPoints int) Option {
+	return func(c *srConfig) {
+		if extendPoints >= 0 {
+			c.extendPoints = extendPoints
+		}
+	}
+}
+
+// WithThreshold the point with the score larger than it is anomaly, default is 3
+func WithThreshold(threshold float64) Option {
+	return func(c *srConfig) {
+		if threshold > 0 {
+			c.threshold = threshold
+		}
+	}
+}
+
+// WithWindowSize the sliding window of the streaming detector, default is 128
+func WithWindowSize(windowSize int) Option {
+	return func(c *srConfig) {
+		if windowSize > 0 {
+			c.windowSize = windowSize
+		}
+	}
+}
+
+func newSrConfig(opts ...Option) *srConfig {
+	config := &srConfig{
+		amplitudeWindow: 3,
+		scoreWindow:     21,
+		extendPoints:    5,
+		threshold:       3,
+		windowSize:      128,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// PointScore is the spectral residual result of one point
+type PointScore struct {
+	model.TimeValue
+	// Saliency is the value of the saliency map
+	Saliency float64 `json:"saliency"`
+	// Score is |saliency - mean| / mean, the mean is the average saliency of the score window ending at the point
+	Score     float64 `json:"score"`
+	IsAnomaly bool    `json:"is_anomaly"`
+}
+
+type Result struct {
+	// Scores is in the time order, one for each point
+	Scores []*PointScore `json:"scores"`
+}
+
+// Anomalies return the scores of the anomaly points
+func (r *Result) Anomalies() []*PointScore {
+	res := []*PointScore{}
+	for _, score := range r.Scores {
+		if score.IsAnomaly {
+			res = append(res, score)
+		}
+	}
+	return res
+}
+
+// Detect run the spectral residual of Ren et al. 2019 on the whole series once, the series is extended by the
+// estimated points so the last points are scored as the others
+func Detect(ctx context.Context, timeSeries *model.TimeSeries, opts ...Option) (*Result, error) {
+	logger := utils.GetLogger(ctx)
+
+	config := newSrConfig(opts...)
+	if timeSeries.IsEmpty() || len(timeSeries.Values) < 2 {
+		logger.Error("time series too short to detect spectral residual")
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := make([]model.TimeValue, 0, len(timeSeries.Values))
+	for _, timeValue := range timeSeries.Values {
+		if invalidValue(timeValue.Value) {
+			logger.Warn("skip invalid point", zap.Any("timeValue", timeValue))
+			continue
+		}
+		datas = append(datas, timeValue)
+	}
+	if len(datas) < 2 {
+		logger.Error("too few valid points to detect spectral residual", zap.Int("pointCnt", len(datas)))
+		return nil, common.ErrorInvalidValue
+	}
+	datas = utils.SortedTimeValues(datas)
+
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		values = append(values, timeValue.Value)
+	}
+	saliencies := saliencyMap(values, config)
+	scores := saliencyScores(saliencies, config.scoreWindow)
+
+	res := &Result{Scores: make([]*PointScore, 0, len(datas))}
+	for i, timeValue := range datas {
+		res.Scores = append(res.Scores, &PointScore{
+			TimeValue: timeValue,
+			Saliency:  saliencies[i],
+			Score:     scores[i],
+			IsAnomaly: scores[i] > config.threshold,
+		})
+	}
+	logger.Debug("spectral residual detect finished", zap.Int("pointCnt", len(datas)),
+		zap.Int("anomalyCnt", len(res.Anomalies())))
+	return res, nil
+}
+
+// saliencyMap extend the values then return the saliency of the original values
+func saliencyMap(values []float64, config *srConfig) []float64 {
+	extended := extend(values, config.extendPoints)
+	n := len(extended)
+
+	seq := make([]complex128, n)
+	for i, v := range extended {
+		seq[i] = complex(v, 0)
+	}
+	fft := fourier.NewCmplxFFT(n)
+	coeffs := fft.Coefficients(nil, seq)
+
+	amplitudes := make([]float64, n)
+	logAmplitudes := make([]float64, n)
+	for i, c := range coeffs {
+		amplitudes[i] = cmplx.Abs(c)
+		if amplitudes[i] > eps {
+			logAmplitudes[i] = math.Log(amplitudes[i])
+		}
+	}
+
+	// the spectral residual keep the phase and replace the amplitude
+	averageLogAmplitudes := trailingAverage(logAmplitudes, config.amplitudeWindow)
+	for i, c := range coeffs {
+		if amplitudes[i] <= eps {
+			coeffs[i] = 0
+			continue
+		}
+		residual := math.Exp(logAmplitudes[i] - averageLogAmplitudes[i])
+		coeffs[i] = c * complex(residual/amplitudes[i], 0)
+	}
+
+	wave := fft.Sequence(nil, coeffs)
+	res := make([]float64, len(values))
+	for i := range res {
+		res[i] = cmplx.Abs(wave[i]) / float64(n)
+	}
+	return res
+}
+
+// saliencyScores the score is the relative difference of the saliency to the average of the previous window
+func saliencyScores(saliencies []float64, window int) []float64 {
+	averages := trailingAverage(saliencies, window)
+	res := make([]float64, len(saliencies))
+	for i, s := range saliencies {
+		average := math.Max(averages[i], eps)
+		res[i] = math.Abs(s-average) / average
+	}
+	return res
+}
+
+// extend append the estimated points, all of them are the value of the last point predicted by the
+// average gradients of the m points before it, like the reference implementation of Ren et al. 2019.
+// the last point is not used, so a spike on it won't bend the extension and hide itself
+func extend(values []float64, extendPoints int) []float64 {
+	n := len(values)
+	m := min(extendPoints, n-2)
+	if m <= 0 || extendPoints <= 0 {
+		return values
+	}
+
+	// the points before the last one are x[n-m-2 : n-1]
+	window := values[n-m-2 : n-1]
+	last := window[len(window)-1]
+	next := window[1]
+	for i := 0; i < len(window)-1; i++ {
+		next += (last - window[i]) / float64(len(window)-1-i)
+	}
+
+	res := make([]float64, n, n+extendPoints)
+	copy(res, values)
+	for i := 0; i < extendPoints; i++ {
+		res = append(res, next)
+	}
+	return res
+}
+
+// trailingAverage is the average of the window points ending at each point, the first points use fewer points
+func trailingAverage(values []float64, window int) []float64 {
+	res := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		res[i] = sum / float64(min(i+1, window))
+	}
+	return res
+}
+
+func invalidValue(value float64) bool {
+	return math.IsNaN(value) || math.IsInf(value, 0)
+}
@@ -0,0 +1,179 @@
+package spectral
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// sineSeries is the minutely sine of period 20 plus the spikes
+func sineSeries(n int, spikes map[int]float64) *model.TimeSeries {
+	res := &model.TimeSeries{}
+	for i := 0; i < n; i++ {
+		res.Values = append(res.Values, model.TimeValue{
+			Time:  testStart.Add(time.Duration(i) * time.Minute),
+			Value: 10 + math.Sin(2*math.Pi*float64(i)/20) + spikes[i],
+		})
+	}
+	return res
+}
+
+func anomalyIndexes(scores []*PointScore) []int {
+	res := []int{}
+	for _, score := range scores {
+		if score.IsAnomaly {
+			res = append(res, int(score.Time.Sub(testStart)/time.Minute))
+		}
+	}
+	return res
+}
+
+func TestTrailingAverage(t *testing.T) {
+	expected := []float64{1, 1.5, 2, 3, 4}
+	for i, v := range trailingAverage([]float64{1, 2, 3, 4, 5}, 3) {
+		if math.Abs(v-expected[i]) > 1e-12 {
+			t.Errorf("got %v at %v, expected %v", v, i, expected[i])
+		}
+	}
+}
+
+func TestExtend(t *testing.T) {
+	// the window before the last point is [3 4 5 6], next = 4 + 3/3 + 2/2 + 1/1 = 7,
+	// the spike on the last point is not used
+	res := extend([]float64{1, 2, 3, 4, 5, 6, 100}, 3)
+	expected := []float64{1, 2, 3, 4, 5, 6, 100, 7, 7, 7}
+	if len(res) != len(expected) {
+		t.Fatalf("got %v, expected %v", res, expected)
+	}
+	for i := range res {
+		if math.Abs(res[i]-expected[i]) > 1e-12 {
+			t.Fatalf("got %v, expected %v", res, expected)
+		}
+	}
+
+	if res := extend([]float64{1, 2}, 3); len(res) != 2 {
+		t.Errorf("got %v for the too short values, expected no extension", res)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	res, err := Detect(context.Background(), sineSeries(200, map[int]float64{120: 5}))
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if len(res.Scores) != 200 {
+		t.Fatalf("got %v scores, expected 200", len(res.Scores))
+	}
+	if indexes := anomalyIndexes(res.Scores); len(indexes) != 1 || indexes[0] != 120 {
+		t.Errorf("got anomalies at %v, expected [120]", indexes)
+	}
+	anomalies := res.Anomalies()
+	if len(anomalies) != 1 || anomalies[0].Value != res.Scores[120].Value || anomalies[0].Score <= 3 {
+		t.Errorf("got anomalies %+v", anomalies)
+	}
+
+	// the spike on the last point is scored as the others thanks to the extension
+	res, err = Detect(context.Background(), sineSeries(200, map[int]float64{199: 5}))
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if indexes := anomalyIndexes(res.Scores); len(indexes) != 1 || indexes[0] != 199 {
+		t.Errorf("got anomalies at %v, expected [199]", indexes)
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), `{"scores":[{"time":`) || !strings.Contains(string(data), `"is_anomaly":true`) {
+		t.Errorf("unexpected json %v", string(data[:min(len(data), 100)]))
+	}
+}
+
+func TestDetectInvalid(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Detect(ctx, sineSeries(1, nil)); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the short series", err)
+	}
+	timeSeries := sineSeries(3, nil)
+	timeSeries.Values[0].Value, timeSeries.Values[1].Value = math.NaN(), math.Inf(1)
+	if _, err := Detect(ctx, timeSeries); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v with one valid point", err)
+	}
+
+	// the invalid points are skipped, the others are sorted
+	timeSeries = sineSeries(100, map[int]float64{60: 5})
+	timeSeries.Values[10].Value = math.NaN()
+	timeSeries.Values[0], timeSeries.Values[99] = timeSeries.Values[99], timeSeries.Values[0]
+	res, err := Detect(ctx, timeSeries)
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if len(res.Scores) != 99 || !res.Scores[0].Time.Equal(testStart) {
+		t.Errorf("got %v scores begin at %v", len(res.Scores), res.Scores[0].Time)
+	}
+	if indexes := anomalyIndexes(res.Scores); len(indexes) != 1 || indexes[0] != 60 {
+		t.Errorf("got anomalies at %v, expected [60]", indexes)
+	}
+}
+
+func TestStreamDetector(t *testing.T) {
+	ctx := context.Background()
+	d := NewStreamDetector(WithWindowSize(64))
+	// the saliency of the pure sine is almost 0 at the window edge, the noise make the scores stable
+	random := rand.New(rand.NewSource(1))
+	timeSeries := sineSeries(200, map[int]float64{120: 5})
+	for i := range timeSeries.Values {
+		timeSeries.Values[i].Value += 0.3 * (random.Float64() - 0.5)
+	}
+
+	scored := 0
+	anomalies := []int{}
+	for i, timeValue := range timeSeries.Values {
+		score, ok := d.AppendPoint(ctx, timeValue)
+		// the points before the score window are not scored
+		if ok != (i >= 20) {
+			t.Fatalf("point %v got scored %v", i, ok)
+		}
+		if !ok {
+			continue
+		}
+		scored++
+		if score.IsAnomaly {
+			anomalies = append(anomalies, i)
+		}
+	}
+	// the spike is still in the window when the next point is scored, its saliency spread to the next point
+	if scored != 180 || len(anomalies) != 2 || anomalies[0] != 120 || anomalies[1] != 121 {
+		t.Errorf("got %v scored, anomalies at %v, expected [120 121]", scored, anomalies)
+	}
+	if last := d.LastAnomaly(); last == nil || !last.Time.Equal(timeSeries.Values[121].Time) {
+		t.Errorf("got last anomaly %+v", last)
+	}
+	if last := d.LastValue(); last == nil || !last.Time.Equal(timeSeries.Values[199].Time) {
+		t.Errorf("got last value %+v", last)
+	}
+
+	// the point not after the last one and the invalid point are skipped
+	if _, ok := d.AppendPoint(ctx, timeSeries.Values[150]); ok {
+		t.Errorf("the old point is scored")
+	}
+	if _, ok := d.AppendPoint(ctx, model.TimeValue{Time: testStart.Add(time.Hour * 10), Value: math.NaN()}); ok {
+		t.Errorf("the nan point is scored")
+	}
+
+	d.Reset()
+	if d.LastValue() != nil || d.LastAnomaly() != nil {
+		t.Errorf("the detector is not cleared")
+	}
+}
@@ -0,0 +1,110 @@
+package spectral
+
+import (
+	"context"
+	"sync"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+// StreamDetector score each new point with the spectral residual of the sliding window ending at it,
+// the window is extended by the estimated points so the new point is not at the edge of the fft.
+// safe for concurrent use
+type StreamDetector struct {
+	mu sync.Mutex
+
+	config *srConfig
+	datas  []model.TimeValue
+
+	lastAnomaly *PointScore
+}
+
+func NewStreamDetector(opts ...Option) *StreamDetector {
+	config := newSrConfig(opts...)
+	return &StreamDetector{
+		config: config,
+		datas:  make([]model.TimeValue, 0, config.windowSize),
+	}
+}
+
+// AppendPoint return false if the point is not scored, like the invalid point, the point not after
+// the last one, or the window has fewer points than the score window
+func (d *StreamDetector) AppendPoint(ctx context.Context, timeValue model.TimeValue) (*PointScore, bool) {
+	logger := utils.GetLogger(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if invalidValue(timeValue.Value) {
+		logger.Debug("skip invalid point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+	if len(d.datas) > 0 && !d.datas[len(d.datas)-1].Before(timeValue) {
+		logger.Debug("skip the point not after the last point", zap.Any("timeValue", timeValue))
+		return nil, false
+	}
+
+	if len(d.datas) == d.config.windowSize {
+		copy(d.datas, d.datas[1:])
+		d.datas = d.datas[:len(d.datas)-1]
+	}
+	d.datas = append(d.datas, timeValue)
+	if len(d.datas) < min(d.config.scoreWindow, d.config.windowSize) || len(d.datas) < 2 {
+		return nil, false
+	}
+
+	values := make([]float64, 0, len(d.datas))
+	for _, data := range d.datas {
+		values = append(values, data.Value)
+	}
+	saliencies := saliencyMap(values, d.config)
+	scores := saliencyScores(saliencies, d.config.scoreWindow)
+
+	last := len(values) - 1
+	score := &PointScore{
+		TimeValue: timeValue,
+		Saliency:  saliencies[last],
+		Score:     scores[last],
+		IsAnomaly: scores[last] > d.config.threshold,
+	}
+	if score.IsAnomaly {
+		d.lastAnomaly = score
+	}
+	copied := *score
+	return &copied, true
+}
+
+// LastValue return the copy of the last point in the window, nil if empty
+func (d *StreamDetector) LastValue() *model.TimeValue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.datas) == 0 {
+		return nil
+	}
+	copied := d.datas[len(d.datas)-1]
+	return &copied
+}
+
+// LastAnomaly return the copy of the last anomaly point, nil if not found yet
+func (d *StreamDetector) LastAnomaly() *PointScore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.lastAnomaly == nil {
+		return nil
+	}
+	copied := *d.lastAnomaly
+	return &copied
+}
+
+// Reset clear the window
+func (d *StreamDetector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.datas = d.datas[:0]
+	d.lastAnomaly = nil
+}
@@ -0,0 +1,143 @@
+package stl
+
+import "math"
+
+// loessAt fit the local polynomial at x with the points ys[left:right+1], the position of ys[i] is i.
+// robustWeights nil means all 1. return false if all the weights are 0
+func loessAt(ys, robustWeights []float64, x float64, left, right, window, degree int) (float64, bool) {
+	n := len(ys)
+	maxDist := math.Max(x-float64(left), float64(right)-x)
+	if window > n {
+		maxDist += float64(window-n) / 2
+	}
+
+	weights := make([]float64, right-left+1)
+	sumWeight := 0.0
+	for i := left; i <= right; i++ {
+		dist := math.Abs(float64(i) - x)
+		weight := 0.0
+		switch {
+		case dist <= 0.001*maxDist:
+			weight = 1
+		case dist <= 0.999*maxDist:
+			weight = math.Pow(1-math.Pow(dist/maxDist, 3), 3)
+		}
+		if robustWeights != nil {
+			weight *= robustWeights[i]
+		}
+		weights[i-left] = weight
+		sumWeight += weight
+	}
+	if sumWeight <= 0 {
+		return 0, false
+	}
+	for i := range weights {
+		weights[i] /= sumWeight
+	}
+
+	// local linear, the slope is skipped if the points are too concentrated
+	if degree > 0 {
+		center := 0.0
+		for i := left; i <= right; i++ {
+			center += weights[i-left] * float64(i)
+		}
+		spread := 0.0
+		for i := left; i <= right; i++ {
+			spread += weights[i-left] * (float64(i) - center) * (float64(i) - center)
+		}
+		if math.Sqrt(spread) > 0.001*float64(n-1) {
+			slope := (x - center) / spread
+			for i := left; i <= right; i++ {
+				weights[i-left] *= slope*(float64(i)-center) + 1
+			}
+		}
+	}
+
+	res := 0.0
+	for i := left; i <= right; i++ {
+		res += weights[i-left] * ys[i]
+	}
+	return res, true
+}
+
+// loessWindow return the nearest window points of position x
+func loessWindow(n, window int, x float64) (int, int) {
+	if window >= n {
+		return 0, n - 1
+	}
+	left := int(math.Round(x)) - window/2
+	left = min(max(left, 0), n-window)
+	return left, left + window - 1
+}
+
+// loessFit fit at x with the nearest window points, the window is widened if the robust weights of all the
+// points are 0, like the neighbours of a big outlier in the cycle subseries
+func loessFit(ys, robustWeights []float64, x float64, window, degree int) (float64, bool) {
+	for {
+		left, right := loessWindow(len(ys), window, x)
+		if value, ok := loessAt(ys, robustWeights, x, left, right, window, degree); ok {
+			return value, true
+		}
+		if window >= len(ys) {
+			return 0, false
+		}
+		window = window*2 + 1
+	}
+}
+
+// loessSmooth fit every jump points and linear interpolate the points between them
+func loessSmooth(ys, robustWeights []float64, window, degree, jump int) []float64 {
+	n := len(ys)
+	res := make([]float64, n)
+	if n == 0 {
+		return res
+	}
+	if n == 1 {
+		res[0] = ys[0]
+		return res
+	}
+	jump = max(min(jump, n-1), 1)
+
+	fitted := []int{}
+	for i := 0; i < n; i += jump {
+		fitted = append(fitted, i)
+	}
+	if fitted[len(fitted)-1] != n-1 {
+		fitted = append(fitted, n-1)
+	}
+
+	for _, i := range fitted {
+		value, ok := loessFit(ys, robustWeights, float64(i), window, degree)
+		if !ok {
+			value = ys[i]
+		}
+		res[i] = value
+	}
+	for k := 0; k+1 < len(fitted); k++ {
+		start, end := fitted[k], fitted[k+1]
+		for i := start + 1; i < end; i++ {
+			ratio := float64(i-start) / float64(end-start)
+			res[i] = res[start] + (res[end]-res[start])*ratio
+		}
+	}
+	return res
+}
+
+// movingAverage the result length is len(values) - window + 1
+func movingAverage(values []float64, window int) []float64 {
+	if len(values) < window {
+		return []float64{}
+	}
+	res := make([]float64, 0, len(values)-window+1)
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		if i >= window-1 {
+			res = append(res, sum/float64(window))
+		}
+	}
+	return res
+}
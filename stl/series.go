@@ -0,0 +1,102 @@
+package stl
+
+import (
+	"context"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+	"github.com/uyouii/timeseries-algorithms/utils"
+	"go.uber.org/zap"
+)
+
+type SeasonalComponent struct {
+	Period time.Duration     `json:"period"`
+	Series *model.TimeSeries `json:"series"`
+}
+
+// Decomposition is the components as time series, they have the same labels and times as the input
+type Decomposition struct {
+	Trend     *model.TimeSeries    `json:"trend"`
+	Seasonal  *model.TimeSeries    `json:"seasonal"` // the sum of all the seasonal components
+	Seasonals []*SeasonalComponent `json:"seasonals"`
+	Residual  *model.TimeSeries    `json:"residual"`
+	// RobustWeights is the weight of each point, the outliers have small weights, nil if not robust
+	RobustWeights []float64 `json:"robust_weights,omitempty"`
+}
+
+// Decompose run stl with one period, or mstl with more periods like daily and weekly.
+// the series need be regular spaced without gap, the interval is the median of the time diffs,
+// return common.ErrorInvalidValue if there is gap or duplicated time
+func Decompose(ctx context.Context, timeSeries *model.TimeSeries, periods []time.Duration,
+	opts ...Option) (*Decomposition, error) {
+	logger := utils.GetLogger(ctx)
+
+	if timeSeries.IsEmpty() || len(timeSeries.Values) < 2 {
+		logger.Error("time series too short to decompose")
+		return nil, common.ErrorInvalidValue
+	}
+
+	datas := utils.SortedTimeValues(timeSeries.Values)
+
+	interval := utils.MedianInterval(datas)
+	if interval <= 0 {
+		logger.Error("can not infer the interval of time series", zap.String("timeSeries", timeSeries.DebugString()))
+		return nil, common.ErrorInvalidValue
+	}
+	if !utils.RegularSpaced(datas, interval) {
+		logger.Error("time series is not regular spaced", zap.Duration("interval", interval),
+			zap.String("timeSeries", timeSeries.DebugString()))
+		return nil, common.ErrorInvalidValue
+	}
+
+	periodCnts := make([]int, 0, len(periods))
+	for _, period := range periods {
+		periodCnts = append(periodCnts, int((period+interval/2)/interval))
+	}
+
+	values := make([]float64, 0, len(datas))
+	for _, timeValue := range datas {
+		values = append(values, timeValue.Value)
+	}
+
+	components, err := DecomposeValues(values, periodCnts, opts...)
+	if err != nil {
+		logger.Error("decompose time series failed", zap.Error(err), zap.Ints("periodCnts", periodCnts),
+			zap.Int("pointCnt", len(values)))
+		return nil, err
+	}
+
+	seasonal := make([]float64, len(values))
+	res := &Decomposition{
+		Trend:         toTimeSeries(timeSeries.Labels, datas, components.Trend),
+		Seasonals:     make([]*SeasonalComponent, 0, len(periods)),
+		Residual:      toTimeSeries(timeSeries.Labels, datas, components.Residual),
+		RobustWeights: components.RobustWeights,
+	}
+	for i, period := range periods {
+		res.Seasonals = append(res.Seasonals, &SeasonalComponent{
+			Period: period,
+			Series: toTimeSeries(timeSeries.Labels, datas, components.Seasonals[i]),
+		})
+		for j, v := range components.Seasonals[i] {
+			seasonal[j] += v
+		}
+	}
+	res.Seasonal = toTimeSeries(timeSeries.Labels, datas, seasonal)
+	return res, nil
+}
+
+func toTimeSeries(labels map[string]string, datas []model.TimeValue, values []float64) *model.TimeSeries {
+	res := &model.TimeSeries{
+		Labels: map[string]string{},
+		Values: make([]model.TimeValue, 0, len(values)),
+	}
+	for key, value := range labels {
+		res.Labels[key] = value
+	}
+	for i, v := range values {
+		res.Values = append(res.Values, model.TimeValue{Time: datas[i].Time, Value: v})
+	}
+	return res
+}
@@ -0,0 +1,347 @@
+package stl
+
+import (
+	"math"
+	"sort"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/utils"
+)
+
+type stlConfig struct {
+	seasonalWindow  int // 0 means default, 7 for stl and 7 + 4 * i for the i-th period of mstl
+	trendWindow     int // 0 means the smallest odd >= 1.5 * period / (1 - 1.5 / seasonalWindow)
+	lowPassWindow   int // 0 means the smallest odd > period
+	periodic        bool
+	seasonalDegree  int
+	trendDegree     int
+	robust          bool
+	innerIterations int // 0 means 2 if robust else 5
+	outerIterations int // -1 means 15 if robust else 0
+	mstlIterations  int
+}
+
+type Option func(*stlConfig)
+
+// WithSeasonalWindow the loess window of the cycle subseries, odd and >= 7, larger is smoother
+func WithSeasonalWindow(window int) Option {
+	return func(c *stlConfig) {
+		c.seasonalWindow = window
+	}
+}
+
+// WithPeriodicSeasonal the seasonal is the same for all the seasons, like s.window = "periodic" of R stl.
+// the seasonal window is set to 10 * n + 1 and the seasonal degree is 0
+func WithPeriodicSeasonal() Option {
+	return func(c *stlConfig) {
+		c.periodic = true
+	}
+}
+
+// WithTrendWindow the loess window of the trend, odd
+func WithTrendWindow(window int) Option {
+	return func(c *stlConfig) {
+		c.trendWindow = window
+	}
+}
+
+// WithLowPassWindow the loess window of the low pass filter, odd
+func WithLowPassWindow(window int) Option {
+	return func(c *stlConfig) {
+		c.lowPassWindow = window
+	}
+}
+
+// WithDegrees the loess degree of the seasonal and trend smoothing, 0 or 1, default is 1
+func WithDegrees(seasonalDegree, trendDegree int) Option {
+	return func(c *stlConfig) {
+		c.seasonalDegree = min(max(seasonalDegree, 0), 1)
+		c.trendDegree = min(max(trendDegree, 0), 1)
+	}
+}
+
+// WithRobust use the robust weights so the outliers won't affect the trend and seasonal
+func WithRobust(robust bool) Option {
+	return func(c *stlConfig) {
+		c.robust = robust
+	}
+}
+
+// WithIterations set the inner and outer loop count
+func WithIterations(inner, outer int) Option {
+	return func(c *stlConfig) {
+		if inner > 0 {
+			c.innerIterations = inner
+		}
+		if outer >= 0 {
+			c.outerIterations = outer
+		}
+	}
+}
+
+// WithMSTLIterations the times to refine all the seasonal components, default is 2
+func WithMSTLIterations(iterations int) Option {
+	return func(c *stlConfig) {
+		if iterations > 0 {
+			c.mstlIterations = iterations
+		}
+	}
+}
+
+func newStlConfig(opts ...Option) *stlConfig {
+	config := &stlConfig{
+		seasonalDegree:  1,
+		trendDegree:     1,
+		outerIterations: -1,
+		mstlIterations:  2,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.innerIterations == 0 {
+		config.innerIterations = 5
+		if config.robust {
+			config.innerIterations = 2
+		}
+	}
+	if config.periodic {
+		config.seasonalDegree = 0
+	}
+	if config.outerIterations < 0 {
+		config.outerIterations = 0
+		if config.robust {
+			config.outerIterations = 15
+		}
+	}
+	return config
+}
+
+// stlParams is the windows of one period
+type stlParams struct {
+	period         int
+	seasonalWindow int
+	trendWindow    int
+	lowPassWindow  int
+}
+
+func nextOdd(x float64) int {
+	res := int(math.Ceil(x))
+	if res%2 == 0 {
+		res++
+	}
+	return res
+}
+
+func (c *stlConfig) params(n, period, seasonalWindow int) stlParams {
+	if c.seasonalWindow > 0 {
+		seasonalWindow = c.seasonalWindow
+	}
+	if c.periodic {
+		seasonalWindow = 10*n + 1
+	}
+	seasonalWindow = max(nextOdd(float64(seasonalWindow)), 3)
+
+	res := stlParams{
+		period:         period,
+		seasonalWindow: seasonalWindow,
+		trendWindow:    nextOdd(1.5 * float64(period) / (1 - 1.5/float64(seasonalWindow))),
+		lowPassWindow:  nextOdd(float64(period) + 1),
+	}
+	if c.trendWindow > 0 {
+		res.trendWindow = max(nextOdd(float64(c.trendWindow)), 3)
+	}
+	if c.lowPassWindow > 0 {
+		res.lowPassWindow = max(nextOdd(float64(c.lowPassWindow)), 3)
+	}
+	return res
+}
+
+// Components is the decomposition of the values, values = Trend + sum(Seasonals) + Residual
+type Components struct {
+	Trend     []float64
+	Seasonals [][]float64 // one for each period, in the order of the periods
+	Residual  []float64
+	// RobustWeights is the weight of each point in [0, 1], the outliers have small weights, nil if not robust
+	RobustWeights []float64
+}
+
+// DecomposeValues decompose the regular spaced values, one period is stl, more periods is mstl.
+// the period is the points count of one season and need at least 2 full seasons
+func DecomposeValues(values []float64, periods []int, opts ...Option) (*Components, error) {
+	config := newStlConfig(opts...)
+
+	if len(periods) == 0 {
+		return nil, common.ErrorInvalidValue
+	}
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, common.ErrorInvalidValue
+		}
+	}
+	sortedPeriods := append([]int{}, periods...)
+	sort.Ints(sortedPeriods)
+	for _, period := range sortedPeriods {
+		if period < 2 || len(values) < 2*period {
+			return nil, common.ErrorInvalidValue
+		}
+	}
+
+	if len(periods) == 1 {
+		trend, seasonal, robustWeights := stl(values, config, config.params(len(values), periods[0], 7))
+		return newComponents(values, trend, [][]float64{seasonal}, robustWeights), nil
+	}
+	return mstl(values, periods, config), nil
+}
+
+func newComponents(values, trend []float64, seasonals [][]float64, robustWeights []float64) *Components {
+	residual := make([]float64, len(values))
+	for i := range values {
+		residual[i] = values[i] - trend[i]
+		for _, seasonal := range seasonals {
+			residual[i] -= seasonal[i]
+		}
+	}
+	return &Components{
+		Trend:         trend,
+		Seasonals:     seasonals,
+		Residual:      residual,
+		RobustWeights: robustWeights,
+	}
+}
+
+// mstl extract the seasonal components from the shortest period, then refine them by turns.
+// Bandara, Hyndman and Bergmeir 2021
+func mstl(values []float64, periods []int, config *stlConfig) *Components {
+	order := make([]int, len(periods))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return periods[order[i]] < periods[order[j]]
+	})
+
+	n := len(values)
+	seasonals := make([][]float64, len(periods))
+	for i := range seasonals {
+		seasonals[i] = make([]float64, n)
+	}
+	deseasonalized := append([]float64{}, values...)
+
+	var trend, robustWeights []float64
+	for iteration := 0; iteration < config.mstlIterations; iteration++ {
+		for rank, index := range order {
+			for i := range deseasonalized {
+				deseasonalized[i] += seasonals[index][i]
+			}
+			var seasonal []float64
+			trend, seasonal, robustWeights = stl(deseasonalized, config,
+				config.params(n, periods[index], 7+4*rank))
+			seasonals[index] = seasonal
+			for i := range deseasonalized {
+				deseasonalized[i] -= seasonal[i]
+			}
+		}
+	}
+	return newComponents(values, trend, seasonals, robustWeights)
+}
+
+// stl is the algorithm of Cleveland et al. 1990, return the trend, seasonal and robust weights
+func stl(values []float64, config *stlConfig, params stlParams) ([]float64, []float64, []float64) {
+	n := len(values)
+	trend := make([]float64, n)
+	seasonal := make([]float64, n)
+	var robustWeights []float64
+
+	for outer := 0; outer <= config.outerIterations; outer++ {
+		for inner := 0; inner < config.innerIterations; inner++ {
+			seasonal = stlSeasonal(values, trend, robustWeights, config, params)
+			deseasonalized := make([]float64, n)
+			for i := range values {
+				deseasonalized[i] = values[i] - seasonal[i]
+			}
+			trend = loessSmooth(deseasonalized, robustWeights, params.trendWindow, config.trendDegree,
+				jump(params.trendWindow))
+		}
+		if outer < config.outerIterations {
+			robustWeights = calRobustWeights(values, trend, seasonal)
+		}
+	}
+	if config.robust {
+		robustWeights = calRobustWeights(values, trend, seasonal)
+	}
+	return trend, seasonal, robustWeights
+}
+
+// stlSeasonal smooth the cycle subseries of the detrended values, then remove the low frequency part
+func stlSeasonal(values, trend, robustWeights []float64, config *stlConfig, params stlParams) []float64 {
+	n, period := len(values), params.period
+
+	// cycle has one more season on each side
+	cycle := make([]float64, n+2*period)
+	for k := 0; k < period; k++ {
+		subseries, subWeights := []float64{}, []float64{}
+		for i := k; i < n; i += period {
+			subseries = append(subseries, values[i]-trend[i])
+			if robustWeights != nil {
+				subWeights = append(subWeights, robustWeights[i])
+			}
+		}
+		if robustWeights == nil {
+			subWeights = nil
+		}
+
+		m := len(subseries)
+		smoothed := loessSmooth(subseries, subWeights, params.seasonalWindow, config.seasonalDegree,
+			jump(params.seasonalWindow))
+		extend := func(x float64) float64 {
+			value, ok := loessFit(subseries, subWeights, x, params.seasonalWindow, config.seasonalDegree)
+			if !ok {
+				return smoothed[min(max(int(x), 0), m-1)]
+			}
+			return value
+		}
+
+		cycle[k] = extend(-1)
+		for j, value := range smoothed {
+			cycle[k+(j+1)*period] = value
+		}
+		cycle[k+(m+1)*period] = extend(float64(m))
+	}
+
+	// the length is n after the moving averages
+	lowPass := movingAverage(movingAverage(movingAverage(cycle, period), period), 3)
+	lowPass = loessSmooth(lowPass, nil, params.lowPassWindow, 1, jump(params.lowPassWindow))
+
+	seasonal := make([]float64, n)
+	for i := 0; i < n; i++ {
+		seasonal[i] = cycle[i+period] - lowPass[i]
+	}
+	return seasonal
+}
+
+func jump(window int) int {
+	return max(int(math.Ceil(float64(window)/10)), 1)
+}
+
+// calRobustWeights is the bisquare weights of the residual, h = 6 * median(|residual|)
+func calRobustWeights(values, trend, seasonal []float64) []float64 {
+	n := len(values)
+	absResiduals := make([]float64, n)
+	for i := range values {
+		absResiduals[i] = math.Abs(values[i] - trend[i] - seasonal[i])
+	}
+	sorted := append([]float64{}, absResiduals...)
+	sort.Float64s(sorted)
+	h := 6 * utils.Median(sorted)
+
+	res := make([]float64, n)
+	for i, r := range absResiduals {
+		switch {
+		case h == 0 || r <= 0.001*h:
+			res[i] = 1
+		case r <= 0.999*h:
+			res[i] = math.Pow(1-math.Pow(r/h, 2), 2)
+		}
+	}
+	return res
+}
@@ -0,0 +1,184 @@
+package stl
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/common"
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// seasonalValues is the linear trend plus the sine of each period, the amplitude is the index plus 1
+func seasonalValues(n int, slope float64, periods ...int) []float64 {
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = 10 + slope*float64(i)
+		for j, period := range periods {
+			res[i] += float64(j+1) * math.Sin(2*math.Pi*float64(i)/float64(period))
+		}
+	}
+	return res
+}
+
+func maxAbsDiff(values []float64, expected func(i int) float64, from, to int) float64 {
+	res := 0.0
+	for i := from; i < to; i++ {
+		res = math.Max(res, math.Abs(values[i]-expected(i)))
+	}
+	return res
+}
+
+func checkAdditive(t *testing.T, values []float64, components *Components) {
+	for i, v := range values {
+		sum := components.Trend[i] + components.Residual[i]
+		for _, seasonal := range components.Seasonals {
+			sum += seasonal[i]
+		}
+		if math.Abs(sum-v) > 1e-9 {
+			t.Fatalf("point %v components sum to %v, expected %v", i, sum, v)
+		}
+	}
+}
+
+func TestDecomposeValues(t *testing.T) {
+	values := seasonalValues(240, 0.05, 24)
+	components, err := DecomposeValues(values, []int{24})
+	if err != nil {
+		t.Fatalf("decompose failed: %v", err)
+	}
+	checkAdditive(t, values, components)
+
+	if components.RobustWeights != nil {
+		t.Errorf("got robust weights without robust")
+	}
+	// the loess at the edges is less accurate, check the middle seasons
+	seasonalErr := maxAbsDiff(components.Seasonals[0], func(i int) float64 {
+		return math.Sin(2 * math.Pi * float64(i) / 24)
+	}, 24, 216)
+	trendErr := maxAbsDiff(components.Trend, func(i int) float64 {
+		return 10 + 0.05*float64(i)
+	}, 24, 216)
+	if seasonalErr > 0.05 || trendErr > 0.05 {
+		t.Errorf("got seasonal error %v trend error %v", seasonalErr, trendErr)
+	}
+}
+
+func TestDecomposeValuesRobust(t *testing.T) {
+	values := seasonalValues(240, 0, 24)
+	values[100] += 60
+
+	robust, err := DecomposeValues(values, []int{24}, WithRobust(true))
+	if err != nil {
+		t.Fatalf("decompose failed: %v", err)
+	}
+	checkAdditive(t, values, robust)
+	// the spike is almost all in the residual, and its weight is near 0
+	if math.Abs(robust.Residual[100]-60) > 2 || robust.RobustWeights[100] > 0.01 {
+		t.Errorf("got residual %v weight %v at the spike", robust.Residual[100], robust.RobustWeights[100])
+	}
+	otherErr := 0.0
+	for i := 24; i < 216; i++ {
+		if i != 100 {
+			otherErr = math.Max(otherErr, math.Abs(robust.Residual[i]))
+		}
+	}
+	if otherErr > 0.5 {
+		t.Errorf("got residual %v out of the spike", otherErr)
+	}
+
+	// without robust the spike leak into the seasonal of the same phase
+	plain, err := DecomposeValues(seasonalValues(240, 0, 24), []int{24})
+	if err != nil {
+		t.Fatalf("decompose failed: %v", err)
+	}
+	nonRobust, err := DecomposeValues(values, []int{24})
+	if err != nil {
+		t.Fatalf("decompose failed: %v", err)
+	}
+	if leak := math.Abs(nonRobust.Seasonals[0][124] - plain.Seasonals[0][124]); leak < 1 {
+		t.Errorf("got seasonal leak %v without robust, expected larger than 1", leak)
+	}
+}
+
+func TestDecomposeValuesMultiplePeriods(t *testing.T) {
+	values := seasonalValues(480, 0.02, 12, 48)
+	// the periods are not sorted, the seasonals are in the order of the periods
+	components, err := DecomposeValues(values, []int{48, 12})
+	if err != nil {
+		t.Fatalf("decompose failed: %v", err)
+	}
+	checkAdditive(t, values, components)
+	if len(components.Seasonals) != 2 {
+		t.Fatalf("got %v seasonals, expected 2", len(components.Seasonals))
+	}
+
+	dailyErr := maxAbsDiff(components.Seasonals[0], func(i int) float64 {
+		return 2 * math.Sin(2*math.Pi*float64(i)/48)
+	}, 48, 432)
+	hourlyErr := maxAbsDiff(components.Seasonals[1], func(i int) float64 {
+		return math.Sin(2 * math.Pi * float64(i) / 12)
+	}, 48, 432)
+	if dailyErr > 0.2 || hourlyErr > 0.2 {
+		t.Errorf("got seasonal errors %v %v", dailyErr, hourlyErr)
+	}
+}
+
+func TestDecomposeValuesInvalid(t *testing.T) {
+	values := seasonalValues(48, 0, 12)
+	testCases := [][]int{nil, {1}, {30}, {12, 30}}
+	for _, periods := range testCases {
+		if _, err := DecomposeValues(values, periods); !errors.Is(err, common.ErrorInvalidValue) {
+			t.Errorf("periods %v got error %v", periods, err)
+		}
+	}
+	values[3] = math.NaN()
+	if _, err := DecomposeValues(values, []int{12}); !errors.Is(err, common.ErrorInvalidValue) {
+		t.Errorf("got error %v for nan", err)
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := seasonalValues(288, 0, 12)
+	timeSeries := &model.TimeSeries{Labels: map[string]string{"host": "a"}}
+	// the series is reversed, the decompose sort it by time
+	for i := len(values) - 1; i >= 0; i-- {
+		timeSeries.Values = append(timeSeries.Values, model.TimeValue{
+			Time:  start.Add(time.Duration(i) * 5 * time.Minute),
+			Value: values[i],
+		})
+	}
+
+	// one hour is 12 points of 5 minutes
+	decomposition, err := Decompose(context.Background(), timeSeries, []time.Duration{time.Hour})
+	if err != nil {
+		t.Fatalf("decompose failed: %v", err)
+	}
+	expected, _ := DecomposeValues(values, []int{12})
+	for i, timeValue := range decomposition.Residual.Values {
+		if !timeValue.Time.Equal(start.Add(time.Duration(i)*5*time.Minute)) ||
+			timeValue.Value != expected.Residual[i] || decomposition.Seasonal.Values[i].Value != expected.Seasonals[0][i] {
+			t.Fatalf("point %v got residual %+v, expected %v", i, timeValue, expected.Residual[i])
+		}
+	}
+	if len(decomposition.Seasonals) != 1 || decomposition.Seasonals[0].Period != time.Hour ||
+		decomposition.Trend.Labels["host"] != "a" {
+		t.Errorf("got decomposition %+v", decomposition)
+	}
+
+	if _, err := Decompose(context.Background(), &model.TimeSeries{}, []time.Duration{time.Hour}); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the empty series", err)
+	}
+
+	// remove some points, the period can't be counted by points with the gap
+	gap := &model.TimeSeries{Values: append(append([]model.TimeValue{}, timeSeries.Values[:100]...),
+		timeSeries.Values[110:]...)}
+	if _, err := Decompose(context.Background(), gap, []time.Duration{time.Hour}); !errors.Is(err,
+		common.ErrorInvalidValue) {
+		t.Errorf("got error %v for the series with gap", err)
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+// SortedTimeValues returns a copy of values sorted by time, keeping the order of equal times.
+func SortedTimeValues(values []model.TimeValue) []model.TimeValue {
+	datas := make([]model.TimeValue, len(values))
+	copy(datas, values)
+	sort.SliceStable(datas, func(i, j int) bool {
+		return datas[i].Before(datas[j])
+	})
+	return datas
+}
+
+// Median returns the median of sorted values, NaN if empty.
+func Median(sortedValues []float64) float64 {
+	n := len(sortedValues)
+	if n == 0 {
+		return math.NaN()
+	}
+	if n%2 == 1 {
+		return sortedValues[n/2]
+	}
+	return (sortedValues[n/2-1] + sortedValues[n/2]) / 2
+}
+
+// MedianInterval returns the median interval between neighbouring points of time sorted datas.
+func MedianInterval(datas []model.TimeValue) time.Duration {
+	if len(datas) < 2 {
+		return 0
+	}
+	diffs := make([]time.Duration, 0, len(datas)-1)
+	for i := 1; i < len(datas); i++ {
+		diffs = append(diffs, datas[i].Time.Sub(datas[i-1].Time))
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	return diffs[len(diffs)/2]
+}
+
+// RegularSpaced return whether the time sorted datas are spaced by the interval without gap or duplicated time,
+// the diffs can jitter less than half of the interval
+func RegularSpaced(datas []model.TimeValue, interval time.Duration) bool {
+	if interval <= 0 {
+		return false
+	}
+	for i := 1; i < len(datas); i++ {
+		diff := datas[i].Time.Sub(datas[i-1].Time)
+		if diff <= interval/2 || diff >= interval+interval/2 {
+			return false
+		}
+	}
+	return true
+}
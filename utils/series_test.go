@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uyouii/timeseries-algorithms/model"
+)
+
+func TestSortedTimeValues(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := []model.TimeValue{
+		{Time: start.Add(2 * time.Minute), Value: 3},
+		{Time: start, Value: 1},
+		{Time: start.Add(2 * time.Minute), Value: 4},
+		{Time: start.Add(time.Minute), Value: 2},
+	}
+
+	datas := SortedTimeValues(values)
+	for i, expected := range []float64{1, 2, 3, 4} {
+		if datas[i].Value != expected {
+			t.Errorf("got %v at %d, expected %v", datas[i].Value, i, expected)
+		}
+	}
+	if values[0].Value != 3 {
+		t.Errorf("the input values should not be changed")
+	}
+	if interval := MedianInterval(datas); interval != time.Minute {
+		t.Errorf("got %v, expected %v", interval, time.Minute)
+	}
+	if interval := MedianInterval(datas[:1]); interval != 0 {
+		t.Errorf("got %v, expected 0 for a single point", interval)
+	}
+}
+
+func TestRegularSpaced(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	datas := []model.TimeValue{
+		{Time: start}, {Time: start.Add(time.Minute)}, {Time: start.Add(2*time.Minute + 10*time.Second)},
+	}
+	if !RegularSpaced(datas, time.Minute) {
+		t.Errorf("the jitter less than half of the interval should be regular spaced")
+	}
+	gap := append(datas, model.TimeValue{Time: start.Add(4 * time.Minute)})
+	if RegularSpaced(gap, time.Minute) {
+		t.Errorf("the series with gap should not be regular spaced")
+	}
+	duplicated := append(datas, model.TimeValue{Time: datas[2].Time})
+	if RegularSpaced(duplicated, time.Minute) {
+		t.Errorf("the series with duplicated time should not be regular spaced")
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if res := Median([]float64{1, 2, 10}); res != 2 {
+		t.Errorf("got %v, expected 2", res)
+	}
+	if res := Median([]float64{1, 2, 4, 10}); res != 3 {
+		t.Errorf("got %v, expected 3", res)
+	}
+	if res := Median(nil); !math.IsNaN(res) {
+		t.Errorf("got %v, expected NaN", res)
+	}
+}